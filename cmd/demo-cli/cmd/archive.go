@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Snider/config/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importConflict  string
+	importKeepPaths bool
+)
+
+var configExportCmd = &cobra.Command{
+	Use:   "export <archive>",
+	Short: "Bundles the configuration into an archive",
+	Long: `Writes config.json and every auxiliary file in the config directory to a
+.tar.gz, .tgz or .zip archive, together with a manifest of SHA-256 checksums
+and the paths of this installation. Hidden files, lock files and .bak backups
+are left out.`,
+	Example: `  demo-cli config export profile.tar.gz
+  demo-cli config export profile.zip`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeArchives,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		format, err := config.ArchiveFormatFor(args[0])
+		if err != nil {
+			return err
+		}
+		cfg, err := config.New()
+		if err != nil {
+			return err
+		}
+		out, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		manifest, err := cfg.Export(out, format)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(args[0])
+			return err
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Exported %d files to %s.\n", len(manifest.Files), args[0])
+		return nil
+	},
+}
+
+var configImportCmd = &cobra.Command{
+	Use:   "import <archive>",
+	Short: "Restores the configuration from an archive",
+	Long: `Restores an archive written by "config export". Every file is checked against
+the manifest and config.json is validated before anything is written.
+
+--conflict decides what happens to files that already exist:
+  merge      merge imported values into the existing files (default)
+  overwrite  replace existing files
+  skip       keep existing files and only create missing ones
+
+Paths in config.json under the home, data or cache directory of the exporting
+machine are rewritten to the same place on this one, unless --keep-paths is
+given.`,
+	Example: `  demo-cli config import profile.tar.gz
+  demo-cli config import profile.zip --conflict overwrite`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeArchives,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		cfg, err := config.New()
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		info, err := in.Stat()
+		if err != nil {
+			return err
+		}
+		files, err := cfg.Import(in, info.Size(), config.ImportOptions{
+			Conflict:  config.ConflictMode(importConflict),
+			KeepPaths: importKeepPaths,
+		})
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		for _, f := range files {
+			fmt.Fprintf(tw, "%s\t%s\n", f.Action, f.Path)
+		}
+		return tw.Flush()
+	},
+}
+
+func init() {
+	configImportCmd.Flags().StringVar(&importConflict, "conflict", string(config.ConflictMerge), "what to do with existing files: overwrite, merge or skip")
+	configImportCmd.RegisterFlagCompletionFunc("conflict", cobra.FixedCompletions([]string{"merge", "overwrite", "skip"}, cobra.ShellCompDirectiveNoFileComp))
+	configImportCmd.Flags().BoolVar(&importKeepPaths, "keep-paths", false, "import directory paths without rewriting them for this machine")
+	configCmd.AddCommand(configExportCmd, configImportCmd)
+}
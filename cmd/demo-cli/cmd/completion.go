@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/Snider/config/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// archiveExtensions are the file extensions offered when completing an
+// archive for "config import" and "config diff --snapshot".
+var archiveExtensions = []string{"tar.gz", "tgz", "zip"}
+
+// formatExtensions returns the extensions of every registered format,
+// without the leading dot.
+func formatExtensions() []string {
+	var exts []string
+	for _, info := range config.Formats() {
+		for _, ext := range info.Extensions {
+			exts = append(exts, strings.TrimPrefix(ext, "."))
+		}
+	}
+	return exts
+}
+
+// completeConfigFiles completes the names of files in a registered format.
+func completeConfigFiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return formatExtensions(), cobra.ShellCompDirectiveFilterFileExt
+}
+
+// completeArchives completes the names of archives written by
+// "config export".
+func completeArchives(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return archiveExtensions, cobra.ShellCompDirectiveFilterFileExt
+}
+
+// completeFormats completes a format extension, as accepted by
+// "convert --from" and "convert --to".
+func completeFormats(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return matching(formatExtensions(), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeKey completes the key of a configuration value as the first
+// argument and nothing after it.
+func completeKey(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return matching((&config.Service{}).Keys(), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeKeyValue completes the key of a configuration value, then its
+// value: the choices suggested by the service, or file names for paths.
+func completeKeyValue(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return completeKey(cmd, args, toComplete)
+	case 1:
+		cfg, err := config.Resolve(config.Options{})
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		setting, err := cfg.Lookup(args[0])
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		if path, ok := setting.Value.(string); ok && filepath.IsAbs(path) {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		return matching(cfg.Suggestions(args[0]), toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+// matching returns the candidates that start with prefix, ignoring case.
+func matching(candidates []string, prefix string) []string {
+	var out []string
+	for _, c := range candidates {
+		if strings.HasPrefix(strings.ToLower(c), strings.ToLower(prefix)) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/Snider/config/pkg/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var configOutput string
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspects and changes the configuration",
+	Long: `Inspects and changes the values stored in config.json.
+
+Keys are the names used in config.json, such as "language" or "features", and
+are matched ignoring case.`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Prints a configuration value",
+	Example: `  demo-cli config get language
+  demo-cli config get features`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeKey,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		cfg, err := config.New()
+		if err != nil {
+			return err
+		}
+		setting, err := cfg.Lookup(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), formatSettingValue(setting.Value))
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Changes a configuration value",
+	Long: `Changes a configuration value and saves config.json.
+
+The value is parsed according to the type of the key. Lists accept a JSON
+array or a comma-separated list; an empty value clears them.`,
+	Example: `  demo-cli config set language fr
+  demo-cli config set features beta,metrics
+  demo-cli config set features '["a,b", "c"]'`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeKeyValue,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		cfg, err := config.New()
+		if err != nil {
+			return err
+		}
+		return cfg.SetString(args[0], args[1])
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:               "unset <key>",
+	Short:             "Restores the default of a configuration value",
+	Example:           `  demo-cli config unset language`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeKey,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		cfg, err := config.New()
+		if err != nil {
+			return err
+		}
+		return cfg.Unset(args[0])
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists every configuration value",
+	Long: `Lists every configuration value with the layer it comes from: "default"
+for built-in defaults and "file" for values set in config.json.`,
+	Example: `  demo-cli config list
+  demo-cli config list -o json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		cfg, err := config.New()
+		if err != nil {
+			return err
+		}
+		return writeSettings(cmd.OutOrStdout(), cfg.Settings(), configOutput)
+	},
+}
+
+// writeSettings writes settings to w as a table, JSON or YAML.
+func writeSettings(w io.Writer, settings []config.Setting, output string) error {
+	switch output {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+		for _, s := range settings {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Key, formatSettingValue(s.Value), s.Source)
+		}
+		return tw.Flush()
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(settings)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(settings); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	return fmt.Errorf("unknown output format %q, use table, json or yaml", output)
+}
+
+// formatSettingValue formats a value for the terminal: strings as they are
+// and anything else as JSON.
+func formatSettingValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(out)
+}
+
+func init() {
+	configListCmd.Flags().StringVarP(&configOutput, "output", "o", "table", "output format: table, json or yaml")
+	configListCmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions([]string{"table", "json", "yaml"}, cobra.ShellCompDirectiveNoFileComp))
+	configCmd.AddCommand(configGetCmd, configSetCmd, configUnsetCmd, configListCmd)
+	rootCmd.AddCommand(configCmd)
+}
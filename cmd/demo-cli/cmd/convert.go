@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"github.com/Snider/config/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	convertFrom   string
+	convertTo     string
+	convertStrict bool
+)
+
+var convertCmd = &cobra.Command{
+	Use:   "convert [src] [dst]",
+	Short: "Converts a config file to another format",
+	Long: `Converts a config file from one format to another, for example INI to YAML.
+
+The formats are taken from the file extensions. Use "-" (or leave the argument
+out) to read from stdin or write to stdout; the format of stdin is detected
+from its content unless --from is given, and --to is required for stdout.
+
+Values the destination format cannot represent exactly are listed as warnings
+on stderr. With --strict, a lossy conversion writes nothing and fails.`,
+	Example: `  demo-cli convert settings.ini settings.yaml
+  cat settings.xml | demo-cli convert --to json
+  demo-cli convert --from env - settings.properties < .env`,
+	Args:              cobra.MaximumNArgs(2),
+	ValidArgsFunction: completeConfigFiles,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		src, dst := "-", "-"
+		if len(args) > 0 {
+			src = args[0]
+		}
+		if len(args) > 1 {
+			dst = args[1]
+		}
+
+		srcFormat, data, err := readConvertSource(cmd.InOrStdin(), src)
+		if err != nil {
+			return err
+		}
+
+		dstName := dst
+		if convertTo != "" {
+			dstName = "out." + convertTo
+		} else if dst == "-" {
+			return fmt.Errorf("--to is required when writing to stdout")
+		}
+		dstFormat, err := config.GetConfigFormat(dstName)
+		if err != nil {
+			return err
+		}
+
+		var out bytes.Buffer
+		report, err := config.ConvertStream(bytes.NewReader(data), srcFormat, &out, dstFormat)
+		if err != nil {
+			return err
+		}
+		for _, issue := range report.Issues {
+			fmt.Fprintf(cmd.ErrOrStderr(), "warning: %s\n", issue)
+		}
+		if convertStrict && report.Lossy() {
+			return fmt.Errorf("conversion is lossy (%d values changed), nothing written", len(report.Issues))
+		}
+
+		if dst == "-" {
+			if utf8.Valid(out.Bytes()) && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+				out.WriteByte('\n')
+			}
+			_, err = cmd.OutOrStdout().Write(out.Bytes())
+			return err
+		}
+		return os.WriteFile(dst, out.Bytes(), 0644)
+	},
+}
+
+// readConvertSource reads the source of a conversion and returns its format
+// and content.
+func readConvertSource(stdin io.Reader, src string) (config.ConfigFormat, []byte, error) {
+	var data []byte
+	var err error
+	if src == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(src)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name := src
+	if convertFrom != "" {
+		name = "in." + convertFrom
+	}
+	format, err := config.GetConfigFormat(name)
+	if err != nil && convertFrom == "" {
+		ext, detectErr := config.DetectFormat(data)
+		if detectErr != nil {
+			return nil, nil, fmt.Errorf("cannot determine the source format, use --from: %w", detectErr)
+		}
+		format, err = config.GetConfigFormat(ext)
+	}
+	return format, data, err
+}
+
+func init() {
+	convertCmd.Flags().StringVar(&convertFrom, "from", "", "source format extension, e.g. yaml (default: from the file name or content)")
+	convertCmd.Flags().StringVar(&convertTo, "to", "", "destination format extension, e.g. json (default: from the file name)")
+	convertCmd.RegisterFlagCompletionFunc("from", completeFormats)
+	convertCmd.RegisterFlagCompletionFunc("to", completeFormats)
+	convertCmd.Flags().BoolVar(&convertStrict, "strict", false, "fail instead of writing when the conversion is lossy")
+	rootCmd.AddCommand(convertCmd)
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Snider/config/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffDefaults bool
+	diffSnapshot string
+	diffOutput   string
+)
+
+var configDiffCmd = &cobra.Command{
+	Use:   "diff [file] [file]",
+	Short: "Shows how two configurations differ",
+	Long: `Compares two configurations key by key and lists the values that were added,
+removed or changed, with nested keys as dotted paths and list items as
+path[index].
+
+With two files, the first is compared with the second; they may be in any
+supported format. With one file (or none, for the current config.json), the
+file is compared with the built-in defaults, or with the config.json in an
+archive written by "config export" when --snapshot is given. Keys the file
+or snapshot leaves out count as their defaults.
+
+Output formats:
+  text     one line per change: "+ path: value", "- path: value" or
+           "~ path: old -> new" (default)
+  json     a list of {"path", "kind", "old", "new"} objects
+  unified  a unified diff of the changed paths`,
+	Example: `  demo-cli config diff
+  demo-cli config diff alice.yaml bob.json
+  demo-cli config diff --snapshot profile.tar.gz -o unified`,
+	Args:              cobra.MaximumNArgs(2),
+	ValidArgsFunction: completeConfigFiles,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if diffDefaults && diffSnapshot != "" {
+			return errors.New("--defaults and --snapshot cannot be used together")
+		}
+		if len(args) == 2 && (diffDefaults || diffSnapshot != "") {
+			return errors.New("--defaults and --snapshot compare a single file")
+		}
+
+		var a, b map[string]interface{}
+		var aName, bName string
+		var err error
+		if len(args) == 2 {
+			aName, bName = args[0], args[1]
+			if a, err = config.LoadFile(aName); err != nil {
+				return err
+			}
+			if b, err = config.LoadFile(bName); err != nil {
+				return err
+			}
+		} else {
+			cfg, err := config.Resolve(config.Options{})
+			if err != nil {
+				return err
+			}
+			bName = cfg.ConfigPath
+			if len(args) == 1 {
+				bName = args[0]
+			}
+			if diffSnapshot != "" {
+				aName = diffSnapshot
+				if a, err = config.LoadSnapshot(diffSnapshot); err != nil {
+					return err
+				}
+			} else {
+				aName, a = "defaults", cfg.Defaults()
+			}
+			if b, err = config.LoadFile(bName); err != nil {
+				return err
+			}
+			// A config.json only holds the keys that were set.
+			a, b = cfg.WithDefaults(a), cfg.WithDefaults(b)
+		}
+		return writeChanges(cmd.OutOrStdout(), config.Diff(a, b), aName, bName, diffOutput)
+	},
+}
+
+// writeChanges writes changes to w as text, JSON or a unified diff of the
+// configurations named a and b.
+func writeChanges(w io.Writer, changes []config.Change, a, b, output string) error {
+	switch output {
+	case "text":
+		if len(changes) == 0 {
+			fmt.Fprintln(w, "No differences.")
+		}
+		for _, c := range changes {
+			fmt.Fprintln(w, c)
+		}
+		return nil
+	case "json":
+		if changes == nil {
+			changes = []config.Change{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(changes)
+	case "unified":
+		if len(changes) == 0 {
+			return nil
+		}
+		fmt.Fprintf(w, "--- %s\n+++ %s\n", a, b)
+		for _, c := range changes {
+			fmt.Fprintf(w, "@@ %s @@\n", c.Path)
+			if c.Kind != config.ChangeAdded {
+				fmt.Fprintf(w, "-%s: %s\n", c.Path, config.FormatDiffValue(c.Old))
+			}
+			if c.Kind != config.ChangeRemoved {
+				fmt.Fprintf(w, "+%s: %s\n", c.Path, config.FormatDiffValue(c.New))
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q, use text, json or unified", output)
+}
+
+func init() {
+	configDiffCmd.Flags().BoolVar(&diffDefaults, "defaults", false, "compare the file with the built-in defaults (the default with one file)")
+	configDiffCmd.Flags().StringVar(&diffSnapshot, "snapshot", "", "compare the file with the config.json in an exported archive")
+	configDiffCmd.Flags().StringVarP(&diffOutput, "output", "o", "text", "output format: text, json or unified")
+	configDiffCmd.RegisterFlagCompletionFunc("snapshot", completeArchives)
+	configDiffCmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions([]string{"text", "json", "unified"}, cobra.ShellCompDirectiveNoFileComp))
+	configCmd.AddCommand(configDiffCmd)
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/Snider/config/pkg/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	pathsOutput string
+	doctorFix   bool
+)
+
+var configPathsCmd = &cobra.Command{
+	Use:   "paths",
+	Short: "Prints the files and directories the configuration uses",
+	Long: `Prints every file and directory the configuration service resolves, such as
+config.json and the data and cache directories. Nothing is created, so this
+also works when config.json is missing or broken.`,
+	Example: `  demo-cli config paths
+  demo-cli config paths -o json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		cfg, err := config.Resolve(config.Options{})
+		if err != nil {
+			return err
+		}
+		return writePaths(cmd.OutOrStdout(), cfg.Paths(), pathsOutput)
+	},
+}
+
+var configDoctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Checks the configuration files and directories for problems",
+	Long: `Checks that every configuration directory exists, is writable and is not
+writable by everyone, that config.json exists, parses and holds valid values,
+and that no stale lock or temporary files remain.
+
+With --fix, the problems that can be repaired are fixed and the checks are run
+again. A config.json that cannot be parsed is kept with a ".bak" suffix and
+replaced by the defaults; invalid values are removed so that their defaults
+are used.`,
+	Example: `  demo-cli config doctor
+  demo-cli config doctor --fix`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		out := cmd.OutOrStdout()
+		problems, err := config.Doctor(config.Options{})
+		if err != nil {
+			return err
+		}
+		if doctorFix && len(problems) > 0 {
+			for _, p := range problems {
+				if !p.Fixable() {
+					continue
+				}
+				if err := p.Fix(); err != nil {
+					fmt.Fprintf(out, "could not fix %s: %v\n", p, err)
+					continue
+				}
+				fmt.Fprintf(out, "fixed %s\n", p)
+			}
+			if problems, err = config.Doctor(config.Options{}); err != nil {
+				return err
+			}
+		}
+		if len(problems) == 0 {
+			fmt.Fprintln(out, "No problems found.")
+			return nil
+		}
+		fixable := 0
+		for _, p := range problems {
+			if p.Fixable() {
+				fixable++
+				fmt.Fprintf(out, "%s (fixable)\n", p)
+			} else {
+				fmt.Fprintln(out, p)
+			}
+		}
+		if fixable > 0 && !doctorFix {
+			fmt.Fprintln(out, "Run with --fix to repair the fixable problems.")
+		}
+		return fmt.Errorf("%d problems found", len(problems))
+	},
+}
+
+// writePaths writes paths to w as a table, JSON or YAML.
+func writePaths(w io.Writer, paths []config.ResolvedPath, output string) error {
+	switch output {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tPATH")
+		for _, p := range paths {
+			fmt.Fprintf(tw, "%s\t%s\n", p.Name, p.Path)
+		}
+		return tw.Flush()
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(paths)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(paths); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	return fmt.Errorf("unknown output format %q, use table, json or yaml", output)
+}
+
+func init() {
+	configPathsCmd.Flags().StringVarP(&pathsOutput, "output", "o", "table", "output format: table, json or yaml")
+	configPathsCmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions([]string{"table", "json", "yaml"}, cobra.ShellCompDirectiveNoFileComp))
+	configDoctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "repair the problems that can be fixed automatically")
+	configCmd.AddCommand(configPathsCmd, configDoctorCmd)
+}
@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Snider/config/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var editFormat string
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edits the configuration in $EDITOR",
+	Long: `Opens the configuration in $VISUAL or $EDITOR (vi if neither is set) as a
+temporary file in the chosen format. When the editor exits, the file is parsed
+and validated, and config.json is replaced atomically.
+
+If the file cannot be parsed or validated, the editor is opened again with the
+errors as comments next to the offending keys. Save the file unchanged, or
+empty it, to give up without changing anything.`,
+	Example: `  demo-cli config edit
+  EDITOR="code --wait" demo-cli config edit --format json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		cfg, err := config.New()
+		if err != nil {
+			return err
+		}
+
+		ext := "." + strings.TrimPrefix(editFormat, ".")
+		if ext == ".json" {
+			ext = ".jsonc" // plain JSON has no comments to report errors in
+		}
+		comment, ok := commentSyntaxes[ext]
+		if !ok {
+			return fmt.Errorf("cannot edit the configuration as %s, use yaml, json, json5, hcl or xml", editFormat)
+		}
+		format, err := config.GetConfigFormat(ext)
+		if err != nil {
+			return err
+		}
+		values := cfg.Values()
+		report, err := config.CheckConversion(values, format)
+		if err != nil {
+			return err
+		}
+		if report.Lossy() {
+			return fmt.Errorf("cannot edit the configuration as %s: %s", editFormat, report.Issues[0])
+		}
+		var buf bytes.Buffer
+		if err := format.Encode(&buf, values); err != nil {
+			return err
+		}
+
+		tmp, err := os.CreateTemp("", "config-*"+ext)
+		if err != nil {
+			return err
+		}
+		path := tmp.Name()
+		defer os.Remove(path)
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+
+		original := buf.Bytes()
+		content := original
+		for {
+			if err := os.WriteFile(path, content, 0600); err != nil {
+				return err
+			}
+			if err := runEditor(path); err != nil {
+				return err
+			}
+			edited, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			switch {
+			case len(bytes.TrimSpace(edited)) == 0:
+				fmt.Fprintln(cmd.ErrOrStderr(), "Edit cancelled, the configuration was not changed.")
+				return nil
+			case bytes.Equal(edited, original):
+				fmt.Fprintln(cmd.ErrOrStderr(), "No changes.")
+				return nil
+			case bytes.Equal(edited, content):
+				return errors.New("edit abandoned, the configuration was not changed")
+			}
+
+			// Blank the old error comments rather than removing them, so that
+			// parse errors point at the lines the user saw.
+			var verrs config.ValidationErrors
+			data, err := format.Decode(bytes.NewReader(comment.strip(edited, true)))
+			if err != nil {
+				verrs = config.ValidationErrors{{Message: err.Error()}}
+			} else if err := cfg.Apply(changedValues(cfg, data)); err == nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Saved %s.\n", cfg.ConfigPath)
+				return nil
+			} else if !errors.As(err, &verrs) {
+				return err
+			}
+			content = comment.annotate(comment.strip(edited, false), verrs)
+		}
+	},
+}
+
+// changedValues returns the values of data whose keys are set in config.json
+// or that differ from the current value, so that applying them leaves keys
+// the user did not touch at their defaults instead of writing the defaults
+// into config.json. Unknown keys are kept for Apply to report.
+func changedValues(cfg *config.Service, data map[string]interface{}) map[string]interface{} {
+	changed := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		setting, err := cfg.Lookup(key)
+		if err != nil || setting.Source == config.SourceFile ||
+			len(config.Diff(map[string]interface{}{key: setting.Value}, map[string]interface{}{key: value})) > 0 {
+			changed[key] = value
+		}
+	}
+	return changed
+}
+
+// runEditor opens path in the user's editor and waits for it to exit.
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := strings.Fields(editor)
+	c := exec.Command(args[0], append(args[1:], path)...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %w", filepath.Base(args[0]), err)
+	}
+	return nil
+}
+
+// commentSyntax describes the line comments of an editable format.
+type commentSyntax struct {
+	start, end string
+}
+
+// commentSyntaxes lists the formats the configuration can be edited in.
+var commentSyntaxes = map[string]commentSyntax{
+	".yaml":  {start: "#"},
+	".yml":   {start: "#"},
+	".jsonc": {start: "//"},
+	".json5": {start: "//"},
+	".hcl":   {start: "#"},
+	".xml":   {start: "<!--", end: " -->"},
+}
+
+// errorMarker starts every comment added by annotate, so that strip can
+// remove them again.
+const errorMarker = " error: "
+
+// annotate returns content with a comment for each error, placed above the
+// first line that defines its key, and a comment at the top explaining what
+// to do.
+func (c commentSyntax) annotate(content []byte, errs config.ValidationErrors) []byte {
+	lines := strings.SplitAfter(string(content), "\n")
+	above := make(map[int][]string)
+	header := []string{c.line("", "the configuration was not saved; fix the errors below, or empty the file to cancel")}
+	for _, err := range errs {
+		if i := keyLine(lines, err.Key); i >= 0 {
+			indent := lines[i][:len(lines[i])-len(strings.TrimLeft(lines[i], " \t"))]
+			above[i] = append(above[i], c.line(indent, err.Message))
+			continue
+		}
+		header = append(header, c.line("", err.Error()))
+	}
+
+	var b strings.Builder
+	for _, l := range header {
+		b.WriteString(l)
+	}
+	for i, l := range lines {
+		for _, a := range above[i] {
+			b.WriteString(a)
+		}
+		b.WriteString(l)
+	}
+	return []byte(b.String())
+}
+
+// line formats one error comment.
+func (c commentSyntax) line(indent, msg string) string {
+	msg = strings.ReplaceAll(msg, "\n", " ")
+	if c.end != "" {
+		// XML comments must not contain "--", which messages quoting
+		// values or flags may.
+		for strings.Contains(msg, "--") {
+			msg = strings.ReplaceAll(msg, "--", "- -")
+		}
+	}
+	return indent + c.start + errorMarker + msg + c.end + "\n"
+}
+
+// strip removes the comments added by annotate, or replaces them with empty
+// lines if blank is set.
+func (c commentSyntax) strip(content []byte, blank bool) []byte {
+	var b bytes.Buffer
+	for _, l := range strings.SplitAfter(string(content), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(l), c.start+errorMarker) {
+			if blank {
+				b.WriteByte('\n')
+			}
+			continue
+		}
+		b.WriteString(l)
+	}
+	return b.Bytes()
+}
+
+// keyLine returns the index of the first line that defines key, or -1.
+func keyLine(lines []string, key string) int {
+	if key == "" {
+		return -1
+	}
+	for i, l := range lines {
+		l = strings.TrimLeft(strings.TrimSpace(l), `<"'`)
+		if !strings.HasPrefix(l, key) {
+			continue
+		}
+		if rest := l[len(key):]; rest == "" || strings.ContainsRune(`"' :=>/[`+"\t", rune(rest[0])) {
+			return i
+		}
+	}
+	return -1
+}
+
+func init() {
+	configEditCmd.Flags().StringVar(&editFormat, "format", "yaml", "format of the file to edit: yaml, json, json5, hcl or xml")
+	configEditCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"yaml", "json", "json5", "hcl", "xml"}, cobra.ShellCompDirectiveNoFileComp))
+	configCmd.AddCommand(configEditCmd)
+}
@@ -11,6 +11,8 @@ var rootCmd = &cobra.Command{
 	Use:   "demo-cli",
 	Short: "A demo CLI for the config module",
 	Long:  `A longer description that spans multiple lines and likely contains examples and usage of using your application.`,
+	// Execute prints the error.
+	SilenceErrors: true,
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("Hello from the demo CLI!")
 	},
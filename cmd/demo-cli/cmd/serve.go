@@ -1,33 +1,197 @@
 package cmd
 
 import (
-	"fmt"
+	"io/fs"
 	"log"
-	"net/http"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
+	"github.com/Snider/config/pkg/config"
+	"github.com/Snider/config/pkg/server"
 	"github.com/spf13/cobra"
 )
 
+// serveSettings are the keys read from serve.yaml in the config directory.
+type serveSettings struct {
+	server.Options
+	// SelfSigned generates a self-signed certificate if the TLS files do
+	// not exist.
+	SelfSigned bool `config:"self_signed"`
+	// Dev serves the frontend from server.DevStaticDir unless a static
+	// directory is set.
+	Dev bool `config:"dev"`
+	// RemoteConfig serves the config API also when the server listens on
+	// an address other machines can reach.
+	RemoteConfig bool `config:"remote_config"`
+}
+
+var serveFlags serveSettings
+
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Starts the HTTP server",
-	Long:  `Starts the HTTP server to serve the frontend and the API.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		http.HandleFunc("/api/v1/demo", func(w http.ResponseWriter, r *http.Request) {
-			fmt.Fprintf(w, "Hello, world!")
-		})
+	Long: `Starts the HTTP server to serve the frontend and the API.
+
+Settings are read from serve.yaml in the config directory, if it exists, and
+can be overridden with flags:
+
+  addr: localhost:8080
+  static_dir: /path/to/frontend
+  dev: false
+  tls_cert: /path/to/cert.pem
+  tls_key: /path/to/key.pem
+  self_signed: false
+  read_timeout: 15s
+  write_timeout: 15s
+  shutdown_timeout: 10s
+  remote_config: false
 
-		fs := http.FileServer(http.Dir("./ui/dist/config/browser"))
-		http.Handle("/", fs)
+The frontend is embedded in the binary when it is built. While working on the
+frontend, --dev serves the output of "npm run build" in ui/dist/config/browser
+instead, and --static-dir any other directory; changes to its files are
+served without restarting.
 
-		log.Println("Listening on :8080...")
-		err := http.ListenAndServe(":8080", nil)
+With --self-signed, a certificate for local use is generated if the TLS files
+do not exist, by default in the tls directory of the data directory. The
+server stops gracefully on SIGINT or SIGTERM, letting requests in progress
+finish.
+
+The config API under /api/v1/config changes the configuration without
+authentication, so it is only served on a loopback address such as the
+default localhost:8080. To serve it on any other address, such as :8080,
+--remote-config must be given.`,
+	Example: `  demo-cli serve --addr localhost:9000
+  demo-cli serve --addr :8080 --remote-config
+  demo-cli serve --dev
+  demo-cli serve --self-signed
+  demo-cli serve --tls-cert cert.pem --tls-key key.pem --write-timeout 1m`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		cfg, err := config.New()
 		if err != nil {
-			log.Fatal(err)
+			return err
+		}
+		settings := serveSettings{Options: server.DefaultOptions()}
+		if err := cfg.LoadStructAs("serve", ".yaml", &settings); err != nil {
+			return err
+		}
+		applyServeFlags(cmd, &settings)
+
+		opts := settings.Options
+		if opts.Loopback() || settings.RemoteConfig {
+			opts.Config = cfg
+		} else {
+			log.Printf("Warning: not serving the config API on %s, which other machines can reach; give --remote-config to serve it.", opts.Addr)
+		}
+		if settings.Dev && opts.StaticDir == "" {
+			opts.StaticDir = server.DevStaticDir
 		}
+		if _, err := fs.Stat(opts.Frontend(), "index.html"); err != nil {
+			if opts.StaticDir == "" {
+				log.Println("Warning: this binary was built without the frontend; run \"go generate ./ui\" before building it.")
+			} else {
+				log.Printf("Warning: %s has no index.html; build the frontend with \"npm run build\" in the ui directory.", opts.StaticDir)
+			}
+		}
+		if settings.SelfSigned {
+			if opts.TLSCert == "" {
+				opts.TLSCert = filepath.Join(cfg.DataDir, "tls", "cert.pem")
+			}
+			if opts.TLSKey == "" {
+				opts.TLSKey = filepath.Join(cfg.DataDir, "tls", "key.pem")
+			}
+			generated, err := server.EnsureSelfSigned(opts.TLSCert, opts.TLSKey, certificateHosts(opts.Addr))
+			if err != nil {
+				return err
+			}
+			if generated {
+				log.Printf("Generated a self-signed certificate in %s", filepath.Dir(opts.TLSCert))
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		scheme := "http"
+		if opts.TLS() {
+			scheme = "https"
+		}
+		if opts.StaticDir != "" {
+			log.Printf("Serving the frontend from %s", opts.StaticDir)
+		}
+		log.Printf("Listening on %s://%s...", scheme, opts.Addr)
+		if err := server.ListenAndServe(ctx, opts, server.NewHandler(opts)); err != nil {
+			return err
+		}
+		log.Println("Server stopped.")
+		return nil
 	},
 }
 
+// applyServeFlags overrides settings with the flags given on the command
+// line.
+func applyServeFlags(cmd *cobra.Command, settings *serveSettings) {
+	flags := cmd.Flags()
+	if flags.Changed("addr") {
+		settings.Addr = serveFlags.Addr
+	}
+	if flags.Changed("static-dir") {
+		settings.StaticDir = serveFlags.StaticDir
+	}
+	if flags.Changed("tls-cert") {
+		settings.TLSCert = serveFlags.TLSCert
+	}
+	if flags.Changed("tls-key") {
+		settings.TLSKey = serveFlags.TLSKey
+	}
+	if flags.Changed("dev") {
+		settings.Dev = serveFlags.Dev
+	}
+	if flags.Changed("self-signed") {
+		settings.SelfSigned = serveFlags.SelfSigned
+	}
+	if flags.Changed("remote-config") {
+		settings.RemoteConfig = serveFlags.RemoteConfig
+	}
+	if flags.Changed("read-timeout") {
+		settings.ReadTimeout = serveFlags.ReadTimeout
+	}
+	if flags.Changed("write-timeout") {
+		settings.WriteTimeout = serveFlags.WriteTimeout
+	}
+	if flags.Changed("shutdown-timeout") {
+		settings.ShutdownTimeout = serveFlags.ShutdownTimeout
+	}
+}
+
+// certificateHosts returns the names a self-signed certificate for addr is
+// issued to: localhost and the host of addr, if any.
+func certificateHosts(addr string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if host, _, err := net.SplitHostPort(addr); err == nil && host != "" && host != "localhost" {
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
 func init() {
+	defaults := server.DefaultOptions()
+	flags := serveCmd.Flags()
+	flags.StringVar(&serveFlags.Addr, "addr", defaults.Addr, "address to listen on")
+	flags.StringVar(&serveFlags.StaticDir, "static-dir", "", "serve the frontend from this directory instead of the embedded build")
+	flags.BoolVar(&serveFlags.Dev, "dev", false, "serve the frontend from "+server.DevStaticDir+" for development")
+	flags.StringVar(&serveFlags.TLSCert, "tls-cert", "", "TLS certificate file (PEM)")
+	flags.StringVar(&serveFlags.TLSKey, "tls-key", "", "TLS private key file (PEM)")
+	flags.BoolVar(&serveFlags.SelfSigned, "self-signed", false, "generate a self-signed certificate if the TLS files do not exist")
+	flags.BoolVar(&serveFlags.RemoteConfig, "remote-config", false, "serve the config API on an address that is not loopback")
+	flags.DurationVar(&serveFlags.ReadTimeout, "read-timeout", defaults.ReadTimeout, "maximum time to read a request")
+	flags.DurationVar(&serveFlags.WriteTimeout, "write-timeout", defaults.WriteTimeout, "maximum time to write a response")
+	flags.DurationVar(&serveFlags.ShutdownTimeout, "shutdown-timeout", defaults.ShutdownTimeout, "time allowed for requests to finish on shutdown")
+	serveCmd.MarkFlagDirname("static-dir")
+	serveCmd.MarkFlagFilename("tls-cert", "pem", "crt")
+	serveCmd.MarkFlagFilename("tls-key", "pem", "key")
 	rootCmd.AddCommand(serveCmd)
 }
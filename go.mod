@@ -4,14 +4,20 @@ go 1.25
 
 require (
 	github.com/adrg/xdg v0.5.3
+	github.com/fxamacker/cbor/v2 v2.9.0
+	github.com/hashicorp/hcl v1.0.0
 	github.com/spf13/cobra v1.10.1
+	github.com/vmihailenco/msgpack/v5 v5.4.1
 	gopkg.in/ini.v1 v1.67.0
-	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
+	howett.net/plist v1.0.1
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
 	github.com/stretchr/testify v1.11.1 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
 	golang.org/x/sys v0.37.0 // indirect
 )
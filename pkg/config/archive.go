@@ -0,0 +1,726 @@
+package config
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat is the container format of an exported configuration.
+type ArchiveFormat string
+
+// Archive formats supported by Export.
+const (
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	ArchiveZip   ArchiveFormat = "zip"
+)
+
+// ArchiveFormatFor returns the archive format for a file name ending in
+// ".tar.gz", ".tgz" or ".zip".
+func ArchiveFormatFor(name string) (ArchiveFormat, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return ArchiveTarGz, nil
+	case strings.HasSuffix(lower, ".zip"):
+		return ArchiveZip, nil
+	}
+	return "", fmt.Errorf("unsupported archive %s, use .tar.gz, .tgz or .zip", filepath.Base(name))
+}
+
+// Archive layout.
+const (
+	archiveVersion     = 1
+	archiveManifest    = "manifest.json"
+	archiveConfigFile  = "config.json"
+	archiveFilesPrefix = "files/"
+	// maxArchiveEntry limits the size of a single file read from an archive.
+	maxArchiveEntry = 32 << 20
+)
+
+// ArchiveManifest describes the content of an exported configuration. It is
+// stored as manifest.json at the root of the archive.
+type ArchiveManifest struct {
+	// Version is the version of the archive layout.
+	Version int `json:"version"`
+	// Created is the time the archive was written.
+	Created time.Time `json:"created"`
+	// Paths are the paths of the exporting installation, used to rewrite
+	// directory fields on import.
+	Paths []ResolvedPath `json:"paths"`
+	// Files lists every other file in the archive.
+	Files []ArchiveFile `json:"files"`
+}
+
+// ArchiveFile is a file listed in an ArchiveManifest.
+type ArchiveFile struct {
+	// Name is the slash-separated name of the file in the archive:
+	// "config.json", or "files/" followed by the path of an auxiliary file
+	// relative to the config directory.
+	Name string `json:"name"`
+	// Size is the size of the file in bytes.
+	Size int64 `json:"size"`
+	// SHA256 is the hex-encoded SHA-256 checksum of the file.
+	SHA256 string `json:"sha256"`
+}
+
+// Export writes config.json and every auxiliary file in the config directory,
+// such as those written by SaveStructAs and SaveKeyValues, to w as an
+// archive with a manifest of checksums. Hidden files, lock files and ".bak"
+// backups are left out.
+//
+// Example:
+//
+//	out, err := os.Create("profile.tar.gz")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer out.Close()
+//	if _, err := cfg.Export(out, config.ArchiveTarGz); err != nil {
+//		log.Fatal(err)
+//	}
+func (s *Service) Export(w io.Writer, format ArchiveFormat) (*ArchiveManifest, error) {
+	if format != ArchiveTarGz && format != ArchiveZip {
+		return nil, fmt.Errorf("unsupported archive format %q", format)
+	}
+	files, err := s.exportFiles()
+	if err != nil {
+		return nil, err
+	}
+	manifest := &ArchiveManifest{
+		Version: archiveVersion,
+		Created: time.Now().UTC().Truncate(time.Second),
+		Paths:   s.Paths(),
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sum := sha256.Sum256(files[name])
+		manifest.Files = append(manifest.Files, ArchiveFile{
+			Name:   name,
+			Size:   int64(len(files[name])),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	entries := append([]string{archiveManifest}, names...)
+	files[archiveManifest] = data
+	if format == ArchiveZip {
+		err = writeZip(w, entries, files, manifest.Created)
+	} else {
+		err = writeTarGz(w, entries, files, manifest.Created)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	return manifest, nil
+}
+
+// exportFiles reads the files to export, keyed by their name in the archive.
+func (s *Service) exportFiles() (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	data, err := os.ReadFile(s.ConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	files[archiveConfigFile] = data
+
+	err = filepath.WalkDir(s.ConfigDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if p != s.ConfigDir && strings.HasPrefix(name, ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || p == s.ConfigPath ||
+			strings.HasSuffix(name, ".lock") || strings.HasSuffix(name, ".bak") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.ConfigDir, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[archiveFilesPrefix+filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config directory: %w", err)
+	}
+	return files, nil
+}
+
+// writeTarGz writes the named files to w as a gzip-compressed tar archive.
+func writeTarGz(w io.Writer, names []string, files map[string][]byte, modTime time.Time) error {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	for _, name := range names {
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(files[name])),
+			ModTime:  modTime,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeZip writes the named files to w as a zip archive.
+func writeZip(w io.Writer, names []string, files map[string][]byte, modTime time.Time) error {
+	zw := zip.NewWriter(w)
+	for _, name := range names {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime})
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// ConflictMode decides what Import does with files that already exist.
+type ConflictMode string
+
+// Conflict modes accepted by Import.
+const (
+	// ConflictMerge merges imported values into existing files. Values from
+	// the archive win, except that config.json values still at their
+	// built-in default in the archive do not replace local changes.
+	ConflictMerge ConflictMode = "merge"
+	// ConflictOverwrite replaces existing files with those from the archive.
+	ConflictOverwrite ConflictMode = "overwrite"
+	// ConflictSkip keeps existing files and only creates missing ones.
+	ConflictSkip ConflictMode = "skip"
+)
+
+// ImportOptions controls Import.
+type ImportOptions struct {
+	// Conflict decides what happens to files that already exist. The zero
+	// value is ConflictMerge.
+	Conflict ConflictMode
+	// KeepPaths imports the directory fields of config.json as they are,
+	// instead of rewriting paths of the exporting installation to the
+	// matching local paths.
+	KeepPaths bool
+}
+
+// Import actions reported in ImportedFile.Action.
+const (
+	ImportCreated     = "created"
+	ImportOverwritten = "overwritten"
+	ImportMerged      = "merged"
+	ImportSkipped     = "skipped"
+	ImportUnchanged   = "unchanged"
+)
+
+// ImportedFile reports what Import did with a file from the archive.
+type ImportedFile struct {
+	// Path is the local path of the file.
+	Path string `json:"path" yaml:"path"`
+	// Action is one of ImportCreated, ImportOverwritten, ImportMerged,
+	// ImportSkipped or ImportUnchanged.
+	Action string `json:"action" yaml:"action"`
+}
+
+// Import restores an archive written by Export. The archive, a tar.gz or zip
+// file recognised from its content, is checked against its manifest and the
+// config.json it holds is validated before anything is written, so a
+// damaged archive or an invalid configuration changes nothing.
+//
+// Paths in config.json under the home, data or cache directory of the
+// exporting installation are rewritten to the same place under the local
+// directory unless opts.KeepPaths is set, so a workspace in ~/lethean/projects
+// on one machine is ~/lethean/projects on the other. Existing files are handled
+// according to opts.Conflict.
+//
+// Example:
+//
+//	f, err := os.Open("profile.tar.gz")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer f.Close()
+//	info, err := f.Stat()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	files, err := cfg.Import(f, info.Size(), config.ImportOptions{Conflict: config.ConflictMerge})
+func (s *Service) Import(r io.ReaderAt, size int64, opts ImportOptions) ([]ImportedFile, error) {
+	mode := opts.Conflict
+	if mode == "" {
+		mode = ConflictMerge
+	}
+	if mode != ConflictMerge && mode != ConflictOverwrite && mode != ConflictSkip {
+		return nil, fmt.Errorf("unknown conflict mode %q, use overwrite, merge or skip", opts.Conflict)
+	}
+	manifest, files, err := readArchive(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// Plan every change first, so that nothing is written if any file
+	// cannot be imported.
+	type change struct {
+		path string
+		data []byte
+	}
+	var report []ImportedFile
+	var changes []change
+	var values map[string]interface{}
+	configAction := ""
+	for _, f := range manifest.Files {
+		if f.Name == archiveConfigFile {
+			values, configAction, err = s.importValues(files[f.Name], manifest, mode, opts.KeepPaths)
+			if err != nil {
+				return nil, err
+			}
+			report = append(report, ImportedFile{Path: s.ConfigPath, Action: configAction})
+			continue
+		}
+		dst := filepath.Join(s.ConfigDir, filepath.FromSlash(strings.TrimPrefix(f.Name, archiveFilesPrefix)))
+		if s.isConfigFile(dst) {
+			return nil, fmt.Errorf("cannot import %s: it would replace %s", f.Name, archiveConfigFile)
+		}
+		data, action, err := importFile(dst, files[f.Name], mode)
+		if err != nil {
+			return nil, fmt.Errorf("cannot import %s: %w", f.Name, err)
+		}
+		report = append(report, ImportedFile{Path: dst, Action: action})
+		if data != nil {
+			changes = append(changes, change{dst, data})
+		}
+	}
+
+	for _, c := range changes {
+		if err := os.MkdirAll(filepath.Dir(c.path), os.ModePerm); err != nil {
+			return nil, err
+		}
+		if err := writeFileAtomic(c.path, c.data, 0644); err != nil {
+			return nil, err
+		}
+	}
+	if values != nil && configAction != ImportSkipped && configAction != ImportUnchanged {
+		if err := s.Apply(values); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// importValues decodes the config.json of an archive and returns the values
+// to apply and the action taken, or nil values if nothing changes.
+func (s *Service) importValues(data []byte, manifest *ArchiveManifest, mode ConflictMode, keepPaths bool) (map[string]interface{}, string, error) {
+	imported, err := decodeConfigFile(data, Options{CommentedJSON: true})
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot import %s: %w", archiveConfigFile, err)
+	}
+	if !keepPaths {
+		imported = rewritePaths(imported, pathRewrites(manifest.Paths, s.Paths())).(map[string]interface{})
+	}
+	if err := s.Validate(imported); err != nil {
+		return nil, "", fmt.Errorf("cannot import %s: %w", archiveConfigFile, err)
+	}
+
+	action := ImportOverwritten
+	switch mode {
+	case ConflictSkip:
+		if _, err := os.Stat(s.ConfigPath); err == nil {
+			return nil, ImportSkipped, nil
+		}
+		action = ImportCreated
+	case ConflictMerge:
+		_, set, err := s.marshalFile()
+		if err != nil {
+			return nil, "", err
+		}
+		current, err := Canonicalize(set)
+		if err != nil {
+			return nil, "", err
+		}
+		merged := current.(map[string]interface{})
+		for key, value := range imported {
+			fieldVal, name, _ := s.field(key)
+			v, _ := decodeSetting(name, fieldVal.Type(), value)
+			if reflect.DeepEqual(v.Interface(), s.defaultValue(name, fieldVal.Type()).Interface()) {
+				continue
+			}
+			merged[name] = value
+		}
+		imported = merged
+		action = ImportMerged
+	}
+	decoded, _ := s.decodeSettings(imported)
+	if s.sameValues(decoded) {
+		return nil, ImportUnchanged, nil
+	}
+	return imported, action, nil
+}
+
+// sameValues reports whether applying values would leave every persistent
+// field unchanged.
+func (s *Service) sameValues(values map[string]reflect.Value) bool {
+	for _, key := range s.Keys() {
+		fieldVal, _, _ := s.field(key)
+		want := s.defaultValue(key, fieldVal.Type())
+		if v, ok := values[key]; ok {
+			want = v
+		}
+		if !reflect.DeepEqual(fieldVal.Interface(), want.Interface()) {
+			return false
+		}
+	}
+	return true
+}
+
+// isConfigFile reports whether path is the file of the configuration,
+// which only the config.json entry of an archive may replace.
+func (s *Service) isConfigFile(path string) bool {
+	if filepath.Clean(path) == filepath.Clean(s.ConfigPath) {
+		return true
+	}
+	a, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	b, err := os.Stat(s.ConfigPath)
+	return err == nil && os.SameFile(a, b)
+}
+
+// importFile returns the content to write to dst for a file imported from
+// an archive and the action taken, or nil content if dst is left alone.
+func importFile(dst string, data []byte, mode ConflictMode) ([]byte, string, error) {
+	existing, err := os.ReadFile(dst)
+	if os.IsNotExist(err) {
+		return data, ImportCreated, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	switch {
+	case bytes.Equal(existing, data):
+		return nil, ImportUnchanged, nil
+	case mode == ConflictSkip:
+		return nil, ImportSkipped, nil
+	case mode == ConflictOverwrite:
+		return data, ImportOverwritten, nil
+	}
+
+	format, err := resolveFormat(dst)
+	if err != nil {
+		return nil, "", err
+	}
+	local, err := format.Decode(bytes.NewReader(existing))
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot merge into %s: %w", dst, err)
+	}
+	incoming, err := format.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	var buf bytes.Buffer
+	if err := format.Encode(&buf, mergeMaps(local, incoming)); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), ImportMerged, nil
+}
+
+// mergeMaps merges src into dst, recursing into maps present in both, and
+// returns dst.
+func mergeMaps(dst, src map[string]interface{}) map[string]interface{} {
+	for key, value := range src {
+		if sub, ok := value.(map[string]interface{}); ok {
+			if existing, ok := dst[key].(map[string]interface{}); ok {
+				dst[key] = mergeMaps(existing, sub)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+	return dst
+}
+
+// pathRewrite replaces the path from, or a path under it, with to.
+type pathRewrite struct {
+	from, to string
+}
+
+// rewriteRoots names the paths that locate an installation on a machine.
+// Every other directory is found under one of them by default, and a
+// directory moved elsewhere is kept as it is.
+var rewriteRoots = map[string]bool{"UserHomeDir": true, "RootDir": true, "CacheDir": true}
+
+// pathRewrites pairs the root paths of an exporting installation with the
+// local paths of the same name, longest first so that the most specific
+// matches.
+func pathRewrites(exported, local []ResolvedPath) []pathRewrite {
+	localPaths := make(map[string]string)
+	for _, p := range local {
+		localPaths[p.Name] = p.Path
+	}
+	var rewrites []pathRewrite
+	for _, p := range exported {
+		to, ok := localPaths[p.Name]
+		if ok && rewriteRoots[p.Name] && p.Path != "" && p.Path != to {
+			rewrites = append(rewrites, pathRewrite{from: p.Path, to: to})
+		}
+	}
+	sort.SliceStable(rewrites, func(i, j int) bool {
+		return len(rewrites[i].from) > len(rewrites[j].from)
+	})
+	return rewrites
+}
+
+// rewritePaths returns v with every string that is one of the exported
+// root paths, or lies under one, rewritten to the matching local path.
+func rewritePaths(v interface{}, rewrites []pathRewrite) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[key] = rewritePaths(value, rewrites)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, value := range v {
+			out[i] = rewritePaths(value, rewrites)
+		}
+		return out
+	case string:
+		for _, r := range rewrites {
+			if v == r.from {
+				return r.to
+			}
+			rest := strings.TrimPrefix(v, r.from)
+			if rest != v && (rest[0] == '/' || rest[0] == '\\') {
+				// The archive may come from a system with other separators.
+				return filepath.Join(r.to, filepath.FromSlash(strings.ReplaceAll(rest, `\`, "/")))
+			}
+		}
+	}
+	return v
+}
+
+// LoadSnapshot returns the configuration values held in the config.json of
+// an archive written by Export, after checking the archive against its
+// manifest. It is used to compare a configuration with an earlier export.
+func LoadSnapshot(path string) (map[string]interface{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	_, files, err := readArchive(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	data, ok := files[archiveConfigFile]
+	if !ok {
+		return nil, fmt.Errorf("%s: archive has no %s", filepath.Base(path), archiveConfigFile)
+	}
+	values, err := decodeConfigFile(data, Options{CommentedJSON: true})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return values, nil
+}
+
+// readArchive reads a tar.gz or zip archive written by Export and checks
+// its files against the manifest.
+func readArchive(r io.ReaderAt, size int64) (*ArchiveManifest, map[string][]byte, error) {
+	magic := make([]byte, 4)
+	if _, err := r.ReadAt(magic, 0); err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	var files map[string][]byte
+	var err error
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		files, err = readTarGz(io.NewSectionReader(r, 0, size))
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		files, err = readZip(r, size)
+	default:
+		return nil, nil, errors.New("not a tar.gz or zip archive")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	data, ok := files[archiveManifest]
+	if !ok {
+		return nil, nil, fmt.Errorf("archive has no %s", archiveManifest)
+	}
+	var manifest ArchiveManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("invalid %s: %w", archiveManifest, err)
+	}
+	if manifest.Version != archiveVersion {
+		return nil, nil, fmt.Errorf("unsupported archive version %d", manifest.Version)
+	}
+	delete(files, archiveManifest)
+
+	for _, f := range manifest.Files {
+		if err := checkArchiveName(f.Name); err != nil {
+			return nil, nil, err
+		}
+		data, ok := files[f.Name]
+		if !ok {
+			return nil, nil, fmt.Errorf("archive is missing %s", f.Name)
+		}
+		sum := sha256.Sum256(data)
+		if int64(len(data)) != f.Size || hex.EncodeToString(sum[:]) != strings.ToLower(f.SHA256) {
+			return nil, nil, fmt.Errorf("checksum mismatch for %s", f.Name)
+		}
+	}
+	if len(files) != len(manifest.Files) {
+		for name := range files {
+			if !manifestLists(&manifest, name) {
+				return nil, nil, fmt.Errorf("%s is not listed in %s", name, archiveManifest)
+			}
+		}
+	}
+	return &manifest, files, nil
+}
+
+// manifestLists reports whether m lists the file name.
+func manifestLists(m *ArchiveManifest, name string) bool {
+	for _, f := range m.Files {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkArchiveName rejects names that Import would not write inside the
+// config directory.
+func checkArchiveName(name string) error {
+	if name == archiveConfigFile {
+		return nil
+	}
+	rel := strings.TrimPrefix(name, archiveFilesPrefix)
+	if rel == name || rel == "" || strings.Contains(rel, `\`) || path.Clean(rel) != rel ||
+		path.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return fmt.Errorf("invalid file name %q in archive", name)
+	}
+	return nil
+}
+
+// readTarGz reads every regular file of a gzip-compressed tar archive.
+func readTarGz(r io.Reader) (map[string][]byte, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+	files := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg:
+		default:
+			return nil, fmt.Errorf("%s is not a regular file", hdr.Name)
+		}
+		if err := addArchiveEntry(files, hdr.Name, tr); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readZip reads every file of a zip archive.
+func readZip(r io.ReaderAt, size int64) (map[string][]byte, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s is not a regular file", f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		err = addArchiveEntry(files, f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// addArchiveEntry reads an archive entry into files, refusing duplicates
+// and entries larger than maxArchiveEntry.
+func addArchiveEntry(files map[string][]byte, name string, r io.Reader) error {
+	if _, dup := files[name]; dup {
+		return fmt.Errorf("duplicate entry %s", name)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxArchiveEntry+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxArchiveEntry {
+		return fmt.Errorf("%s is too large", name)
+	}
+	files[name] = data
+	return nil
+}
@@ -0,0 +1,315 @@
+package config
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// exportTestProfile sets up an installation with a customised configuration
+// and auxiliary files, exports it and returns the archive and its paths.
+func exportTestProfile(t *testing.T, format ArchiveFormat) ([]byte, *Service) {
+	t.Helper()
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	s, err := New()
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := s.Set("language", "fr"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if err := s.Set("workspaceDir", filepath.Join(s.UserHomeDir, "projects")); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if err := s.SaveKeyValues("database.yaml", map[string]interface{}{"host": "db.example.com", "pool": map[string]interface{}{"size": 10}}); err != nil {
+		t.Fatalf("SaveKeyValues() failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(s.ConfigDir, "profiles"), 0755); err != nil {
+		t.Fatalf("MkdirAll() failed: %v", err)
+	}
+	if err := s.SaveKeyValues("profiles/work.ini", map[string]interface{}{"name": "work"}); err != nil {
+		t.Fatalf("SaveKeyValues() failed: %v", err)
+	}
+	for _, name := range []string{"app.lock", ".config.json.123.tmp", "config.json.bak"} {
+		if err := os.WriteFile(filepath.Join(s.ConfigDir, name), []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	manifest, err := s.Export(&buf, format)
+	if err != nil {
+		t.Fatalf("Export() failed: %v", err)
+	}
+	var names []string
+	for _, f := range manifest.Files {
+		names = append(names, f.Name)
+	}
+	expected := []string{"config.json", "files/database.yaml", "files/profiles/work.ini"}
+	if !reflect.DeepEqual(expected, names) {
+		t.Fatalf("Expected files %v, got %v", expected, names)
+	}
+	return buf.Bytes(), s
+}
+
+func TestArchiveGood(t *testing.T) {
+	for _, format := range []ArchiveFormat{ArchiveTarGz, ArchiveZip} {
+		t.Run(string(format)+" restores a profile on another machine", func(t *testing.T) {
+			archive, exported := exportTestProfile(t, format)
+
+			_, cleanup := setupTestEnv(t)
+			defer cleanup()
+			s, err := New()
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			files, err := s.Import(bytes.NewReader(archive), int64(len(archive)), ImportOptions{Conflict: ConflictOverwrite})
+			if err != nil {
+				t.Fatalf("Import() failed: %v", err)
+			}
+			expected := []ImportedFile{
+				{Path: s.ConfigPath, Action: ImportOverwritten},
+				{Path: filepath.Join(s.ConfigDir, "database.yaml"), Action: ImportCreated},
+				{Path: filepath.Join(s.ConfigDir, "profiles", "work.ini"), Action: ImportCreated},
+			}
+			if !reflect.DeepEqual(expected, files) {
+				t.Errorf("Unexpected report.\nExpected: %v\nGot: %v", expected, files)
+			}
+
+			if s.Language != "fr" {
+				t.Errorf("Expected language fr, got %q", s.Language)
+			}
+			if s.UserHomeDir == exported.UserHomeDir {
+				t.Fatal("Both installations use the same home directory")
+			}
+			if want := filepath.Join(s.UserHomeDir, "projects"); s.WorkspaceDir != want {
+				t.Errorf("Expected workspaceDir to be rewritten to %s, got %s", want, s.WorkspaceDir)
+			}
+			if s.DataDir != filepath.Join(s.UserHomeDir, "data") {
+				t.Errorf("Expected the local data directory, got %s", s.DataDir)
+			}
+			db, err := s.LoadKeyValues("database.yaml")
+			if err != nil || db["host"] != "db.example.com" {
+				t.Errorf("Auxiliary file was not restored: %v (%v)", db, err)
+			}
+		})
+	}
+
+	t.Run("Conflict modes", func(t *testing.T) {
+		archive, _ := exportTestProfile(t, ArchiveTarGz)
+
+		testCases := []struct {
+			mode     ConflictMode
+			language string
+			route    string
+			db       map[string]interface{}
+		}{
+			{ConflictSkip, "de", "/local", map[string]interface{}{"host": "localhost", "port": int64(5432)}},
+			{ConflictOverwrite, "fr", "/", map[string]interface{}{"host": "db.example.com", "pool": map[string]interface{}{"size": int64(10)}}},
+			{ConflictMerge, "fr", "/local", map[string]interface{}{"host": "db.example.com", "port": int64(5432), "pool": map[string]interface{}{"size": int64(10)}}},
+		}
+		for _, tc := range testCases {
+			t.Run(string(tc.mode), func(t *testing.T) {
+				_, cleanup := setupTestEnv(t)
+				defer cleanup()
+				s, err := New()
+				if err != nil {
+					t.Fatalf("New() failed: %v", err)
+				}
+				if err := s.Set("language", "de"); err != nil {
+					t.Fatalf("Set() failed: %v", err)
+				}
+				if err := s.Set("default_route", "/local"); err != nil {
+					t.Fatalf("Set() failed: %v", err)
+				}
+				if err := s.SaveKeyValues("database.yaml", map[string]interface{}{"host": "localhost", "port": 5432}); err != nil {
+					t.Fatalf("SaveKeyValues() failed: %v", err)
+				}
+
+				if _, err := s.Import(bytes.NewReader(archive), int64(len(archive)), ImportOptions{Conflict: tc.mode}); err != nil {
+					t.Fatalf("Import() failed: %v", err)
+				}
+				reloaded, err := New()
+				if err != nil {
+					t.Fatalf("New() failed: %v", err)
+				}
+				if reloaded.Language != tc.language || reloaded.DefaultRoute != tc.route {
+					t.Errorf("Expected language %q and route %q, got %q and %q", tc.language, tc.route, reloaded.Language, reloaded.DefaultRoute)
+				}
+				db, err := s.LoadKeyValues("database.yaml")
+				if err != nil {
+					t.Fatalf("LoadKeyValues() failed: %v", err)
+				}
+				if !reflect.DeepEqual(tc.db, db) {
+					t.Errorf("Expected %v, got %v", tc.db, db)
+				}
+			})
+		}
+	})
+
+	t.Run("Importing twice changes nothing", func(t *testing.T) {
+		archive, _ := exportTestProfile(t, ArchiveZip)
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		if _, err := s.Import(bytes.NewReader(archive), int64(len(archive)), ImportOptions{}); err != nil {
+			t.Fatalf("Import() failed: %v", err)
+		}
+		files, err := s.Import(bytes.NewReader(archive), int64(len(archive)), ImportOptions{})
+		if err != nil {
+			t.Fatalf("Import() failed: %v", err)
+		}
+		for _, f := range files {
+			if f.Action != ImportUnchanged {
+				t.Errorf("Expected %s to be unchanged, got %s", f.Path, f.Action)
+			}
+		}
+	})
+
+	t.Run("ArchiveFormatFor", func(t *testing.T) {
+		for name, expected := range map[string]ArchiveFormat{"a.tar.gz": ArchiveTarGz, "A.TGZ": ArchiveTarGz, "b.zip": ArchiveZip} {
+			if got, err := ArchiveFormatFor(name); err != nil || got != expected {
+				t.Errorf("ArchiveFormatFor(%q) = %q, %v", name, got, err)
+			}
+		}
+	})
+}
+
+// buildZip writes a zip archive with the given entries, in order.
+func buildZip(t *testing.T, entries [][2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.Create(e[0])
+		if err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+		w.Write([]byte(e[1]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// testManifest returns a manifest listing a single file.
+func testManifest(t *testing.T, name, content string) string {
+	t.Helper()
+	sum := sha256.Sum256([]byte(content))
+	data, err := json.Marshal(ArchiveManifest{
+		Version: 1,
+		Files:   []ArchiveFile{{Name: name, Size: int64(len(content)), SHA256: hex.EncodeToString(sum[:])}},
+	})
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+	return string(data)
+}
+
+func TestArchiveBad(t *testing.T) {
+	archive, _ := exportTestProfile(t, ArchiveZip)
+
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() failed: %v", err)
+	}
+	entries := make(map[string]string)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		var b bytes.Buffer
+		b.ReadFrom(rc)
+		rc.Close()
+		entries[f.Name] = b.String()
+	}
+	manifest := entries["manifest.json"]
+
+	testCases := []struct {
+		name     string
+		archive  []byte
+		expected string
+	}{
+		{"not an archive", []byte("hello"), "not a tar.gz or zip archive"},
+		{"no manifest", buildZip(t, [][2]string{{"config.json", "{}"}}), "archive has no manifest.json"},
+		{"tampered file", buildZip(t, [][2]string{
+			{"manifest.json", manifest},
+			{"config.json", strings.Replace(entries["config.json"], "fr", "de", 1)},
+			{"files/database.yaml", entries["files/database.yaml"]},
+			{"files/profiles/work.ini", entries["files/profiles/work.ini"]},
+		}), "checksum mismatch for config.json"},
+		{"missing file", buildZip(t, [][2]string{
+			{"manifest.json", manifest},
+			{"config.json", entries["config.json"]},
+		}), "archive is missing files/database.yaml"},
+		{"unlisted file", buildZip(t, [][2]string{
+			{"manifest.json", manifest},
+			{"config.json", entries["config.json"]},
+			{"files/database.yaml", entries["files/database.yaml"]},
+			{"files/profiles/work.ini", entries["files/profiles/work.ini"]},
+			{"files/extra.yaml", "a: 1"},
+		}), "files/extra.yaml is not listed in manifest.json"},
+		{"path outside the config directory", buildZip(t, [][2]string{
+			{"manifest.json", testManifest(t, "files/../../evil.json", "{}")},
+			{"files/../../evil.json", "{}"},
+		}), `invalid file name "files/../../evil.json" in archive`},
+		{"auxiliary file replacing config.json", buildZip(t, [][2]string{
+			{"manifest.json", testManifest(t, "files/config.json", `{"language": "de"}`)},
+			{"files/config.json", `{"language": "de"}`},
+		}), "cannot import files/config.json: it would replace config.json"},
+		{"invalid configuration", buildZip(t, [][2]string{
+			{"manifest.json", testManifest(t, "config.json", `{"language": ""}`)},
+			{"config.json", `{"language": ""}`},
+		}), "cannot import config.json: invalid configuration: language: must not be empty"},
+	}
+
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+	s, err := New()
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	before, err := os.ReadFile(s.ConfigPath)
+	if err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := s.Import(bytes.NewReader(tc.archive), int64(len(tc.archive)), ImportOptions{})
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+
+	t.Run("Unknown conflict mode", func(t *testing.T) {
+		if _, err := s.Import(bytes.NewReader(archive), int64(len(archive)), ImportOptions{Conflict: "replace"}); err == nil {
+			t.Error("Expected an error for an unknown conflict mode")
+		}
+	})
+
+	after, err := os.ReadFile(s.ConfigPath)
+	if err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("A failed Import() changed config.json")
+	}
+	if _, err := os.Stat(filepath.Join(s.ConfigDir, "database.yaml")); !os.IsNotExist(err) {
+		t.Error("A failed Import() wrote an auxiliary file")
+	}
+	if _, err := ArchiveFormatFor("profile.rar"); err == nil {
+		t.Error("Expected an error for an unsupported archive name")
+	}
+}
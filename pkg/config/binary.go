@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// cborEncMode encodes with the RFC 8949 core deterministic rules, so equal
+// maps always produce identical bytes. Times are written as tagged RFC 3339
+// strings so they load back as time.Time.
+var cborEncMode = func() cbor.EncMode {
+	opts := cbor.CoreDetEncOptions()
+	opts.Time = cbor.TimeRFC3339Nano
+	opts.TimeTag = cbor.EncTagRequired
+	mode, err := opts.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}()
+
+// cborDecMode decodes maps with string keys as map[string]interface{}.
+var cborDecMode = func() cbor.DecMode {
+	mode, err := cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]interface{}{}),
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}()
+
+// CBORFormat implements the ConfigFormat interface for CBOR (.cbor) files,
+// a compact binary encoding suited to large cached values. Encoding is
+// deterministic: map keys are sorted and integers use their shortest form.
+// Values load in the canonical value model (see Canonicalize), with times as
+// time.Time and byte strings as base64 strings.
+type CBORFormat struct{}
+
+// Decode reads a CBOR document from r and decodes it into a map.
+func (f *CBORFormat) Decode(r io.Reader) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	if err := cborDecMode.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("cbor: %w", err)
+	}
+	return canonicalDocument("cbor", result)
+}
+
+// Encode writes the provided map to w as CBOR.
+func (f *CBORFormat) Encode(w io.Writer, data map[string]interface{}) error {
+	out, err := cborEncMode.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("cbor: %w", err)
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// Load reads a CBOR file from the given path and decodes it into a map.
+func (f *CBORFormat) Load(path string) (map[string]interface{}, error) {
+	return loadFile(path, f.Decode)
+}
+
+// Save encodes the provided map as CBOR and writes it to the given path.
+func (f *CBORFormat) Save(path string, data map[string]interface{}) error {
+	return saveFile(path, data, f.Encode)
+}
+
+// MsgpackFormat implements the ConfigFormat interface for MessagePack
+// (.msgpack) files. Encoding is deterministic: map keys are sorted and
+// integers use their shortest form. Values load with the same types as
+// CBORFormat.
+type MsgpackFormat struct{}
+
+// Decode reads a MessagePack document from r and decodes it into a map.
+func (f *MsgpackFormat) Decode(r io.Reader) (map[string]interface{}, error) {
+	dec := msgpack.NewDecoder(r)
+	var result map[string]interface{}
+	if err := dec.Decode(&result); err != nil {
+		return nil, fmt.Errorf("msgpack: %w", err)
+	}
+	result, err := canonicalDocument("msgpack", result)
+	if err != nil {
+		return nil, err
+	}
+	timesToUTC(result)
+	return result, nil
+}
+
+// Encode writes the provided map to w as MessagePack.
+func (f *MsgpackFormat) Encode(w io.Writer, data map[string]interface{}) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetSortMapKeys(true)
+	enc.UseCompactInts(true)
+	if err := enc.Encode(data); err != nil {
+		return fmt.Errorf("msgpack: %w", err)
+	}
+	return nil
+}
+
+// Load reads a MessagePack file from the given path and decodes it into a
+// map.
+func (f *MsgpackFormat) Load(path string) (map[string]interface{}, error) {
+	return loadFile(path, f.Decode)
+}
+
+// Save encodes the provided map as MessagePack and writes it to the given
+// path.
+func (f *MsgpackFormat) Save(path string, data map[string]interface{}) error {
+	return saveFile(path, data, f.Encode)
+}
+
+// timesToUTC converts the times in v to UTC, in place. The MessagePack
+// decoder returns times in the local time zone, while they are written in
+// UTC.
+func timesToUTC(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			val[k] = timesToUTC(child)
+		}
+	case []interface{}:
+		for i, child := range val {
+			val[i] = timesToUTC(child)
+		}
+	case time.Time:
+		return val.UTC()
+	}
+	return v
+}
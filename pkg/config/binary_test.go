@@ -0,0 +1,168 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// binaryFormats lists the binary formats under test by file extension.
+var binaryFormats = []struct {
+	ext    string
+	format ConfigFormat
+}{
+	{".cbor", &CBORFormat{}},
+	{".msgpack", &MsgpackFormat{}},
+}
+
+func TestBinaryFormatsGood(t *testing.T) {
+	data := map[string]interface{}{
+		"name":     "cache",
+		"count":    int64(1 << 40),
+		"negative": int64(-12),
+		"ratio":    0.125,
+		"enabled":  true,
+		"missing":  nil,
+		"updated":  time.Date(2024, 5, 6, 7, 8, 9, 10, time.UTC),
+		"blob":     []byte{0, 1, 2, 255},
+		"tags":     []interface{}{"a", int64(2), 3.5},
+		"nested": map[string]interface{}{
+			"deep": map[string]interface{}{"key": "value"},
+		},
+	}
+
+	for _, tc := range binaryFormats {
+		t.Run(tc.ext+" round trips typed values", func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache"+tc.ext)
+			if err := tc.format.Save(path, data); err != nil {
+				t.Fatalf("Save() failed: %v", err)
+			}
+			got, err := tc.format.Load(path)
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			// Byte strings load as base64 strings.
+			expected := make(map[string]interface{}, len(data))
+			for k, v := range data {
+				expected[k] = v
+			}
+			expected["blob"] = "AAEC/w=="
+			if !reflect.DeepEqual(expected, got) {
+				t.Errorf("Round trip mismatch.\nExpected: %#v\nGot: %#v", expected, got)
+			}
+		})
+
+		t.Run(tc.ext+" encodes deterministically", func(t *testing.T) {
+			dir := t.TempDir()
+			var first []byte
+			for i := 0; i < 10; i++ {
+				path := filepath.Join(dir, fmt.Sprintf("cache%d%s", i, tc.ext))
+				if err := tc.format.Save(path, data); err != nil {
+					t.Fatalf("Save() failed: %v", err)
+				}
+				out, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("Failed to read file: %v", err)
+				}
+				if first == nil {
+					first = out
+				} else if !bytes.Equal(first, out) {
+					t.Fatalf("Encoding %d differs from the first one", i)
+				}
+			}
+		})
+	}
+}
+
+func TestBinaryFormatsBad(t *testing.T) {
+	for _, tc := range binaryFormats {
+		t.Run(tc.ext+" truncated file", func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache"+tc.ext)
+			if err := tc.format.Save(path, map[string]interface{}{"key": "a long enough value"}); err != nil {
+				t.Fatalf("Save() failed: %v", err)
+			}
+			out, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read file: %v", err)
+			}
+			if err := os.WriteFile(path, out[:len(out)/2], 0644); err != nil {
+				t.Fatalf("Failed to truncate file: %v", err)
+			}
+			if _, err := tc.format.Load(path); err == nil {
+				t.Error("Expected an error for a truncated file, but got nil")
+			}
+		})
+
+		t.Run(tc.ext+" unsupported value", func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache"+tc.ext)
+			if err := tc.format.Save(path, map[string]interface{}{"ch": make(chan int)}); err == nil {
+				t.Error("Expected an error for an unsupported value, but got nil")
+			}
+		})
+	}
+}
+
+// benchmarkData builds a cache-like map with n entries, each holding a mix of
+// strings, numbers, lists and nested maps.
+func benchmarkData(n int) map[string]interface{} {
+	data := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		data[fmt.Sprintf("item-%04d", i)] = map[string]interface{}{
+			"id":      int64(i),
+			"title":   fmt.Sprintf("Cached item number %d", i),
+			"score":   float64(i) * 1.5,
+			"active":  i%2 == 0,
+			"tags":    []interface{}{"alpha", "beta", "gamma"},
+			"updated": "2024-01-02T03:04:05Z",
+			"meta": map[string]interface{}{
+				"etag":  fmt.Sprintf("%08x", i*2654435761),
+				"hits":  int64(i * 7),
+				"owner": "user@example.com",
+			},
+		}
+	}
+	return data
+}
+
+func BenchmarkFormats(b *testing.B) {
+	data := benchmarkData(1000)
+	formats := []struct {
+		ext    string
+		format ConfigFormat
+	}{
+		{".json", &JSONFormat{}},
+		{".cbor", &CBORFormat{}},
+		{".msgpack", &MsgpackFormat{}},
+	}
+
+	for _, tc := range formats {
+		path := filepath.Join(b.TempDir(), "cache"+tc.ext)
+
+		b.Run("Save"+tc.ext, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := tc.format.Save(path, data); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if info, err := os.Stat(path); err == nil {
+				b.ReportMetric(float64(info.Size()), "file-bytes")
+			}
+		})
+
+		b.Run("Load"+tc.ext, func(b *testing.B) {
+			if err := tc.format.Save(path, data); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := tc.format.Load(path); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
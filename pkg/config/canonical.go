@@ -0,0 +1,182 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Canonicalize converts a decoded value to the canonical value model that
+// every format returns from Decode and Load, so the output of one format can
+// be saved by any other:
+//
+//   - maps become map[string]interface{}; non-string keys such as the
+//     integers and booleans YAML allows are formatted as strings
+//   - lists become []interface{}
+//   - integers become int64, or float64 when they do not fit
+//   - floats become float64
+//   - byte strings become base64 strings, as encoding/json writes them
+//   - strings, bools, time.Time values and nil are kept
+//
+// Pointers and interfaces are followed. Any other value, such as a struct,
+// is an error.
+func Canonicalize(v interface{}) (interface{}, error) {
+	return canonicalValue("", v)
+}
+
+// canonicalDocument converts a decoded document to the canonical value model.
+// A nil document stays nil.
+func canonicalDocument(format string, m map[string]interface{}) (map[string]interface{}, error) {
+	if m == nil {
+		return nil, nil
+	}
+	v, err := canonicalValue("", m)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", format, err)
+	}
+	return v.(map[string]interface{}), nil
+}
+
+// unmarshalJSON is json.Unmarshal, except that numbers are decoded as
+// json.Number so that integers keep their precision until canonicalValue
+// converts them to int64.
+func unmarshalJSON(data []byte, v interface{}) error {
+	if !json.Valid(data) {
+		// Report the same syntax error as json.Unmarshal.
+		return json.Unmarshal(data, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// canonicalValue converts v, which is found at path, to the canonical value
+// model.
+func canonicalValue(path string, v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case nil, string, bool, int64, float64:
+		return v, nil
+	case time.Time:
+		return val, nil
+	case json.Number:
+		if n, err := strconv.ParseInt(string(val), 10, 64); err == nil {
+			return n, nil
+		}
+		f, err := val.Float64()
+		if err != nil {
+			return nil, canonicalError(path, "invalid number %q", val)
+		}
+		return f, nil
+	case []byte:
+		return base64.StdEncoding.EncodeToString(val), nil
+	}
+
+	rv := indirectValue(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil, nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := rv.Uint(); u <= math.MaxInt64 {
+			return int64(u), nil
+		}
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return base64.StdEncoding.EncodeToString(b), nil
+		}
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			child, err := canonicalValue(fmt.Sprintf("%s[%d]", path, i), rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			out[i] = child
+		}
+		return out, nil
+	case reflect.Map:
+		out := make(map[string]interface{}, rv.Len())
+		for _, k := range rv.MapKeys() {
+			key, err := canonicalKey(path, k)
+			if err != nil {
+				return nil, err
+			}
+			child := joinPath(path, key)
+			if _, dup := out[key]; dup {
+				return nil, canonicalError(child, "duplicate key after converting keys to strings")
+			}
+			value, err := canonicalValue(child, rv.MapIndex(k).Interface())
+			if err != nil {
+				return nil, err
+			}
+			out[key] = value
+		}
+		return out, nil
+	case reflect.Struct:
+		if t, ok := rv.Interface().(time.Time); ok {
+			return t, nil
+		}
+	}
+	return nil, canonicalError(path, "unsupported value of type %T", v)
+}
+
+// canonicalKey formats a map key as a string. Only scalar keys are allowed.
+func canonicalKey(path string, k reflect.Value) (string, error) {
+	k = indirectValue(k)
+	switch k.Kind() {
+	case reflect.String:
+		return k.String(), nil
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(k.Interface()), nil
+	}
+	if k.IsValid() {
+		if t, ok := k.Interface().(time.Time); ok {
+			return t.Format(time.RFC3339Nano), nil
+		}
+		return "", canonicalError(path, "unsupported map key of type %s", k.Type())
+	}
+	return "", canonicalError(path, "unsupported nil map key")
+}
+
+// formatText formats a scalar for formats that store every value as text,
+// writing times in RFC 3339 format so that they can be parsed again.
+func formatText(v interface{}) string {
+	if s, ok := timeText(reflect.ValueOf(v)); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// timeText returns v in RFC 3339 format if it holds a time.Time.
+func timeText(v reflect.Value) (string, bool) {
+	if !v.IsValid() || v.Type() != timeType {
+		return "", false
+	}
+	return v.Interface().(time.Time).Format(time.RFC3339Nano), true
+}
+
+// canonicalError returns an error for the value at path.
+func canonicalError(path, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if path == "" {
+		return fmt.Errorf("%s", msg)
+	}
+	return fmt.Errorf("%s: %s", path, msg)
+}
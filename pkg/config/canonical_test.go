@@ -0,0 +1,200 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// checkCanonical returns an error for the first value in v that is not part
+// of the canonical value model.
+func checkCanonical(path string, v interface{}) error {
+	switch val := v.(type) {
+	case nil, string, bool, int64, float64, time.Time:
+		return nil
+	case []interface{}:
+		for i, child := range val {
+			if err := checkCanonical(fmt.Sprintf("%s[%d]", path, i), child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		for k, child := range val {
+			if err := checkCanonical(joinPath(path, k), child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%s: unexpected %T", path, v)
+}
+
+func TestCanonicalizeGood(t *testing.T) {
+	t.Run("Converts decoder output to the canonical model", func(t *testing.T) {
+		when := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+		name := "demo"
+		in := map[interface{}]interface{}{
+			"name":  &name,
+			1:       int8(-1),
+			true:    uint16(2),
+			"big":   uint64(math.MaxUint64),
+			"ratio": float32(0.5),
+			"blob":  []byte("hi"),
+			"when":  when,
+			"num":   json.Number("42"),
+			"real":  json.Number("1.5"),
+			"list":  [2]int{1, 2},
+			"nested": map[interface{}]interface{}{
+				"ints": []int32{3},
+				"nil":  nil,
+			},
+		}
+		got, err := Canonicalize(in)
+		if err != nil {
+			t.Fatalf("Canonicalize() failed: %v", err)
+		}
+		expected := map[string]interface{}{
+			"name":  "demo",
+			"1":     int64(-1),
+			"true":  int64(2),
+			"big":   float64(math.MaxUint64),
+			"ratio": 0.5,
+			"blob":  "aGk=",
+			"when":  when,
+			"num":   int64(42),
+			"real":  1.5,
+			"list":  []interface{}{int64(1), int64(2)},
+			"nested": map[string]interface{}{
+				"ints": []interface{}{int64(3)},
+				"nil":  nil,
+			},
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Unexpected result.\nExpected: %#v\nGot: %#v", expected, got)
+		}
+	})
+
+	t.Run("Loads YAML with non-string keys as JSON-compatible maps", func(t *testing.T) {
+		src := "ports:\n  80: http\n  443: https\nflags:\n  true: on\n"
+		data, err := (&YAMLFormat{}).Decode(strings.NewReader(src))
+		if err != nil {
+			t.Fatalf("Decode() failed: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := (&JSONFormat{}).Encode(&buf, data); err != nil {
+			t.Fatalf("JSON Encode() failed: %v", err)
+		}
+		got, err := (&JSONFormat{}).Decode(&buf)
+		if err != nil {
+			t.Fatalf("JSON Decode() failed: %v", err)
+		}
+		expected := map[string]interface{}{
+			"ports": map[string]interface{}{"80": "http", "443": "https"},
+			"flags": map[string]interface{}{"true": "on"},
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Unexpected result.\nExpected: %v\nGot: %v", expected, got)
+		}
+	})
+
+	t.Run("Keeps integers exact when loading JSON", func(t *testing.T) {
+		src := `{"id": 9007199254740993, "ratio": 2.5, "exp": 1e3}`
+		for _, format := range []ConfigFormat{&JSONFormat{}, &JSONCFormat{}, &NDJSONFormat{}} {
+			got, err := format.Decode(strings.NewReader(src))
+			if err != nil {
+				t.Fatalf("%T Decode() failed: %v", format, err)
+			}
+			expected := map[string]interface{}{
+				"id":    int64(9007199254740993),
+				"ratio": 2.5,
+				"exp":   1000.0,
+			}
+			if !reflect.DeepEqual(expected, got) {
+				t.Errorf("%T: unexpected result.\nExpected: %#v\nGot: %#v", format, expected, got)
+			}
+		}
+	})
+}
+
+func TestCanonicalizeBad(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"struct value", map[string]interface{}{"a": struct{}{}}, "a: unsupported value of type struct {}"},
+		{"channel value", map[string]interface{}{"a": []interface{}{make(chan int)}}, "a[0]: unsupported value of type chan int"},
+		{"struct key", map[interface{}]interface{}{struct{ A int }{1}: "x"}, "unsupported map key of type struct { A int }"},
+		{"colliding keys", map[interface{}]interface{}{1: "a", "1": "b"}, "1: duplicate key after converting keys to strings"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Canonicalize(tc.value)
+			if err == nil || err.Error() != tc.want {
+				t.Errorf("Expected error %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+// TestFormatsInteroperate checks that the output of every registered format
+// is in the canonical value model and can be saved by every other format.
+func TestFormatsInteroperate(t *testing.T) {
+	data := map[string]interface{}{
+		"name":    "demo",
+		"port":    int64(8080),
+		"ratio":   0.75,
+		"debug":   true,
+		"updated": time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		"tags":    []interface{}{"a", "b"},
+		"server": map[string]interface{}{
+			"host":    "localhost",
+			"retries": int64(3),
+		},
+	}
+
+	formats := Formats()
+	for _, src := range formats {
+		srcFormat, err := GetConfigFormat(src.Extensions[0])
+		if err != nil {
+			t.Fatalf("GetConfigFormat(%q) failed: %v", src.Extensions[0], err)
+		}
+		var encoded bytes.Buffer
+		if err := srcFormat.Encode(&encoded, data); err != nil {
+			t.Fatalf("%s: Encode() failed: %v", src.Extensions[0], err)
+		}
+		loaded, err := srcFormat.Decode(&encoded)
+		if err != nil {
+			t.Fatalf("%s: Decode() failed: %v", src.Extensions[0], err)
+		}
+		if err := checkCanonical("", loaded); err != nil {
+			t.Errorf("%s: output is not canonical: %v", src.Extensions[0], err)
+		}
+
+		for _, dst := range formats {
+			t.Run(src.Extensions[0]+" to "+dst.Extensions[0], func(t *testing.T) {
+				dstFormat, err := GetConfigFormat(dst.Extensions[0])
+				if err != nil {
+					t.Fatalf("GetConfigFormat(%q) failed: %v", dst.Extensions[0], err)
+				}
+				var out bytes.Buffer
+				if err := dstFormat.Encode(&out, loaded); err != nil {
+					t.Fatalf("Encode() failed: %v", err)
+				}
+				got, err := dstFormat.Decode(&out)
+				if err != nil {
+					t.Fatalf("Decode() failed: %v", err)
+				}
+				if err := checkCanonical("", got); err != nil {
+					t.Errorf("Output is not canonical: %v", err)
+				}
+			})
+		}
+	}
+}
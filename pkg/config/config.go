@@ -35,6 +35,7 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -50,9 +51,13 @@ import (
 const appName = "lethean"
 const configFileName = "config.json"
 
-// Options holds configuration for the config service. This struct is provided
-// for future extensibility and currently has no fields.
-type Options struct{}
+// Options holds configuration for the config service.
+type Options struct {
+	// CommentedJSON allows config.json to contain comments and trailing
+	// commas. When enabled, Save preserves the comments and key order of the
+	// existing file and only rewrites values that have changed.
+	CommentedJSON bool
+}
 
 // Service provides access to the application's configuration.
 // It handles loading, saving, and providing access to configuration values,
@@ -77,15 +82,15 @@ type Service struct {
 	DefaultRoute string   `json:"default_route"`
 	Features     []string `json:"features"`
 	Language     string   `json:"language"`
+
+	opts     Options
+	defaults map[string]interface{}
+	fileKeys map[string]bool // keys set in config.json
 }
 
-// createServiceInstance handles the setup of the configuration service. It
-// resolves necessary paths, creates directories, and loads the configuration
-// file if it exists. If the configuration file is not found, it creates a new
-// one with default values. This function is not exported and is used internally
-// by the New and Register constructors.
-func createServiceInstance() (*Service, error) {
-	// --- Path and Directory Setup ---
+// defaultService returns a service with the default paths and values,
+// without touching the file system.
+func defaultService(opts Options) (*Service, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return nil, fmt.Errorf("could not resolve user home directory: %w", err)
@@ -112,8 +117,25 @@ func createServiceInstance() (*Service, error) {
 		DefaultRoute: "/",
 		Features:     []string{},
 		Language:     "en",
+		opts:         opts,
 	}
 	s.ConfigPath = filepath.Join(s.ConfigDir, configFileName)
+	s.defaults = s.Values()
+	return s, nil
+}
+
+// createServiceInstance handles the setup of the configuration service. It
+// resolves necessary paths, creates directories, and loads the configuration
+// file if it exists. If the configuration file is not found, it creates an
+// empty one, {}, since no key is set yet and every value is its default. This
+// function is not exported and is used internally by the New, NewWithOptions
+// and Register constructors.
+func createServiceInstance(opts Options) (*Service, error) {
+	// --- Path and Directory Setup ---
+	s, err := defaultService(opts)
+	if err != nil {
+		return nil, err
+	}
 
 	dirs := []string{s.RootDir, s.ConfigDir, s.DataDir, s.CacheDir, s.WorkspaceDir, s.UserHomeDir}
 	for _, dir := range dirs {
@@ -125,13 +147,13 @@ func createServiceInstance() (*Service, error) {
 	// --- Load or Create Configuration ---
 	if data, err := os.ReadFile(s.ConfigPath); err == nil {
 		// Config file exists, load it.
-		if err := json.Unmarshal(data, s); err != nil {
+		if err := s.unmarshal(data); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 		}
 	} else if os.IsNotExist(err) {
-		// Config file does not exist, create it with default values.
+		// Config file does not exist, create an empty one.
 		if err := s.Save(); err != nil {
-			return nil, fmt.Errorf("failed to create default config file: %w", err)
+			return nil, fmt.Errorf("failed to create config file: %w", err)
 		}
 	} else {
 		// Another error occurred reading the file.
@@ -154,7 +176,20 @@ func createServiceInstance() (*Service, error) {
 //	}
 //	// Use cfg to access configuration settings.
 func New() (*Service, error) {
-	return createServiceInstance()
+	return createServiceInstance(Options{})
+}
+
+// NewWithOptions creates a new instance of the configuration service with the
+// given options. It behaves like New in every other respect.
+//
+// Example:
+//
+//	cfg, err := config.NewWithOptions(config.Options{CommentedJSON: true})
+//	if err != nil {
+//		log.Fatalf("Failed to initialize config: %v", err)
+//	}
+func NewWithOptions(opts Options) (*Service, error) {
+	return createServiceInstance(opts)
 }
 
 // Register creates a new instance of the configuration service and registers it
@@ -163,7 +198,7 @@ func New() (*Service, error) {
 // It performs the same initialization as New, but also integrates the service
 // with the provided core instance.
 func Register(c *core.Core) (any, error) {
-	s, err := createServiceInstance()
+	s, err := createServiceInstance(Options{})
 	if err != nil {
 		return nil, err
 	}
@@ -179,7 +214,10 @@ func Register(c *core.Core) (any, error) {
 // Save writes the current configuration to a JSON file. The location of the file
 // is determined by the ConfigPath field of the Service struct. This method is
 // typically called automatically by Set, but can be used to explicitly save
-// changes.
+// changes. Only the keys config.json set when it was loaded and the keys set
+// since are written, so the others keep following their defaults. The file
+// is replaced atomically, so a failed write leaves the previous configuration
+// in place.
 //
 // Example:
 //
@@ -188,17 +226,98 @@ func Register(c *core.Core) (any, error) {
 //		log.Printf("Error saving configuration: %v", err)
 //	}
 func (s *Service) Save() error {
-	data, err := json.MarshalIndent(s, "", "  ")
+	data, values, err := s.marshalFile()
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(s.ConfigPath, data, 0644); err != nil {
+	if s.opts.CommentedJSON {
+		existing, err := os.ReadFile(s.ConfigPath)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+		if len(existing) > 0 {
+			if data, err = mergeJSONC(existing, values, false); err != nil {
+				return fmt.Errorf("failed to marshal config: %w", err)
+			}
+		}
+	}
+
+	if err := writeFileAtomic(s.ConfigPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 	return nil
 }
 
+// marshalFile returns the keys set in config.json as indented JSON in the
+// order of Keys, together with their values by key. Empty values of keys
+// tagged omitempty are left out, as encoding/json does.
+func (s *Service) marshalFile() ([]byte, map[string]interface{}, error) {
+	all, err := json.Marshal(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(all, &fields); err != nil {
+		return nil, nil, err
+	}
+	var b bytes.Buffer
+	values := make(map[string]interface{})
+	for _, key := range s.Keys() {
+		raw, ok := fields[key]
+		if !ok || !s.fileKeys[key] {
+			continue
+		}
+		if len(values) == 0 {
+			b.WriteString("{\n  ")
+		} else {
+			b.WriteString(",\n  ")
+		}
+		name, _ := json.Marshal(key)
+		b.Write(name)
+		b.WriteString(": ")
+		if err := json.Indent(&b, raw, "  ", "  "); err != nil {
+			return nil, nil, err
+		}
+		val, _, _ := s.field(key)
+		values[key] = cloneValue(val).Interface()
+	}
+	if len(values) == 0 {
+		return []byte("{}"), values, nil
+	}
+	b.WriteString("\n}")
+	return b.Bytes(), values, nil
+}
+
+// unmarshal decodes the contents of config.json into the service, accepting
+// comments and trailing commas when the CommentedJSON option is set. It
+// records the keys the file sets, which Lookup reports as SourceFile.
+func (s *Service) unmarshal(data []byte) error {
+	if s.opts.CommentedJSON {
+		doc, err := parseJSONC(string(data), false)
+		if err != nil {
+			return err
+		}
+		if data, err = json.Marshal(doc.value.decode()); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return err
+	}
+	var present map[string]json.RawMessage
+	if err := json.Unmarshal(data, &present); err != nil {
+		return err
+	}
+	s.fileKeys = make(map[string]bool, len(present))
+	for key := range present {
+		if _, name, ok := s.field(key); ok {
+			s.fileKeys[name] = true
+		}
+	}
+	return nil
+}
+
 // Get retrieves a configuration value by its key. The key corresponds to the
 // JSON tag of a field in the Service struct. The retrieved value is stored in
 // the `out` parameter, which must be a non-nil pointer to a variable of the
@@ -213,6 +332,25 @@ func (s *Service) Save() error {
 //	}
 //	fmt.Println("Current language is:", currentLanguage)
 func (s *Service) Get(key string, out any) error {
+	srcVal, _, ok := s.field(key)
+	if !ok {
+		return KeyError{Key: key}
+	}
+	outVal := reflect.ValueOf(out)
+	if outVal.Kind() != reflect.Ptr || outVal.IsNil() {
+		return errors.New("output argument must be a non-nil pointer")
+	}
+	targetVal := outVal.Elem()
+	if !srcVal.Type().AssignableTo(targetVal.Type()) {
+		return fmt.Errorf("cannot assign config value of type %s to output of type %s", srcVal.Type(), targetVal.Type())
+	}
+	targetVal.Set(srcVal)
+	return nil
+}
+
+// field returns the persistent field of the service whose JSON name matches
+// key, ignoring case, together with that name.
+func (s *Service) field(key string) (reflect.Value, string, bool) {
 	val := reflect.ValueOf(s).Elem()
 	typ := val.Type()
 
@@ -222,23 +360,11 @@ func (s *Service) Get(key string, out any) error {
 		if jsonTag != "" && jsonTag != "-" {
 			jsonName := strings.Split(jsonTag, ",")[0]
 			if strings.EqualFold(jsonName, key) {
-				outVal := reflect.ValueOf(out)
-				if outVal.Kind() != reflect.Ptr || outVal.IsNil() {
-					return errors.New("output argument must be a non-nil pointer")
-				}
-				targetVal := outVal.Elem()
-				srcVal := val.Field(i)
-
-				if !srcVal.Type().AssignableTo(targetVal.Type()) {
-					return fmt.Errorf("cannot assign config value of type %s to output of type %s", srcVal.Type(), targetVal.Type())
-				}
-				targetVal.Set(srcVal)
-				return nil
+				return val.Field(i), jsonName, true
 			}
 		}
 	}
-
-	return fmt.Errorf("key '%s' not found in config", key)
+	return reflect.Value{}, "", false
 }
 
 // SaveStruct saves an arbitrary struct to a JSON file in the config directory.
@@ -302,28 +428,18 @@ func (s *Service) LoadStruct(key string, data interface{}) error {
 //		log.Printf("Failed to set default route: %v", err)
 //	}
 func (s *Service) Set(key string, v any) error {
-	val := reflect.ValueOf(s).Elem()
-	typ := val.Type()
-
-	for i := 0; i < val.NumField(); i++ {
-		field := typ.Field(i)
-		jsonTag := field.Tag.Get("json")
-		if jsonTag != "" && jsonTag != "-" {
-			jsonName := strings.Split(jsonTag, ",")[0]
-			if strings.EqualFold(jsonName, key) {
-				fieldVal := val.Field(i)
-				if !fieldVal.CanSet() {
-					return fmt.Errorf("cannot set config field for key '%s'", key)
-				}
-				newVal := reflect.ValueOf(v)
-				if !newVal.Type().AssignableTo(fieldVal.Type()) {
-					return fmt.Errorf("type mismatch for key '%s': expected %s, got %s", key, fieldVal.Type(), newVal.Type())
-				}
-				fieldVal.Set(newVal)
-				return s.Save()
-			}
-		}
+	fieldVal, name, ok := s.field(key)
+	if !ok {
+		return KeyError{Key: key}
 	}
-
-	return fmt.Errorf("key '%s' not found in config", key)
+	if !fieldVal.CanSet() {
+		return fmt.Errorf("cannot set config field for key '%s'", key)
+	}
+	newVal := reflect.ValueOf(v)
+	if !newVal.Type().AssignableTo(fieldVal.Type()) {
+		return fmt.Errorf("type mismatch for key '%s': expected %s, got %s", key, fieldVal.Type(), newVal.Type())
+	}
+	fieldVal.Set(newVal)
+	s.setFileKey(name, true)
+	return s.Save()
 }
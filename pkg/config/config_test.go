@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/Snider/config/pkg/core"
@@ -166,6 +167,40 @@ func TestConfigServiceGood(t *testing.T) {
 			t.Errorf("Expected Timeout '%d', got '%d'", expectedConfig.Timeout, actualConfig.Timeout)
 		}
 	})
+
+	t.Run("CommentedJSON preserves comments in config.json", func(t *testing.T) {
+		tempHomeDir, cleanup := setupTestEnv(t)
+		defer cleanup()
+
+		configDir := filepath.Join(tempHomeDir, appName, "config")
+		if err := os.MkdirAll(configDir, os.ModePerm); err != nil {
+			t.Fatalf("Failed to create test config dir: %v", err)
+		}
+		configPath := filepath.Join(configDir, configFileName)
+		customConfig := "{\n  // UI language\n  \"language\": \"fr\",\n  \"features\": [],\n}\n"
+		if err := os.WriteFile(configPath, []byte(customConfig), 0644); err != nil {
+			t.Fatalf("Failed to write custom config file: %v", err)
+		}
+
+		s, err := NewWithOptions(Options{CommentedJSON: true})
+		if err != nil {
+			t.Fatalf("NewWithOptions() failed: %v", err)
+		}
+		if s.Language != "fr" {
+			t.Errorf("Expected language 'fr', got '%s'", s.Language)
+		}
+
+		if err := s.Set("language", "de"); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			t.Fatalf("Failed to read config file: %v", err)
+		}
+		if !strings.Contains(string(data), "// UI language\n  \"language\": \"de\",") {
+			t.Errorf("Expected comment to be preserved, got:\n%s", data)
+		}
+	})
 }
 
 func TestConfigServiceUgly(t *testing.T) {
@@ -0,0 +1,278 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// ConversionIssue describes a value that did not survive a conversion
+// unchanged.
+type ConversionIssue struct {
+	// Path is the dotted path of the value, with list indexes in brackets.
+	Path string
+	// Message explains what happened to the value.
+	Message string
+}
+
+// String returns the issue as "path: message".
+func (i ConversionIssue) String() string {
+	if i.Path == "" {
+		return i.Message
+	}
+	return i.Path + ": " + i.Message
+}
+
+// ConversionReport lists the values that a conversion changed or dropped.
+type ConversionReport struct {
+	Issues []ConversionIssue
+}
+
+// Lossy reports whether the conversion lost or changed any data.
+func (r *ConversionReport) Lossy() bool {
+	return len(r.Issues) > 0
+}
+
+// Convert reads the file src and writes its data to dst, choosing both
+// formats from their extensions (the format of src is detected from its
+// content if it has no extension). It returns a report of every value that
+// the destination format cannot represent exactly, such as nested maps
+// flattened into INI sections or numbers stored as strings in a dotenv file.
+// The destination is written even if the conversion is lossy.
+//
+// Example:
+//
+//	report, err := config.Convert("settings.ini", "settings.yaml")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	for _, issue := range report.Issues {
+//		log.Printf("warning: %s", issue)
+//	}
+func Convert(src, dst string) (*ConversionReport, error) {
+	srcFormat, err := resolveFormat(src)
+	if err != nil {
+		return nil, err
+	}
+	dstFormat, err := GetConfigFormat(dst)
+	if err != nil {
+		return nil, err
+	}
+	data, err := srcFormat.Load(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(src), err)
+	}
+	report, err := CheckConversion(data, dstFormat)
+	if err != nil {
+		return nil, err
+	}
+	if err := dstFormat.Save(dst, data); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", filepath.Base(dst), err)
+	}
+	return report, nil
+}
+
+// ConvertStream decodes r with the src format and encodes the data to w with
+// the dst format, returning the same report as Convert.
+func ConvertStream(r io.Reader, src ConfigFormat, w io.Writer, dst ConfigFormat) (*ConversionReport, error) {
+	data, err := src.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	report, err := CheckConversion(data, dst)
+	if err != nil {
+		return nil, err
+	}
+	if err := dst.Encode(w, data); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// CheckConversion encodes data with format, decodes it again and reports
+// every value that came back different.
+func CheckConversion(data map[string]interface{}, format ConfigFormat) (*ConversionReport, error) {
+	var buf bytes.Buffer
+	if err := format.Encode(&buf, data); err != nil {
+		return nil, err
+	}
+	decoded, err := format.Decode(&buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read back converted data: %w", err)
+	}
+	report := &ConversionReport{}
+	compareConverted(report, "", data, decoded)
+	return report, nil
+}
+
+// compareConverted adds an issue to report for every difference between the
+// original value and the value read back after conversion.
+func compareConverted(report *ConversionReport, path string, original, converted interface{}) {
+	add := func(p, format string, args ...interface{}) {
+		report.Issues = append(report.Issues, ConversionIssue{Path: p, Message: fmt.Sprintf(format, args...)})
+	}
+
+	ov := indirectValue(reflect.ValueOf(original))
+	cv := indirectValue(reflect.ValueOf(converted))
+
+	switch {
+	case ov.Kind() == reflect.Map && cv.Kind() == reflect.Map:
+		seen := make(map[string]bool)
+		for _, key := range sortedMapKeys(ov) {
+			seen[key] = true
+			child := joinPath(path, key)
+			before, _ := mapIndex(ov, key)
+			after, ok := mapIndex(cv, key)
+			if !ok {
+				add(child, "dropped (%s)", describeValue(reflect.ValueOf(before)))
+				continue
+			}
+			compareConverted(report, child, before, after)
+		}
+		for _, key := range sortedMapKeys(cv) {
+			if !seen[key] {
+				add(joinPath(path, key), "added by the conversion")
+			}
+		}
+		return
+	case isList(ov) && isList(cv):
+		n := ov.Len()
+		if cv.Len() < n {
+			n = cv.Len()
+		}
+		for i := 0; i < n; i++ {
+			compareConverted(report, fmt.Sprintf("%s[%d]", path, i), ov.Index(i).Interface(), cv.Index(i).Interface())
+		}
+		if ov.Len() != cv.Len() {
+			add(path, "list of %d elements became %d elements", ov.Len(), cv.Len())
+		}
+		return
+	}
+
+	if convertedEqual(ov, cv) {
+		return
+	}
+	oType, cType := describeKind(ov), describeKind(cv)
+	if oType != cType {
+		add(path, "%s became %s (%s)", describeValue(ov), cType, describeValue(cv))
+		return
+	}
+	add(path, "value changed from %s to %s", describeValue(ov), describeValue(cv))
+}
+
+// convertedEqual reports whether two scalars are equal, treating numbers of
+// any kind as equal when they have the same value and times as equal when
+// they denote the same instant.
+func convertedEqual(a, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+	if an, ok := numberValue(a); ok {
+		bn, ok := numberValue(b)
+		return ok && an == bn
+	}
+	if at, ok := a.Interface().(time.Time); ok {
+		bt, ok := b.Interface().(time.Time)
+		return ok && at.Equal(bt)
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// numberValue returns v as a float64 if it is a number that a float64 can
+// represent exactly.
+func numberValue(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f := float64(v.Int())
+		return f, f < math.MaxInt64 && int64(f) == v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f := float64(v.Uint())
+		return f, f < math.MaxUint64 && uint64(f) == v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// describeKind names the kind of a value for reports.
+func describeKind(v reflect.Value) string {
+	switch {
+	case !v.IsValid():
+		return "null"
+	case v.Type() == timeType:
+		return "time"
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		return "bytes"
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		return "map"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	}
+	return v.Type().String()
+}
+
+// describeValue formats a value and its kind for reports, shortening long
+// values.
+func describeValue(v reflect.Value) string {
+	v = indirectValue(v)
+	kind := describeKind(v)
+	switch kind {
+	case "null":
+		return "null"
+	case "map":
+		return fmt.Sprintf("map of %d keys", v.Len())
+	case "list":
+		return fmt.Sprintf("list of %d elements", v.Len())
+	case "bytes":
+		return fmt.Sprintf("%d bytes", v.Len())
+	}
+	s := fmt.Sprintf("%v", v.Interface())
+	if kind == "string" {
+		s = fmt.Sprintf("%q", s)
+	}
+	if len(s) > 40 {
+		s = s[:37] + "..."
+	}
+	return kind + " " + s
+}
+
+// sortedMapKeys returns the keys of a map value as sorted strings.
+func sortedMapKeys(m reflect.Value) []string {
+	keys := make([]string, 0, m.Len())
+	for _, k := range m.MapKeys() {
+		keys = append(keys, fmt.Sprint(k.Interface()))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mapIndex looks up a string key in a map value.
+func mapIndex(m reflect.Value, key string) (interface{}, bool) {
+	for _, k := range m.MapKeys() {
+		if fmt.Sprint(k.Interface()) == key {
+			return m.MapIndex(k).Interface(), true
+		}
+	}
+	return nil, false
+}
+
+// isList reports whether v is a list other than a byte slice.
+func isList(v reflect.Value) bool {
+	return v.IsValid() && describeKind(v) == "list"
+}
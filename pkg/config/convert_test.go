@@ -0,0 +1,117 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConvertGood(t *testing.T) {
+	t.Run("Converts INI to YAML without loss", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "app.ini")
+		dst := filepath.Join(dir, "app.yaml")
+		if err := os.WriteFile(src, []byte("name = demo\n\n[server]\nport = 8080\ntls = true\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		report, err := Convert(src, dst)
+		if err != nil {
+			t.Fatalf("Convert() failed: %v", err)
+		}
+		if report.Lossy() {
+			t.Errorf("Expected a lossless conversion, got %v", report.Issues)
+		}
+		got, err := (&YAMLFormat{}).Load(dst)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		expected := map[string]interface{}{
+			"name":   "demo",
+			"server": map[string]interface{}{"port": int64(8080), "tls": true},
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("Reports lossy conversions", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "app.json")
+		if err := os.WriteFile(src, []byte(`{"name": "demo", "port": 8080, "debug": false, "tags": []}`), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		report, err := Convert(src, filepath.Join(dir, "app.env"))
+		if err != nil {
+			t.Fatalf("Convert() failed: %v", err)
+		}
+		expected := []string{
+			`debug: bool false became string (string "false")`,
+			`port: integer 8080 became string (string "8080")`,
+			`tags: list of 0 elements became string (string "[]")`,
+		}
+		var got []string
+		for _, issue := range report.Issues {
+			got = append(got, issue.String())
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Unexpected report.\nExpected: %q\nGot: %q", expected, got)
+		}
+
+		report, err = Convert(src, filepath.Join(dir, "app.ini"))
+		if err != nil {
+			t.Fatalf("Convert() failed: %v", err)
+		}
+		if len(report.Issues) != 1 || report.Issues[0].Path != "tags" || !strings.Contains(report.Issues[0].Message, "dropped") {
+			t.Errorf("Expected the empty list to be reported as dropped, got %v", report.Issues)
+		}
+	})
+
+	t.Run("Converts streams", func(t *testing.T) {
+		var out bytes.Buffer
+		report, err := ConvertStream(strings.NewReader("<config><port type=\"int\">80</port></config>"), &XMLFormat{}, &out, &JSONFormat{})
+		if err != nil {
+			t.Fatalf("ConvertStream() failed: %v", err)
+		}
+		if report.Lossy() {
+			t.Errorf("Expected a lossless conversion, got %v", report.Issues)
+		}
+		if out.String() != "{\n  \"port\": 80\n}" {
+			t.Errorf("Unexpected output:\n%s", out.String())
+		}
+	})
+}
+
+func TestConvertBad(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "app.json")
+	if err := os.WriteFile(src, []byte(`{"missing": null}`), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	t.Run("Unsupported destination", func(t *testing.T) {
+		if _, err := Convert(src, filepath.Join(dir, "app.txt")); err == nil {
+			t.Error("Expected an error for an unsupported destination format")
+		}
+	})
+
+	t.Run("Missing source", func(t *testing.T) {
+		if _, err := Convert(filepath.Join(dir, "missing.json"), filepath.Join(dir, "app.yaml")); err == nil {
+			t.Error("Expected an error for a missing source file")
+		}
+	})
+
+	t.Run("Value the destination cannot encode", func(t *testing.T) {
+		dst := filepath.Join(dir, "app.hcl")
+		if _, err := Convert(src, dst); err == nil {
+			t.Error("Expected an error for a null value in HCL")
+		}
+		if _, err := os.Stat(dst); !os.IsNotExist(err) {
+			t.Errorf("Expected no destination file to be written, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// ChangeKind says how a value differs between two configurations.
+type ChangeKind string
+
+// Kinds of Change.
+const (
+	ChangeAdded   ChangeKind = "added"
+	ChangeRemoved ChangeKind = "removed"
+	ChangeChanged ChangeKind = "changed"
+)
+
+// Change is a difference found by Diff.
+type Change struct {
+	// Path is the dotted path of the value, with list indexes in brackets,
+	// such as "database.hosts[1]".
+	Path string `json:"path" yaml:"path"`
+	// Kind says whether the value was added, removed or changed.
+	Kind ChangeKind `json:"kind" yaml:"kind"`
+	// Old is the value in the first configuration, or nil if it was added.
+	Old interface{} `json:"old" yaml:"old"`
+	// New is the value in the second configuration, or nil if it was
+	// removed.
+	New interface{} `json:"new" yaml:"new"`
+}
+
+// String returns the change as "+ path: new", "- path: old" or
+// "~ path: old -> new", with values formatted as JSON.
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s: %s", c.Path, FormatDiffValue(c.New))
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s: %s", c.Path, FormatDiffValue(c.Old))
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", c.Path, FormatDiffValue(c.Old), FormatDiffValue(c.New))
+}
+
+// FormatDiffValue formats a value of a Change as JSON, with times in RFC 3339
+// form.
+func FormatDiffValue(v interface{}) string {
+	if t, ok := v.(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(out)
+}
+
+// Diff compares two configurations, as loaded from files of any format or
+// returned by Values, and returns every difference sorted by path. Maps are
+// compared key by key and lists element by element, so a change deep inside
+// a structure is reported at its own path. Numbers are equal if they have
+// the same value, whatever their type, so an integer loaded from YAML equals
+// the same number loaded from JSON.
+//
+// Example:
+//
+//	a, _ := config.LoadFile("alice.yaml")
+//	b, _ := config.LoadFile("bob.json")
+//	for _, change := range config.Diff(a, b) {
+//		fmt.Println(change)
+//	}
+func Diff(a, b map[string]interface{}) []Change {
+	var changes []Change
+	diffValues(&changes, "", reflect.ValueOf(a), reflect.ValueOf(b))
+	return changes
+}
+
+// diffValues appends the differences between a and b, found at path, to
+// changes.
+func diffValues(changes *[]Change, path string, a, b reflect.Value) {
+	a, b = indirectValue(a), indirectValue(b)
+	switch {
+	case a.Kind() == reflect.Map && b.Kind() == reflect.Map:
+		keys := sortedMapKeys(a)
+		for _, key := range sortedMapKeys(b) {
+			if _, ok := mapIndex(a, key); !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := joinPath(path, key)
+			av, inA := mapIndex(a, key)
+			bv, inB := mapIndex(b, key)
+			switch {
+			case !inB:
+				*changes = append(*changes, Change{Path: child, Kind: ChangeRemoved, Old: av})
+			case !inA:
+				*changes = append(*changes, Change{Path: child, Kind: ChangeAdded, New: bv})
+			default:
+				diffValues(changes, child, reflect.ValueOf(av), reflect.ValueOf(bv))
+			}
+		}
+		return
+	case isList(a) && isList(b):
+		for i := 0; i < a.Len() || i < b.Len(); i++ {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= b.Len():
+				*changes = append(*changes, Change{Path: child, Kind: ChangeRemoved, Old: a.Index(i).Interface()})
+			case i >= a.Len():
+				*changes = append(*changes, Change{Path: child, Kind: ChangeAdded, New: b.Index(i).Interface()})
+			default:
+				diffValues(changes, child, a.Index(i), b.Index(i))
+			}
+		}
+		return
+	}
+	if !convertedEqual(a, b) {
+		*changes = append(*changes, Change{Path: path, Kind: ChangeChanged, Old: valueInterface(a), New: valueInterface(b)})
+	}
+}
+
+// valueInterface returns the value held by v, or nil if v is invalid.
+func valueInterface(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// DiffFiles loads two config files of any supported format, as LoadFile
+// does, and compares them with Diff.
+func DiffFiles(a, b string) ([]Change, error) {
+	am, err := LoadFile(a)
+	if err != nil {
+		return nil, err
+	}
+	bm, err := LoadFile(b)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(am, bm), nil
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffGood(t *testing.T) {
+	t.Run("Reports nested changes", func(t *testing.T) {
+		a := map[string]interface{}{
+			"language": "en",
+			"port":     int64(8080),
+			"colour":   "blue",
+			"database": map[string]interface{}{"host": "localhost", "ports": []interface{}{int64(1), int64(2)}},
+			"features": []string{"a", "b"},
+			"created":  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		}
+		b := map[string]interface{}{
+			"language": "fr",
+			"port":     float64(8080),
+			"database": map[string]interface{}{"host": "db", "ports": []interface{}{int64(1)}, "user": "admin"},
+			"features": []interface{}{"a", "b", "c"},
+			"created":  time.Date(2024, 1, 2, 4, 4, 5, 0, time.FixedZone("", 3600)),
+			"debug":    true,
+		}
+		expected := []Change{
+			{Path: "colour", Kind: ChangeRemoved, Old: "blue"},
+			{Path: "database.host", Kind: ChangeChanged, Old: "localhost", New: "db"},
+			{Path: "database.ports[1]", Kind: ChangeRemoved, Old: int64(2)},
+			{Path: "database.user", Kind: ChangeAdded, New: "admin"},
+			{Path: "debug", Kind: ChangeAdded, New: true},
+			{Path: "features[2]", Kind: ChangeAdded, New: "c"},
+			{Path: "language", Kind: ChangeChanged, Old: "en", New: "fr"},
+		}
+		if got := Diff(a, b); !reflect.DeepEqual(expected, got) {
+			t.Errorf("Unexpected changes.\nExpected: %v\nGot: %v", expected, got)
+		}
+		if got := Diff(a, a); len(got) != 0 {
+			t.Errorf("Expected no changes, got %v", got)
+		}
+	})
+
+	t.Run("Change strings", func(t *testing.T) {
+		testCases := []struct {
+			change   Change
+			expected string
+		}{
+			{Change{Path: "a", Kind: ChangeAdded, New: []interface{}{"x"}}, `+ a: ["x"]`},
+			{Change{Path: "b.c", Kind: ChangeRemoved, Old: int64(1)}, `- b.c: 1`},
+			{Change{Path: "d", Kind: ChangeChanged, Old: "x", New: nil}, `~ d: "x" -> null`},
+		}
+		for _, tc := range testCases {
+			if got := tc.change.String(); got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		}
+	})
+
+	t.Run("Compares files of different formats", func(t *testing.T) {
+		dir := t.TempDir()
+		a := filepath.Join(dir, "a.yaml")
+		b := filepath.Join(dir, "b.json")
+		if err := os.WriteFile(a, []byte("port: 8080\nhosts: [a, b]\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if err := os.WriteFile(b, []byte(`{"port": 8080, "hosts": ["a", "c"]}`), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		changes, err := DiffFiles(a, b)
+		if err != nil {
+			t.Fatalf("DiffFiles() failed: %v", err)
+		}
+		expected := []Change{{Path: "hosts[1]", Kind: ChangeChanged, Old: "b", New: "c"}}
+		if !reflect.DeepEqual(expected, changes) {
+			t.Errorf("Expected %v, got %v", expected, changes)
+		}
+	})
+
+	t.Run("Compares with defaults and snapshots", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		snapshot := filepath.Join(t.TempDir(), "snapshot.zip")
+		var buf bytes.Buffer
+		if _, err := s.Export(&buf, ArchiveZip); err != nil {
+			t.Fatalf("Export() failed: %v", err)
+		}
+		if err := os.WriteFile(snapshot, buf.Bytes(), 0644); err != nil {
+			t.Fatalf("Failed to write snapshot: %v", err)
+		}
+		if err := s.SetString("features", "beta"); err != nil {
+			t.Fatalf("SetString() failed: %v", err)
+		}
+
+		current, err := LoadFile(s.ConfigPath)
+		if err != nil {
+			t.Fatalf("LoadFile() failed: %v", err)
+		}
+		expected := []Change{{Path: "features[0]", Kind: ChangeAdded, New: "beta"}}
+		current = s.WithDefaults(current)
+		if got := Diff(s.Defaults(), current); !reflect.DeepEqual(expected, got) {
+			t.Errorf("Against defaults: expected %v, got %v", expected, got)
+		}
+		old, err := LoadSnapshot(snapshot)
+		if err != nil {
+			t.Fatalf("LoadSnapshot() failed: %v", err)
+		}
+		if got := Diff(s.WithDefaults(old), current); !reflect.DeepEqual(expected, got) {
+			t.Errorf("Against the snapshot: expected %v, got %v", expected, got)
+		}
+	})
+}
+
+func TestDiffBad(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.yaml")
+	broken := filepath.Join(dir, "broken.json")
+	if err := os.WriteFile(broken, []byte("{"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := DiffFiles(missing, broken); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+	if _, err := DiffFiles(broken, broken); err == nil {
+		t.Error("Expected an error for a file that cannot be parsed")
+	}
+	if _, err := LoadSnapshot(broken); err == nil {
+		t.Error("Expected an error for a snapshot that is not an archive")
+	}
+}
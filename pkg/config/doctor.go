@@ -0,0 +1,315 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// staleAge is how old a lock or temporary file must be before Doctor
+// reports it as left behind by a process that no longer runs.
+const staleAge = 10 * time.Minute
+
+// ResolvedPath is a file or directory used by the service.
+type ResolvedPath struct {
+	// Name is the name of the Service field, such as "ConfigDir".
+	Name string `json:"name" yaml:"name"`
+	// Path is the absolute path.
+	Path string `json:"path" yaml:"path"`
+	// IsDir reports whether the path is a directory.
+	IsDir bool `json:"isDir" yaml:"isDir"`
+}
+
+// Paths returns every file and directory the service uses, in the order of
+// the Service fields.
+func (s *Service) Paths() []ResolvedPath {
+	return []ResolvedPath{
+		{Name: "ConfigPath", Path: s.ConfigPath},
+		{Name: "UserHomeDir", Path: s.UserHomeDir, IsDir: true},
+		{Name: "RootDir", Path: s.RootDir, IsDir: true},
+		{Name: "CacheDir", Path: s.CacheDir, IsDir: true},
+		{Name: "ConfigDir", Path: s.ConfigDir, IsDir: true},
+		{Name: "DataDir", Path: s.DataDir, IsDir: true},
+		{Name: "WorkspaceDir", Path: s.WorkspaceDir, IsDir: true},
+	}
+}
+
+// Resolve returns a service with the default paths and values, updated with
+// the values from config.json if it can be read and parsed. Unlike New it
+// does not create any file or directory, and a missing or broken config.json
+// is not an error, so it can be used to inspect an installation that New
+// cannot open.
+func Resolve(opts Options) (*Service, error) {
+	s, err := defaultService(opts)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(s.ConfigPath)
+	if err != nil {
+		return s, nil
+	}
+	loaded := *s
+	if err := loaded.unmarshal(data); err != nil {
+		return s, nil
+	}
+	return &loaded, nil
+}
+
+// Problem is an issue found by Doctor.
+type Problem struct {
+	// Path is the file or directory the problem is about.
+	Path string
+	// Message describes the problem.
+	Message string
+
+	fix func() error
+}
+
+// String returns the problem as "path: message".
+func (p Problem) String() string {
+	return p.Path + ": " + p.Message
+}
+
+// Fixable reports whether Fix can repair the problem.
+func (p Problem) Fixable() bool {
+	return p.fix != nil
+}
+
+// Fix repairs the problem, or returns an error if it cannot be repaired
+// automatically.
+func (p Problem) Fix() error {
+	if p.fix == nil {
+		return errors.New("cannot be fixed automatically")
+	}
+	return p.fix()
+}
+
+// Doctor checks the installation described by Resolve and returns every
+// problem found:
+//
+//   - directories that are missing, are not directories, are not writable or
+//     are writable by everyone
+//   - a config.json that is missing, writable by other users, cannot be
+//     parsed or holds invalid values (see Validate)
+//   - lock files and temporary files from interrupted saves that are older
+//     than ten minutes
+//
+// Fixable problems can be repaired with Problem.Fix, in the order returned.
+// Fixing a config.json that cannot be parsed moves it aside with a ".bak"
+// suffix and writes the defaults; fixing an invalid value removes it from
+// config.json so that its default is used.
+//
+// Example:
+//
+//	problems, err := config.Doctor(config.Options{})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	for _, p := range problems {
+//		fmt.Println(p)
+//	}
+func Doctor(opts Options) ([]Problem, error) {
+	s, err := Resolve(opts)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, p := range s.Paths() {
+		if p.IsDir && !seen[p.Path] {
+			seen[p.Path] = true
+			dirs = append(dirs, p.Path)
+		}
+	}
+
+	var problems []Problem
+	for _, dir := range dirs {
+		problems = append(problems, checkDir(dir)...)
+	}
+	problems = append(problems, checkConfigFile(s)...)
+	for _, dir := range dirs {
+		problems = append(problems, checkStaleFiles(dir)...)
+	}
+	return problems, nil
+}
+
+// checkDir checks that path is an existing, writable directory that only its
+// owner and group can write to.
+func checkDir(path string) []Problem {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return []Problem{{Path: path, Message: "directory does not exist", fix: func() error {
+			return os.MkdirAll(path, os.ModePerm)
+		}}}
+	}
+	if err != nil {
+		return []Problem{{Path: path, Message: err.Error()}}
+	}
+	if !info.IsDir() {
+		return []Problem{{Path: path, Message: "is not a directory"}}
+	}
+
+	var problems []Problem
+	if perm := info.Mode().Perm(); perm&0002 != 0 {
+		problems = append(problems, Problem{
+			Path:    path,
+			Message: fmt.Sprintf("directory is writable by everyone (mode %04o)", perm),
+			fix:     func() error { return os.Chmod(path, perm&^0002) },
+		})
+	}
+	probe, err := os.CreateTemp(path, ".doctor-*")
+	if err != nil {
+		problems = append(problems, Problem{Path: path, Message: "directory is not writable"})
+	} else {
+		probe.Close()
+		os.Remove(probe.Name())
+	}
+	return problems
+}
+
+// checkConfigFile checks that config.json exists, is writable by its owner
+// only, parses and holds valid values.
+func checkConfigFile(s *Service) []Problem {
+	path := s.ConfigPath
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return []Problem{{Path: path, Message: "config file does not exist", fix: func() error {
+			defaults, err := defaultService(s.opts)
+			if err != nil {
+				return err
+			}
+			defaults.ConfigPath = path
+			return defaults.Save()
+		}}}
+	}
+	if err != nil {
+		return []Problem{{Path: path, Message: err.Error()}}
+	}
+
+	var problems []Problem
+	if perm := info.Mode().Perm(); perm&0022 != 0 {
+		problems = append(problems, Problem{
+			Path:    path,
+			Message: fmt.Sprintf("config file is writable by other users (mode %04o)", perm),
+			fix:     func() error { return os.Chmod(path, perm&^0022) },
+		})
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return append(problems, Problem{Path: path, Message: err.Error()})
+	}
+	values, err := decodeConfigFile(data, s.opts)
+	if err != nil {
+		return append(problems, Problem{
+			Path:    path,
+			Message: fmt.Sprintf("config file cannot be parsed: %v", err),
+			fix: func() error {
+				if err := os.Rename(path, path+".bak"); err != nil {
+					return err
+				}
+				defaults, err := defaultService(s.opts)
+				if err != nil {
+					return err
+				}
+				defaults.ConfigPath = path
+				return defaults.Save()
+			},
+		})
+	}
+	if err := s.Validate(values); err != nil {
+		for _, verr := range err.(ValidationErrors) {
+			key := verr.Key
+			problems = append(problems, Problem{
+				Path:    path,
+				Message: "invalid value: " + verr.Error(),
+				fix:     func() error { return removeConfigKey(path, key, s.opts) },
+			})
+		}
+	}
+	return problems
+}
+
+// checkStaleFiles reports lock files and temporary files left behind by
+// interrupted saves in dir.
+func checkStaleFiles(dir string) []Problem {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil // reported by checkDir
+	}
+	var problems []Problem
+	for _, entry := range entries {
+		name := entry.Name()
+		var message string
+		switch {
+		case strings.HasSuffix(name, ".lock"):
+			message = "stale lock file"
+		case strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".tmp"):
+			message = "temporary file left by an interrupted save"
+		default:
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || time.Since(info.ModTime()) < staleAge {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		problems = append(problems, Problem{
+			Path:    path,
+			Message: message,
+			fix:     func() error { return os.Remove(path) },
+		})
+	}
+	return problems
+}
+
+// decodeConfigFile decodes the contents of config.json into a map.
+func decodeConfigFile(data []byte, opts Options) (map[string]interface{}, error) {
+	if opts.CommentedJSON {
+		doc, err := parseJSONC(string(data), false)
+		if err != nil {
+			return nil, err
+		}
+		values, ok := doc.value.decode().(map[string]interface{})
+		if !ok {
+			return nil, errors.New("config file must hold an object")
+		}
+		return values, nil
+	}
+	var values map[string]interface{}
+	if err := unmarshalJSON(data, &values); err != nil {
+		return nil, err
+	}
+	if values == nil {
+		return map[string]interface{}{}, nil // "null" loads the defaults
+	}
+	return canonicalDocument("json", values)
+}
+
+// removeConfigKey removes key from the config file at path, keeping its
+// comments and layout when opts.CommentedJSON is set.
+func removeConfigKey(path, key string, opts Options) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	values, err := decodeConfigFile(data, opts)
+	if err != nil {
+		return err
+	}
+	delete(values, key)
+	var out []byte
+	if opts.CommentedJSON {
+		out, err = mergeJSONC(data, values, false)
+	} else {
+		out, err = json.MarshalIndent(values, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, out, 0644)
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixAll fixes every fixable problem, failing the test if a fix fails.
+func fixAll(t *testing.T, problems []Problem) {
+	t.Helper()
+	for _, p := range problems {
+		if !p.Fixable() {
+			continue
+		}
+		if err := p.Fix(); err != nil {
+			t.Fatalf("Fix() of %q failed: %v", p, err)
+		}
+	}
+}
+
+func TestDoctorGood(t *testing.T) {
+	t.Run("Reports nothing for a fresh installation", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+
+		if _, err := New(); err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		problems, err := Doctor(Options{})
+		if err != nil {
+			t.Fatalf("Doctor() failed: %v", err)
+		}
+		if len(problems) != 0 {
+			t.Errorf("Expected no problems, got %v", problems)
+		}
+	})
+
+	t.Run("Paths lists every resolved location", func(t *testing.T) {
+		tempHomeDir, cleanup := setupTestEnv(t)
+		defer cleanup()
+
+		s, err := Resolve(Options{})
+		if err != nil {
+			t.Fatalf("Resolve() failed: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(tempHomeDir, appName)); !os.IsNotExist(err) {
+			t.Errorf("Resolve() should not create directories")
+		}
+		var names []string
+		for _, p := range s.Paths() {
+			names = append(names, p.Name)
+			// RootDir and CacheDir come from xdg, which reads the environment once.
+			if p.Name != "RootDir" && p.Name != "CacheDir" && !strings.HasPrefix(p.Path, tempHomeDir) {
+				t.Errorf("%s: expected a path under %s, got %s", p.Name, tempHomeDir, p.Path)
+			}
+		}
+		expected := []string{"ConfigPath", "UserHomeDir", "RootDir", "CacheDir", "ConfigDir", "DataDir", "WorkspaceDir"}
+		if !reflect.DeepEqual(expected, names) {
+			t.Errorf("Expected %v, got %v", expected, names)
+		}
+	})
+
+	t.Run("Fixes a broken installation", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		if err := os.RemoveAll(s.WorkspaceDir); err != nil {
+			t.Fatalf("RemoveAll() failed: %v", err)
+		}
+		if err := os.Chmod(s.DataDir, 0777); err != nil {
+			t.Fatalf("Chmod() failed: %v", err)
+		}
+		if err := os.WriteFile(s.ConfigPath, []byte(`{"language": "", "colour": "blue", "default_route": "/x"}`), 0666); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+		if err := os.Chmod(s.ConfigPath, 0666); err != nil {
+			t.Fatalf("Chmod() failed: %v", err)
+		}
+		lock := filepath.Join(s.ConfigDir, "app.lock")
+		if err := os.WriteFile(lock, nil, 0644); err != nil {
+			t.Fatalf("Failed to write lock file: %v", err)
+		}
+		old := time.Now().Add(-time.Hour)
+		if err := os.Chtimes(lock, old, old); err != nil {
+			t.Fatalf("Chtimes() failed: %v", err)
+		}
+		fresh := filepath.Join(s.ConfigDir, "busy.lock")
+		if err := os.WriteFile(fresh, nil, 0644); err != nil {
+			t.Fatalf("Failed to write lock file: %v", err)
+		}
+
+		problems, err := Doctor(Options{})
+		if err != nil {
+			t.Fatalf("Doctor() failed: %v", err)
+		}
+		var got []string
+		for _, p := range problems {
+			if !p.Fixable() {
+				t.Errorf("Expected %q to be fixable", p)
+			}
+			got = append(got, p.String())
+		}
+		expected := []string{
+			s.DataDir + ": directory is writable by everyone (mode 0777)",
+			s.WorkspaceDir + ": directory does not exist",
+			s.ConfigPath + ": config file is writable by other users (mode 0666)",
+			s.ConfigPath + ": invalid value: colour: unknown key",
+			s.ConfigPath + ": invalid value: language: must not be empty",
+			lock + ": stale lock file",
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Fatalf("Unexpected problems.\nExpected: %q\nGot: %q", expected, got)
+		}
+
+		fixAll(t, problems)
+		if problems, err = Doctor(Options{}); err != nil || len(problems) != 0 {
+			t.Errorf("Expected no problems after fixing, got %v (%v)", problems, err)
+		}
+		reloaded, err := New()
+		if err != nil {
+			t.Fatalf("New() failed after fixing: %v", err)
+		}
+		if reloaded.Language != "en" || reloaded.DefaultRoute != "/x" {
+			t.Errorf("Expected language to be reset and default_route kept, got %q and %q", reloaded.Language, reloaded.DefaultRoute)
+		}
+		if _, err := os.Stat(fresh); err != nil {
+			t.Errorf("A recent lock file should be kept: %v", err)
+		}
+		info, err := os.Stat(s.DataDir)
+		if err != nil {
+			t.Fatalf("Stat() failed: %v", err)
+		}
+		if perm := info.Mode().Perm(); perm != 0775 {
+			t.Errorf("Expected only world write to be removed from the data directory, got mode %04o", perm)
+		}
+	})
+
+	t.Run("Replaces a config file that cannot be parsed", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		if err := os.WriteFile(s.ConfigPath, []byte("{broken"), 0644); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+		problems, err := Doctor(Options{})
+		if err != nil {
+			t.Fatalf("Doctor() failed: %v", err)
+		}
+		if len(problems) != 1 || !strings.Contains(problems[0].Message, "cannot be parsed") {
+			t.Fatalf("Expected a parse problem, got %v", problems)
+		}
+		fixAll(t, problems)
+
+		backup, err := os.ReadFile(s.ConfigPath + ".bak")
+		if err != nil || string(backup) != "{broken" {
+			t.Errorf("Expected the broken file to be kept as a backup, got %q (%v)", backup, err)
+		}
+		if _, err := New(); err != nil {
+			t.Errorf("New() failed after fixing: %v", err)
+		}
+	})
+}
+
+func TestDoctorBad(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	s, err := New()
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := os.RemoveAll(s.DataDir); err != nil {
+		t.Fatalf("RemoveAll() failed: %v", err)
+	}
+	if err := os.WriteFile(s.DataDir, nil, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	problems, err := Doctor(Options{})
+	if err != nil {
+		t.Fatalf("Doctor() failed: %v", err)
+	}
+	if len(problems) != 1 || problems[0].Message != "is not a directory" {
+		t.Fatalf("Expected one problem, got %v", problems)
+	}
+	if problems[0].Fixable() {
+		t.Error("Expected the problem not to be fixable")
+	}
+	if err := problems[0].Fix(); err == nil {
+		t.Error("Expected Fix() to fail")
+	}
+}
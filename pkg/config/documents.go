@@ -0,0 +1,268 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MultiDocumentFormat is implemented by formats that can hold a sequence of
+// documents in one file, such as YAML documents separated by "---" or
+// newline-delimited JSON.
+type MultiDocumentFormat interface {
+	// DecodeAll reads every document from r.
+	DecodeAll(r io.Reader) ([]map[string]interface{}, error)
+	// EncodeAll writes docs to w as a sequence of documents.
+	EncodeAll(w io.Writer, docs []map[string]interface{}) error
+}
+
+// errMultipleDocuments is returned by Decode when the input holds more than
+// one document.
+var errMultipleDocuments = errors.New("input contains multiple documents, use LoadDocuments to read them")
+
+// NDJSONFormat implements the ConfigFormat and MultiDocumentFormat interfaces
+// for newline-delimited JSON (.ndjson, .jsonl): one JSON object per line.
+// Blank lines are ignored. As a ConfigFormat it reads and writes files that
+// hold a single object.
+type NDJSONFormat struct{}
+
+// DecodeAll reads one JSON object per line from r.
+func (f *NDJSONFormat) DecodeAll(r io.Reader) ([]map[string]interface{}, error) {
+	reader := bufio.NewReader(r)
+	docs := []map[string]interface{}{}
+	for line := 1; ; line++ {
+		raw, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 {
+			var doc map[string]interface{}
+			if jsonErr := unmarshalJSON(trimmed, &doc); jsonErr != nil {
+				return nil, fmt.Errorf("ndjson line %d: %w", line, jsonErr)
+			}
+			if doc == nil {
+				return nil, fmt.Errorf("ndjson line %d: expected an object", line)
+			}
+			doc, err = canonicalDocument(fmt.Sprintf("ndjson line %d", line), doc)
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+		if err == io.EOF {
+			return docs, nil
+		}
+	}
+}
+
+// EncodeAll writes each document to w as a single line of JSON.
+func (f *NDJSONFormat) EncodeAll(w io.Writer, docs []map[string]interface{}) error {
+	var buf bytes.Buffer
+	for i, doc := range docs {
+		line, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("ndjson document %d: %w", i, err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Decode reads a single JSON object from r. It returns an error if r holds
+// more than one.
+func (f *NDJSONFormat) Decode(r io.Reader) (map[string]interface{}, error) {
+	docs, err := f.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return singleDocument("ndjson", docs)
+}
+
+// Encode writes data to w as a single line of JSON.
+func (f *NDJSONFormat) Encode(w io.Writer, data map[string]interface{}) error {
+	return f.EncodeAll(w, []map[string]interface{}{data})
+}
+
+// Load reads a newline-delimited JSON file holding a single object.
+func (f *NDJSONFormat) Load(path string) (map[string]interface{}, error) {
+	return loadFile(path, f.Decode)
+}
+
+// Save writes data to a newline-delimited JSON file as a single object.
+func (f *NDJSONFormat) Save(path string, data map[string]interface{}) error {
+	return saveFile(path, data, f.Encode)
+}
+
+// DecodeAll reads every document of a YAML stream from r. Empty documents,
+// such as the one after a trailing "---", are skipped.
+func (f *YAMLFormat) DecodeAll(r io.Reader) ([]map[string]interface{}, error) {
+	nodes, err := decodeYAMLDocuments(r)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]map[string]interface{}, 0, len(nodes))
+	for i, n := range nodes {
+		var doc map[string]interface{}
+		if err := n.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("yaml document %d: %w", i, err)
+		}
+		if doc == nil {
+			doc = make(map[string]interface{})
+		}
+		doc, err = canonicalDocument(fmt.Sprintf("yaml document %d", i), doc)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// EncodeAll writes docs to w as YAML documents separated by "---".
+func (f *YAMLFormat) EncodeAll(w io.Writer, docs []map[string]interface{}) error {
+	out, err := mergeYAMLDocuments(nil, docs)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// decodeYAMLDocuments returns the non-empty documents of a YAML stream.
+func decodeYAMLDocuments(r io.Reader) ([]*yaml.Node, error) {
+	dec := yaml.NewDecoder(r)
+	var nodes []*yaml.Node
+	for {
+		n := &yaml.Node{}
+		if err := dec.Decode(n); err != nil {
+			if err == io.EOF {
+				return nodes, nil
+			}
+			return nil, err
+		}
+		if len(n.Content) == 0 || n.Content[0].Tag == "!!null" {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+}
+
+// mergeYAMLDocuments encodes docs as a YAML stream. Each document whose
+// counterpart at the same position in existing is a mapping is merged into
+// it, keeping its comments and layout as mergeYAML does.
+func mergeYAMLDocuments(existing []byte, docs []map[string]interface{}) ([]byte, error) {
+	nodes, err := decodeYAMLDocuments(bytes.NewReader(existing))
+	if err != nil {
+		nodes = nil // rewrite unparsable files from scratch
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(detectYAMLIndent(existing))
+	for i, doc := range docs {
+		var v interface{} = doc
+		if i < len(nodes) && nodes[i].Content[0].Kind == yaml.MappingNode {
+			merged, err := mergeYAMLNode(nodes[i], doc)
+			if err != nil {
+				return nil, err
+			}
+			v = merged
+		}
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// singleDocument returns the only document of docs, an empty map if there
+// are none, or an error if there are several.
+func singleDocument(format string, docs []map[string]interface{}) (map[string]interface{}, error) {
+	switch len(docs) {
+	case 0:
+		return make(map[string]interface{}), nil
+	case 1:
+		return docs[0], nil
+	}
+	return nil, fmt.Errorf("%s: %w", format, errMultipleDocuments)
+}
+
+// LoadDocuments loads every document from a multi-document file in the
+// config directory, such as a YAML file with "---" separators or a
+// newline-delimited JSON (.ndjson) file.
+//
+// Example:
+//
+//	profiles, err := cfg.LoadDocuments("profiles.yaml")
+//	if err != nil {
+//		log.Printf("Error loading profiles: %v", err)
+//	}
+//	for _, profile := range profiles {
+//		fmt.Println(profile["name"])
+//	}
+func (s *Service) LoadDocuments(key string) ([]map[string]interface{}, error) {
+	path := filepath.Join(s.ConfigDir, key)
+	format, err := multiDocumentFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return format.DecodeAll(file)
+}
+
+// SaveDocuments saves a sequence of documents to a multi-document file in the
+// config directory. When saving YAML over an existing file, each document
+// keeps the comments and layout of the document at the same position.
+func (s *Service) SaveDocuments(key string, docs []map[string]interface{}) error {
+	path := filepath.Join(s.ConfigDir, key)
+	format, err := multiDocumentFormat(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := format.(*YAMLFormat); ok {
+		existing, err := readExisting(path)
+		if err != nil {
+			return err
+		}
+		out, err := mergeYAMLDocuments(existing, docs)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, out, 0644)
+	}
+	var buf bytes.Buffer
+	if err := format.EncodeAll(&buf, docs); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// multiDocumentFormat returns the format for path if it supports multiple
+// documents.
+func multiDocumentFormat(path string) (MultiDocumentFormat, error) {
+	format, err := resolveFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	multi, ok := format.(MultiDocumentFormat)
+	if !ok {
+		return nil, fmt.Errorf("config format %T does not support multiple documents", format)
+	}
+	return multi, nil
+}
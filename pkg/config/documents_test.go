@@ -0,0 +1,149 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDocumentsGood(t *testing.T) {
+	t.Run("Loads and saves YAML documents", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+
+		src := `# Development profile
+name: dev
+debug: true  # verbose logging
+---
+# Production profile
+name: prod
+debug: false
+---
+`
+		path := filepath.Join(s.ConfigDir, "profiles.yaml")
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		docs, err := s.LoadDocuments("profiles.yaml")
+		if err != nil {
+			t.Fatalf("LoadDocuments() failed: %v", err)
+		}
+		expected := []map[string]interface{}{
+			{"name": "dev", "debug": true},
+			{"name": "prod", "debug": false},
+		}
+		if !reflect.DeepEqual(expected, docs) {
+			t.Fatalf("Expected %v, got %v", expected, docs)
+		}
+
+		docs[1]["debug"] = true
+		docs = append(docs, map[string]interface{}{"name": "test"})
+		if err := s.SaveDocuments("profiles.yaml", docs); err != nil {
+			t.Fatalf("SaveDocuments() failed: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		want := `# Development profile
+name: dev
+debug: true # verbose logging
+---
+# Production profile
+name: prod
+debug: true
+---
+name: test
+`
+		if string(data) != want {
+			t.Errorf("Unexpected output.\nExpected:\n%s\nGot:\n%s", want, data)
+		}
+	})
+
+	t.Run("Loads and saves NDJSON documents", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+
+		docs := []map[string]interface{}{
+			{"env": "dev", "replicas": int64(1)},
+			{"env": "prod", "replicas": int64(3), "tags": []interface{}{"eu"}},
+		}
+		if err := s.SaveDocuments("envs.ndjson", docs); err != nil {
+			t.Fatalf("SaveDocuments() failed: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(s.ConfigDir, "envs.ndjson"))
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		want := "{\"env\":\"dev\",\"replicas\":1}\n{\"env\":\"prod\",\"replicas\":3,\"tags\":[\"eu\"]}\n"
+		if string(data) != want {
+			t.Errorf("Unexpected output.\nExpected:\n%s\nGot:\n%s", want, data)
+		}
+
+		got, err := s.LoadDocuments("envs.ndjson")
+		if err != nil {
+			t.Fatalf("LoadDocuments() failed: %v", err)
+		}
+		if !reflect.DeepEqual(docs, got) {
+			t.Errorf("Expected %v, got %v", docs, got)
+		}
+
+		if ext, err := DetectFormat(data); err != nil || ext != ".ndjson" {
+			t.Errorf("Expected NDJSON to be detected, got %q (%v)", ext, err)
+		}
+	})
+
+	t.Run("Skips blank lines", func(t *testing.T) {
+		docs, err := (&NDJSONFormat{}).DecodeAll(strings.NewReader("\n{\"a\": 1}\n\n{\"b\": 2}"))
+		if err != nil {
+			t.Fatalf("DecodeAll() failed: %v", err)
+		}
+		if len(docs) != 2 {
+			t.Errorf("Expected 2 documents, got %d", len(docs))
+		}
+	})
+}
+
+func TestDocumentsBad(t *testing.T) {
+	t.Run("Decode rejects multiple documents", func(t *testing.T) {
+		_, err := (&YAMLFormat{}).Decode(strings.NewReader("a: 1\n---\nb: 2\n"))
+		if !errors.Is(err, errMultipleDocuments) {
+			t.Errorf("Expected errMultipleDocuments for YAML, got %v", err)
+		}
+		_, err = (&NDJSONFormat{}).Decode(strings.NewReader("{\"a\": 1}\n{\"b\": 2}\n"))
+		if !errors.Is(err, errMultipleDocuments) {
+			t.Errorf("Expected errMultipleDocuments for NDJSON, got %v", err)
+		}
+	})
+
+	t.Run("Invalid NDJSON line", func(t *testing.T) {
+		_, err := (&NDJSONFormat{}).DecodeAll(strings.NewReader("{\"a\": 1}\n[1, 2]\n"))
+		if err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("Expected an error for line 2, got %v", err)
+		}
+	})
+
+	t.Run("Format without documents", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		if err := s.SaveDocuments("profiles.ini", nil); err == nil {
+			t.Error("Expected an error for a format without multiple documents")
+		}
+	})
+}
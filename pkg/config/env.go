@@ -0,0 +1,343 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// EnvFormat implements the ConfigFormat interface for dotenv (.env) files.
+// It understands the conventions used by most dotenv tooling:
+//
+//   - blank lines and lines starting with "#" are ignored
+//   - an optional "export " prefix before the key
+//   - unquoted values, which end at the first " #" inline comment
+//   - single-quoted values, which are taken literally and may span lines
+//   - double-quoted values, which may span lines and support the escapes
+//     \n, \r, \t, \", \\ and \$
+//
+// Unquoted and double-quoted values are interpolated: ${VAR}, $VAR and
+// ${VAR:-default} are replaced with the value of a key defined earlier in the
+// file or, failing that, the process environment. Single-quoted values are
+// never interpolated.
+type EnvFormat struct{}
+
+// Decode reads a dotenv document from r and decodes it into a map. All
+// values are returned as strings.
+func (f *EnvFormat) Decode(r io.Reader) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseEnv(string(data))
+}
+
+// Encode writes a map of key-value pairs to w in dotenv format. Keys are
+// written in sorted order. Values that contain anything other than plain
+// word characters are double-quoted and escaped so that Decode returns them
+// unchanged.
+func (f *EnvFormat) Encode(w io.Writer, data map[string]interface{}) error {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		if !isEnvKey(key) {
+			return fmt.Errorf("invalid dotenv key: %q", key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(quoteEnvValue(formatText(data[key])))
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Load reads a dotenv file from the given path and decodes it into a map.
+func (f *EnvFormat) Load(path string) (map[string]interface{}, error) {
+	return loadFile(path, f.Decode)
+}
+
+// Save writes a map of key-value pairs to a dotenv file.
+func (f *EnvFormat) Save(path string, data map[string]interface{}) error {
+	return saveFile(path, data, f.Encode)
+}
+
+// envParser holds the state used while decoding a dotenv document.
+type envParser struct {
+	src    string
+	pos    int
+	line   int
+	values map[string]interface{}
+}
+
+// parseEnv decodes the contents of a dotenv file.
+func parseEnv(src string) (map[string]interface{}, error) {
+	p := &envParser{src: src, line: 1, values: make(map[string]interface{})}
+	for p.pos < len(p.src) {
+		p.skipBlank()
+		if p.pos >= len(p.src) {
+			break
+		}
+		if p.peek() == '#' || p.peek() == '\n' {
+			p.skipLine()
+			continue
+		}
+		key, value, err := p.parseAssignment()
+		if err != nil {
+			return nil, fmt.Errorf("dotenv line %d: %w", p.line, err)
+		}
+		p.values[key] = value
+	}
+	return p.values, nil
+}
+
+// parseAssignment parses a single KEY=VALUE entry, including its trailing
+// comment and line terminator.
+func (p *envParser) parseAssignment() (string, string, error) {
+	if strings.HasPrefix(p.src[p.pos:], "export ") || strings.HasPrefix(p.src[p.pos:], "export\t") {
+		p.pos += len("export")
+		p.skipBlank()
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && isEnvKeyChar(p.src[p.pos]) {
+		p.pos++
+	}
+	key := p.src[start:p.pos]
+	if !isEnvKey(key) {
+		return "", "", fmt.Errorf("invalid key %q", key)
+	}
+
+	p.skipBlank()
+	if p.peek() != '=' {
+		return "", "", fmt.Errorf("expected '=' after key %q", key)
+	}
+	p.pos++
+	p.skipBlank()
+
+	var value string
+	var err error
+	switch p.peek() {
+	case '\'':
+		value, err = p.parseSingleQuoted()
+	case '"':
+		value, err = p.parseDoubleQuoted()
+	default:
+		value = p.parseUnquoted()
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	// Only whitespace or a comment may follow the value on the same line.
+	p.skipBlank()
+	switch p.peek() {
+	case 0, '\n':
+	case '#':
+	default:
+		return "", "", fmt.Errorf("unexpected character %q after value of %q", p.peek(), key)
+	}
+	p.skipLine()
+	return key, value, nil
+}
+
+// parseSingleQuoted reads a literal value enclosed in single quotes.
+func (p *envParser) parseSingleQuoted() (string, error) {
+	p.pos++ // opening quote
+	end := strings.IndexByte(p.src[p.pos:], '\'')
+	if end < 0 {
+		return "", fmt.Errorf("unterminated single-quoted value")
+	}
+	value := p.src[p.pos : p.pos+end]
+	p.line += strings.Count(value, "\n")
+	p.pos += end + 1
+	return value, nil
+}
+
+// parseDoubleQuoted reads a value enclosed in double quotes, resolving
+// escape sequences and variable references.
+func (p *envParser) parseDoubleQuoted() (string, error) {
+	p.pos++ // opening quote
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch c {
+		case '"':
+			p.pos++
+			return b.String(), nil
+		case '\\':
+			if p.pos+1 >= len(p.src) {
+				return "", fmt.Errorf("unterminated double-quoted value")
+			}
+			p.pos++
+			switch e := p.src[p.pos]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$', '`':
+				b.WriteByte(e)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(e)
+			}
+			p.pos++
+		case '$':
+			b.WriteString(p.expandVariable())
+		default:
+			if c == '\n' {
+				p.line++
+			}
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", fmt.Errorf("unterminated double-quoted value")
+}
+
+// parseUnquoted reads a bare value up to the end of the line or the start of
+// an inline comment, resolving variable references.
+func (p *envParser) parseUnquoted() string {
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '\n' || c == '\r' {
+			break
+		}
+		if c == '#' && (p.pos == 0 || p.src[p.pos-1] == ' ' || p.src[p.pos-1] == '\t') {
+			break
+		}
+		if c == '$' {
+			b.WriteString(p.expandVariable())
+			continue
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+	return strings.TrimRight(b.String(), " \t")
+}
+
+// expandVariable resolves a $VAR, ${VAR} or ${VAR:-default} reference at the
+// current position. A lone "$" is returned unchanged.
+func (p *envParser) expandVariable() string {
+	p.pos++ // '$'
+	if p.peek() == '{' {
+		end := strings.IndexByte(p.src[p.pos:], '}')
+		if end < 0 {
+			return "$"
+		}
+		ref := p.src[p.pos+1 : p.pos+end]
+		p.pos += end + 1
+		name, fallback, hasFallback := strings.Cut(ref, ":-")
+		if value, ok := p.lookup(name); ok && value != "" {
+			return value
+		}
+		if hasFallback {
+			return fallback
+		}
+		return ""
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && isEnvKeyChar(p.src[p.pos]) && p.src[p.pos] != '.' && p.src[p.pos] != '-' {
+		p.pos++
+	}
+	if start == p.pos {
+		return "$"
+	}
+	value, _ := p.lookup(p.src[start:p.pos])
+	return value
+}
+
+// lookup resolves a variable against keys defined earlier in the document and
+// then against the process environment.
+func (p *envParser) lookup(name string) (string, bool) {
+	if value, ok := p.values[name]; ok {
+		return fmt.Sprintf("%v", value), true
+	}
+	return os.LookupEnv(name)
+}
+
+func (p *envParser) peek() byte {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *envParser) skipBlank() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t' || p.src[p.pos] == '\r') {
+		p.pos++
+	}
+}
+
+func (p *envParser) skipLine() {
+	for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+		p.pos++
+	}
+	if p.pos < len(p.src) {
+		p.pos++
+		p.line++
+	}
+}
+
+// isEnvKey reports whether s is a valid dotenv key: a letter or underscore
+// followed by letters, digits, underscores, dots or dashes.
+func isEnvKey(s string) bool {
+	if s == "" || (s[0] >= '0' && s[0] <= '9') || s[0] == '.' || s[0] == '-' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isEnvKeyChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isEnvKeyChar(c byte) bool {
+	return c == '_' || c == '.' || c == '-' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// quoteEnvValue returns v unchanged if it can be written as a bare dotenv
+// value, and a double-quoted, escaped string otherwise.
+func quoteEnvValue(v string) string {
+	bare := v != ""
+	for i := 0; i < len(v) && bare; i++ {
+		c := v[i]
+		bare = isEnvKeyChar(c) || strings.IndexByte("/:@+,", c) >= 0
+	}
+	if bare {
+		return v
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; c {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '"', '\\', '$', '`':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEnvFormatGood(t *testing.T) {
+	t.Run("Parses quoting, exports, comments and multi-line values", func(t *testing.T) {
+		t.Setenv("CONFIG_TEST_HOME", "/home/test")
+
+		src := "# deployment settings\n" +
+			"export APP_NAME=demo\n" +
+			"PLAIN = hello world # trailing comment\n" +
+			"HASH=value#not-a-comment\n" +
+			"SINGLE='literal ${APP_NAME} \\n'\n" +
+			"DOUBLE=\"tab\\there \\\"quoted\\\" \\$APP_NAME\"\n" +
+			"MULTI=\"first\n" +
+			"second\"\n" +
+			"EMPTY=\n" +
+			"\n" +
+			"URL=http://${APP_NAME}.local:${PORT:-8080}/\n" +
+			"HOME_DIR=$CONFIG_TEST_HOME/data\n" +
+			"MISSING=${CONFIG_TEST_UNDEFINED}\n"
+
+		got, err := parseEnv(src)
+		if err != nil {
+			t.Fatalf("parseEnv() failed: %v", err)
+		}
+
+		expected := map[string]interface{}{
+			"APP_NAME": "demo",
+			"PLAIN":    "hello world",
+			"HASH":     "value#not-a-comment",
+			"SINGLE":   "literal ${APP_NAME} \\n",
+			"DOUBLE":   "tab\there \"quoted\" $APP_NAME",
+			"MULTI":    "first\nsecond",
+			"EMPTY":    "",
+			"URL":      "http://demo.local:8080/",
+			"HOME_DIR": "/home/test/data",
+			"MISSING":  "",
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Unexpected result.\nExpected: %q\nGot: %q", expected, got)
+		}
+	})
+
+	t.Run("Save escapes values so they round-trip", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "test.env")
+		data := map[string]interface{}{
+			"SIMPLE":  "value",
+			"SPACES":  "has spaces",
+			"SPECIAL": "quote\" backslash\\ dollar$HOME ${X} tick` hash #",
+			"NEWLINE": "line1\nline2",
+			"NUMBER":  42,
+			"EMPTY":   "",
+		}
+
+		f := &EnvFormat{}
+		if err := f.Save(path, data); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		got, err := f.Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+
+		expected := map[string]interface{}{
+			"SIMPLE":  "value",
+			"SPACES":  "has spaces",
+			"SPECIAL": "quote\" backslash\\ dollar$HOME ${X} tick` hash #",
+			"NEWLINE": "line1\nline2",
+			"NUMBER":  "42",
+			"EMPTY":   "",
+		}
+		if !reflect.DeepEqual(expected, got) {
+			raw, _ := os.ReadFile(path)
+			t.Errorf("Round trip mismatch.\nExpected: %q\nGot: %q\nFile:\n%s", expected, got, raw)
+		}
+	})
+}
+
+func TestEnvFormatBad(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+	}{
+		{"missing equals", "KEY value\n"},
+		{"invalid key", "1KEY=value\n"},
+		{"unterminated double quote", "KEY=\"value\n"},
+		{"unterminated single quote", "KEY='value\n"},
+		{"text after quoted value", "KEY=\"value\" extra\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseEnv(tc.src); err == nil {
+				t.Errorf("Expected an error for %q, but got nil", tc.src)
+			}
+		})
+	}
+
+	t.Run("Save rejects invalid keys", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "test.env")
+		f := &EnvFormat{}
+		if err := f.Save(path, map[string]interface{}{"bad key": "x"}); err == nil {
+			t.Errorf("Expected an error for an invalid key, but got nil")
+		}
+	})
+}
@@ -2,20 +2,25 @@ package config
 
 import (
 	"encoding/json"
-	"encoding/xml"
-	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"gopkg.in/ini.v1"
-	"gopkg.in/yaml.v2"
 )
 
 // ConfigFormat defines an interface for loading and saving configuration data in
 // various formats. Each format implementation is responsible for serializing and
-// deserializing data between a file and a map of key-value pairs.
+// deserializing data between a stream or file and a map of key-value pairs.
+//
+// Decode and Encode work on any reader or writer, such as an HTTP body, an
+// embedded file or stdin. Load and Save are helpers on top of them for files
+// on disk; some formats use the existing file in Save to keep its layout.
 type ConfigFormat interface {
+	// Decode reads data from r and returns it as a map.
+	Decode(r io.Reader) (map[string]interface{}, error)
+	// Encode writes the provided data map to w.
+	Encode(w io.Writer, data map[string]interface{}) error
 	// Load reads data from the specified path and returns it as a map.
 	Load(path string) (map[string]interface{}, error)
 	// Save writes the provided data map to the specified path.
@@ -26,51 +31,84 @@ type ConfigFormat interface {
 // methods to read from and write to files in JSON format.
 type JSONFormat struct{}
 
-// Load reads a JSON file from the given path and decodes it into a map.
+// Decode reads a JSON document from r and decodes it into a map.
 // The keys of the map are strings, and the values are of type interface{}.
-func (f *JSONFormat) Load(path string) (map[string]interface{}, error) {
-	data, err := os.ReadFile(path)
+func (f *JSONFormat) Decode(r io.Reader) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
 	var result map[string]interface{}
-	if err := json.Unmarshal(data, &result); err != nil {
+	if err := unmarshalJSON(data, &result); err != nil {
 		return nil, err
 	}
-	return result, nil
+	return canonicalDocument("json", result)
 }
 
-// Save encodes the provided map into JSON format and writes it to the given
-// path. The output is indented for readability.
-func (f *JSONFormat) Save(path string, data map[string]interface{}) error {
+// Encode writes the provided map to w as JSON. The output is indented for
+// readability.
+func (f *JSONFormat) Encode(w io.Writer, data map[string]interface{}) error {
 	jsonData, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, jsonData, 0644)
+	_, err = w.Write(jsonData)
+	return err
+}
+
+// Load reads a JSON file from the given path and decodes it into a map.
+func (f *JSONFormat) Load(path string) (map[string]interface{}, error) {
+	return loadFile(path, f.Decode)
+}
+
+// Save encodes the provided map into JSON format and writes it to the given
+// path.
+func (f *JSONFormat) Save(path string, data map[string]interface{}) error {
+	return saveFile(path, data, f.Encode)
 }
 
 // YAMLFormat implements the ConfigFormat interface for YAML files. It provides
 // methods to read from and write to files in YAML format.
+//
+// Saving over an existing file edits the file's node tree rather than
+// re-encoding the map, so hand-written comments, key order, anchors and
+// aliases, and quoting styles survive for every value that did not change.
 type YAMLFormat struct{}
 
-// Load reads a YAML file from the given path and decodes it into a map.
-func (f *YAMLFormat) Load(path string) (map[string]interface{}, error) {
-	data, err := os.ReadFile(path)
+// Decode reads a YAML document from r and decodes it into a map. It returns
+// an error if r holds several documents separated by "---"; use DecodeAll
+// to read those.
+func (f *YAMLFormat) Decode(r io.Reader) (map[string]interface{}, error) {
+	docs, err := f.DecodeAll(r)
 	if err != nil {
 		return nil, err
 	}
-	var result map[string]interface{}
-	if err := yaml.Unmarshal(data, &result); err != nil {
-		return nil, err
+	return singleDocument("yaml", docs)
+}
+
+// Encode writes the provided map to w as YAML.
+func (f *YAMLFormat) Encode(w io.Writer, data map[string]interface{}) error {
+	yamlData, err := mergeYAML(nil, data)
+	if err != nil {
+		return err
 	}
-	return result, nil
+	_, err = w.Write(yamlData)
+	return err
+}
+
+// Load reads a YAML file from the given path and decodes it into a map.
+func (f *YAMLFormat) Load(path string) (map[string]interface{}, error) {
+	return loadFile(path, f.Decode)
 }
 
 // Save encodes the provided map into YAML format and writes it to the given
-// path.
+// path. If the file already exists, only the values that changed are updated.
 func (f *YAMLFormat) Save(path string, data map[string]interface{}) error {
-	yamlData, err := yaml.Marshal(data)
+	existing, err := readExisting(path)
+	if err != nil {
+		return err
+	}
+	yamlData, err := mergeYAML(existing, data)
 	if err != nil {
 		return err
 	}
@@ -79,127 +117,121 @@ func (f *YAMLFormat) Save(path string, data map[string]interface{}) error {
 
 // INIFormat implements the ConfigFormat interface for INI files. It handles
 // the structured format of INI files, including sections and keys.
+//
+// Keys in the default section map to top-level entries and every other
+// section maps to a nested map. Dotted section names ("[server.tls]") map to
+// deeper levels of nesting. Values are typed when loaded: true/false become
+// bools, whole numbers int64, decimals float64 and repeated "key[]" entries
+// a list. A map saved with Save loads back unchanged provided that:
+//
+//   - top-level and section keys do not contain dots
+//   - lists only hold scalar values and are not empty
+//   - values are strings, bools, numbers or nil (which loads as "")
+//
+// Strings that would otherwise load as another type are written in double
+// quotes to keep them strings.
 type INIFormat struct{}
 
-// Load reads an INI file and converts its sections and keys into a single map.
-// Keys in the map are formed by concatenating the section name and key name with
-// a dot (e.g., "section.key").
-func (f *INIFormat) Load(path string) (map[string]interface{}, error) {
-	cfg, err := ini.Load(path)
+// Decode reads an INI document from r and converts its sections and keys
+// into a nested map of typed values.
+func (f *INIFormat) Decode(r io.Reader) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
-	result := make(map[string]interface{})
-	for _, section := range cfg.Sections() {
-		for _, key := range section.Keys() {
-			result[section.Name()+"."+key.Name()] = key.Value()
-		}
+	cfg, err := ini.LoadSources(iniOptions, data)
+	if err != nil {
+		return nil, err
 	}
-	return result, nil
+	return decodeINI(cfg)
 }
 
-// Save writes a map of key-value pairs to an INI file. Keys in the map are
-// split by a dot to determine the section and key for the INI file.
-func (f *INIFormat) Save(path string, data map[string]interface{}) error {
-	cfg := ini.Empty()
-	for key, value := range data {
-		parts := strings.SplitN(key, ".", 2)
-		section := ini.DefaultSection
-		keyName := parts[0]
-		if len(parts) > 1 {
-			section = parts[0]
-			keyName = parts[1]
-		}
-		if _, err := cfg.Section(section).NewKey(keyName, fmt.Sprintf("%v", value)); err != nil {
-			return err
-		}
+// Encode writes a map to w as INI. Scalar values at the top level are
+// written to the default section and nested maps become sections. For
+// compatibility with flat data, a top-level key in "section.key" form is
+// written to that section.
+func (f *INIFormat) Encode(w io.Writer, data map[string]interface{}) error {
+	cfg := ini.Empty(iniOptions)
+	if err := encodeINI(cfg, data); err != nil {
+		return err
 	}
-	return cfg.SaveTo(path)
+	_, err := cfg.WriteTo(w)
+	return err
 }
 
-// XMLFormat implements the ConfigFormat interface for XML files. It uses a
-// simple structure with a root "config" element containing a series of "entry"
-// elements, each with a "key" and "value".
-type XMLFormat struct{}
+// Load reads an INI file and converts it into a nested map.
+func (f *INIFormat) Load(path string) (map[string]interface{}, error) {
+	return loadFile(path, f.Decode)
+}
 
-// xmlEntry is a helper struct for marshaling and unmarshaling XML data.
-type xmlEntry struct {
-	Key   string `xml:"key"`
-	Value string `xml:"value"`
+// Save writes a map to an INI file.
+func (f *INIFormat) Save(path string, data map[string]interface{}) error {
+	return saveFile(path, data, f.Encode)
 }
 
-// Load reads an XML file and parses it into a map. It expects the XML to have
-// a specific structure as defined by the xmlEntry struct.
-func (f *XMLFormat) Load(path string) (map[string]interface{}, error) {
-	data, err := os.ReadFile(path)
+// XMLFormat implements the ConfigFormat interface for XML files. Data is
+// written inside a root "config" element: nested maps become nested elements,
+// lists become repeated elements and map keys starting with "@" with string
+// values become attributes (with "#text" holding the element's text); other
+// "@" keys are written as entry elements. Non-string values carry a type hint
+// so they load back with the same type:
+//
+//	<config>
+//	  <name>demo</name>
+//	  <port type="int">8080</port>
+//	  <tags>a</tags>
+//	  <tags>b</tags>
+//	  <server tls="on">
+//	    <host>localhost</host>
+//	  </server>
+//	</config>
+//
+// Files in the original flat layout, a list of <entry><key/><value/></entry>
+// elements, can still be loaded.
+type XMLFormat struct{}
+
+// Decode reads an XML document from r and parses it into a map. Elements
+// without a type hint load as strings, or as maps if they have child
+// elements or attributes.
+func (f *XMLFormat) Decode(r io.Reader) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
-	var v struct {
-		XMLName xml.Name   `xml:"config"`
-		Entries []xmlEntry `xml:"entry"`
-	}
-	if err := xml.Unmarshal(data, &v); err != nil {
+	root, err := parseXML(data)
+	if err != nil {
 		return nil, err
 	}
-	result := make(map[string]interface{})
-	for _, entry := range v.Entries {
-		result[entry.Key] = entry.Value
-	}
-	return result, nil
+	return decodeXMLDocument(root)
 }
 
-// Save writes a map of key-value pairs to an XML file. The data is structured
-// with a root "config" element and child "entry" elements.
-func (f *XMLFormat) Save(path string, data map[string]interface{}) error {
-	var entries []xmlEntry
-	for key, value := range data {
-		entries = append(entries, xmlEntry{
-			Key:   key,
-			Value: fmt.Sprintf("%v", value),
-		})
-	}
-	xmlData, err := xml.MarshalIndent(struct {
-		XMLName xml.Name   `xml:"config"`
-		Entries []xmlEntry `xml:"entry"`
-	}{Entries: entries}, "", "  ")
+// Encode writes a map to w as XML. Keys that are not valid element names are
+// written as <entry key="..."> elements. A list with a single element is
+// marked with list="true" and an empty list with type="list".
+func (f *XMLFormat) Encode(w io.Writer, data map[string]interface{}) error {
+	xmlData, err := encodeXMLDocument(data)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, xmlData, 0644)
+	_, err = w.Write(xmlData)
+	return err
 }
 
-// GetConfigFormat returns a ConfigFormat implementation based on the file
-// extension of the provided path. This allows the config service to dynamically
-// handle different file formats.
-//
-// Example:
-//
-//	format, err := GetConfigFormat("settings.json")
-//	if err != nil {
-//		log.Fatal(err)
-//	}
-//	// format is now a JSONFormat
-func GetConfigFormat(path string) (ConfigFormat, error) {
-	ext := strings.ToLower(filepath.Ext(path))
-	switch ext {
-	case ".json":
-		return &JSONFormat{}, nil
-	case ".yaml", ".yml":
-		return &YAMLFormat{}, nil
-	case ".ini":
-		return &INIFormat{}, nil
-	case ".xml":
-		return &XMLFormat{}, nil
-	default:
-		return nil, fmt.Errorf("unsupported config format: %s", ext)
-	}
+// Load reads an XML file and parses it into a map.
+func (f *XMLFormat) Load(path string) (map[string]interface{}, error) {
+	return loadFile(path, f.Decode)
+}
+
+// Save writes a map to an XML file.
+func (f *XMLFormat) Save(path string, data map[string]interface{}) error {
+	return saveFile(path, data, f.Encode)
 }
 
 // SaveKeyValues saves a map of key-value pairs to a file in the config
-// directory. The file format is determined by the extension of the `key`
-// parameter. This method is a convenient way to store structured data in a
-// format of choice.
+// directory. The file format is looked up in the format registry by the
+// extension of the `key` parameter. A key without an extension is saved in
+// the format detected from the file's existing content. This method is a
+// convenient way to store structured data in a format of choice.
 //
 // Example:
 //
@@ -209,17 +241,19 @@ func GetConfigFormat(path string) (ConfigFormat, error) {
 //		log.Printf("Error saving database config: %v", err)
 //	}
 func (s *Service) SaveKeyValues(key string, data map[string]interface{}) error {
-	format, err := GetConfigFormat(key)
+	filePath := filepath.Join(s.ConfigDir, key)
+	format, err := resolveFormat(filePath)
 	if err != nil {
 		return err
 	}
-	filePath := filepath.Join(s.ConfigDir, key)
 	return format.Save(filePath, data)
 }
 
 // LoadKeyValues loads a map of key-value pairs from a file in the config
-// directory. The file format is determined by the extension of the `key`
-// parameter. This allows for easy retrieval of data stored in various formats.
+// directory. The file format is looked up in the format registry by the
+// extension of the `key` parameter, or detected from the file's content when
+// the key has no extension. This allows for easy retrieval of data stored in
+// various formats.
 //
 // Example:
 //
@@ -227,13 +261,13 @@ func (s *Service) SaveKeyValues(key string, data map[string]interface{}) error {
 //	if err != nil {
 //		log.Printf("Error loading database config: %v", err)
 //	}
-//	port, ok := dbConfig["port"].(int)
+//	port, ok := dbConfig["port"].(int64)
 //	// ...
 func (s *Service) LoadKeyValues(key string) (map[string]interface{}, error) {
-	format, err := GetConfigFormat(key)
+	filePath := filepath.Join(s.ConfigDir, key)
+	format, err := resolveFormat(filePath)
 	if err != nil {
 		return nil, err
 	}
-	filePath := filepath.Join(s.ConfigDir, key)
 	return format.Load(filePath)
 }
@@ -19,7 +19,7 @@ func TestConfigFormats(t *testing.T) {
 
 	testData := map[string]interface{}{
 		"key1": "value1",
-		"key2": 123.0,
+		"key2": int64(123),
 		"key3": true,
 	}
 
@@ -28,9 +28,18 @@ func TestConfigFormats(t *testing.T) {
 		filename string
 	}{
 		{"json", "test.json"},
+		{"jsonc", "test.jsonc"},
+		{"json5", "test.json5"},
 		{"yaml", "test.yaml"},
 		{"ini", "test.ini"},
 		{"xml", "test.xml"},
+		{"env", "test.env"},
+		{"properties", "test.properties"},
+		{"hcl", "test.hcl"},
+		{"plist", "test.plist"},
+		{"cbor", "test.cbor"},
+		{"msgpack", "test.msgpack"},
+		{"ndjson", "test.ndjson"},
 	}
 
 	for _, tc := range testCases {
@@ -47,24 +56,9 @@ func TestConfigFormats(t *testing.T) {
 				t.Fatalf("LoadKeyValues failed for %s: %v", tc.format, err)
 			}
 
-			// INI format saves everything as strings, so we need to adjust the expected data
 			expectedData := testData
-			if tc.format == "ini" {
-				expectedData = map[string]interface{}{
-					"DEFAULT.key1": "value1",
-					"DEFAULT.key2": "123",
-					"DEFAULT.key3": "true",
-				}
-			}
-
-			if tc.format == "yaml" {
-				// The yaml library unmarshals numbers as int if they don't have a decimal point.
-				if val, ok := loadedData["key2"].(int); ok {
-					loadedData["key2"] = float64(val)
-				}
-			}
 
-			if tc.format == "xml" {
+			if tc.format == "env" || tc.format == "properties" {
 				expectedData = map[string]interface{}{
 					"key1": "value1",
 					"key2": "123",
@@ -86,10 +80,21 @@ func TestGetConfigFormat(t *testing.T) {
 		expectError   bool
 	}{
 		{"config.json", &JSONFormat{}, false},
+		{"config.jsonc", &JSONCFormat{}, false},
+		{"config.json5", &JSON5Format{}, false},
 		{"config.yaml", &YAMLFormat{}, false},
 		{"config.yml", &YAMLFormat{}, false},
 		{"config.ini", &INIFormat{}, false},
 		{"config.xml", &XMLFormat{}, false},
+		{".env", &EnvFormat{}, false},
+		{"production.env", &EnvFormat{}, false},
+		{"config.properties", &PropertiesFormat{}, false},
+		{"service.hcl", &HCLFormat{}, false},
+		{"Info.plist", &PlistFormat{}, false},
+		{"cache.cbor", &CBORFormat{}, false},
+		{"cache.msgpack", &MsgpackFormat{}, false},
+		{"events.ndjson", &NDJSONFormat{}, false},
+		{"events.jsonl", &NDJSONFormat{}, false},
 		{"config.txt", nil, true},
 	}
 
@@ -0,0 +1,359 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/parser"
+	"github.com/hashicorp/hcl/hcl/printer"
+	"github.com/hashicorp/hcl/hcl/token"
+)
+
+// HCLFormat implements the ConfigFormat interface for HashiCorp Configuration
+// Language (HCL) files. Attributes become map entries, blocks become nested
+// maps keyed by their type and labels, and lists become slices:
+//
+//	name = "api"
+//	service "web" {
+//	  port = 80
+//	}
+//
+// decodes to {"name": "api", "service": {"web": {"port": 80}}}. A block that
+// is repeated with the same type and labels decodes to a slice of maps.
+type HCLFormat struct{}
+
+// Decode reads an HCL document from r and decodes it into a map. Whole
+// numbers are returned as int64 and decimals as float64.
+func (f *HCLFormat) Decode(r io.Reader) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	file, err := parser.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := file.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("hcl: unexpected root node %T", file.Node)
+	}
+	return decodeHCLObjectList(list)
+}
+
+// Encode writes the provided map to w as HCL. Scalars and lists of scalars
+// are written as attributes, nested maps as blocks (using labels where every
+// entry is itself a map) and slices of maps as repeated blocks. The output
+// is formatted with the canonical HCL printer.
+func (f *HCLFormat) Encode(w io.Writer, data map[string]interface{}) error {
+	var b strings.Builder
+	if err := writeHCLBody(&b, reflect.ValueOf(data)); err != nil {
+		return err
+	}
+	formatted, err := printer.Format([]byte(b.String()))
+	if err != nil {
+		return fmt.Errorf("hcl: failed to format output: %w", err)
+	}
+	_, err = w.Write(formatted)
+	return err
+}
+
+// Load reads an HCL file from the given path and decodes it into a map.
+func (f *HCLFormat) Load(path string) (map[string]interface{}, error) {
+	return loadFile(path, f.Decode)
+}
+
+// Save encodes the provided map as HCL and writes it to the given path.
+func (f *HCLFormat) Save(path string, data map[string]interface{}) error {
+	return saveFile(path, data, f.Encode)
+}
+
+// decodeHCLObjectList converts a list of HCL items into a map.
+func decodeHCLObjectList(list *ast.ObjectList) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for _, item := range list.Items {
+		keys := make([]string, len(item.Keys))
+		for i, key := range item.Keys {
+			keys[i] = hclKeyText(key.Token)
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("hcl: item without a key at %s", item.Pos())
+		}
+
+		value, err := decodeHCLNode(item.Val)
+		if err != nil {
+			return nil, err
+		}
+
+		if item.Assign.IsValid() {
+			result[keys[0]] = value
+			continue
+		}
+
+		// Blocks: walk (and create) the maps for the type and labels, then
+		// add the body. A repeated block turns the entry into a list.
+		parent := result
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := parent[key].(map[string]interface{})
+			if !ok {
+				if parent[key] != nil {
+					return nil, fmt.Errorf("hcl: block %q at %s conflicts with a value of the same name", key, item.Pos())
+				}
+				child = make(map[string]interface{})
+				parent[key] = child
+			}
+			parent = child
+		}
+		last := keys[len(keys)-1]
+		switch existing := parent[last].(type) {
+		case nil:
+			parent[last] = value
+		case []interface{}:
+			parent[last] = append(existing, value)
+		default:
+			parent[last] = []interface{}{existing, value}
+		}
+	}
+	return result, nil
+}
+
+// decodeHCLNode converts a single HCL value node into its Go equivalent.
+func decodeHCLNode(node ast.Node) (interface{}, error) {
+	switch n := node.(type) {
+	case *ast.ObjectType:
+		return decodeHCLObjectList(n.List)
+	case *ast.ListType:
+		list := make([]interface{}, 0, len(n.List))
+		for _, elem := range n.List {
+			value, err := decodeHCLNode(elem)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	case *ast.LiteralType:
+		switch n.Token.Type {
+		case token.NUMBER:
+			v, err := strconv.ParseInt(n.Token.Text, 0, 64)
+			if err != nil {
+				return nil, fmt.Errorf("hcl: invalid number %q at %s: %w", n.Token.Text, n.Pos(), err)
+			}
+			return v, nil
+		case token.FLOAT:
+			v, err := strconv.ParseFloat(n.Token.Text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("hcl: invalid number %q at %s: %w", n.Token.Text, n.Pos(), err)
+			}
+			return v, nil
+		case token.BOOL, token.STRING, token.HEREDOC:
+			return n.Token.Value(), nil
+		}
+		return nil, fmt.Errorf("hcl: unsupported literal %q at %s", n.Token.Text, n.Pos())
+	}
+	return nil, fmt.Errorf("hcl: unsupported node %T", node)
+}
+
+// hclKeyText returns the unquoted text of an object key.
+func hclKeyText(tok token.Token) string {
+	if tok.Type == token.STRING {
+		if s, ok := tok.Value().(string); ok {
+			return s
+		}
+	}
+	return tok.Text
+}
+
+// writeHCLBody writes the entries of a map as HCL attributes followed by
+// blocks, both in sorted key order.
+func writeHCLBody(b *strings.Builder, m reflect.Value) error {
+	m = indirectValue(m)
+	if m.Kind() != reflect.Map {
+		return fmt.Errorf("hcl: cannot encode %s as a block body", m.Kind())
+	}
+
+	keys := make([]string, 0, m.Len())
+	values := make(map[string]reflect.Value, m.Len())
+	for _, k := range m.MapKeys() {
+		key := fmt.Sprint(k.Interface())
+		keys = append(keys, key)
+		values[key] = indirectValue(m.MapIndex(k))
+	}
+	sort.Strings(keys)
+
+	var blocks []string
+	for _, key := range keys {
+		if isHCLBlock(values[key]) {
+			blocks = append(blocks, key)
+			continue
+		}
+		b.WriteString(hclKey(key))
+		b.WriteString(" = ")
+		if err := writeHCLValue(b, values[key]); err != nil {
+			return fmt.Errorf("hcl: key %q: %w", key, err)
+		}
+		b.WriteByte('\n')
+	}
+
+	for _, key := range blocks {
+		if err := writeHCLBlock(b, key, values[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeHCLBlock writes a nested map (or a list of maps) as one or more
+// blocks. When every entry of the map is itself a map, the entries are
+// written as labelled blocks (`key "label" { ... }`).
+func writeHCLBlock(b *strings.Builder, key string, v reflect.Value) error {
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		for i := 0; i < v.Len(); i++ {
+			if err := writeHCLBlock(b, key, indirectValue(v.Index(i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if labelled := hclLabelledBlocks(v); labelled != nil {
+		for _, label := range labelled {
+			body := indirectValue(v.MapIndex(reflect.ValueOf(label)))
+			fmt.Fprintf(b, "%s %s {\n", hclKey(key), strconv.Quote(label))
+			if err := writeHCLBody(b, body); err != nil {
+				return err
+			}
+			b.WriteString("}\n")
+		}
+		return nil
+	}
+
+	fmt.Fprintf(b, "%s {\n", hclKey(key))
+	if err := writeHCLBody(b, v); err != nil {
+		return err
+	}
+	b.WriteString("}\n")
+	return nil
+}
+
+// hclLabelledBlocks returns the sorted labels of v if it is a non-empty map
+// with string keys whose values are all non-empty maps, and nil otherwise.
+func hclLabelledBlocks(v reflect.Value) []string {
+	if v.Kind() != reflect.Map || v.Len() == 0 || v.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+	labels := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		child := indirectValue(v.MapIndex(k))
+		if child.Kind() != reflect.Map || child.Len() == 0 {
+			return nil
+		}
+		labels = append(labels, k.String())
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// writeHCLValue writes an attribute value.
+func writeHCLValue(b *strings.Builder, v reflect.Value) error {
+	if s, ok := timeText(v); ok {
+		b.WriteString(strconv.Quote(s))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Invalid:
+		return fmt.Errorf("cannot encode a nil value")
+	case reflect.String:
+		b.WriteString(strconv.Quote(v.String()))
+	case reflect.Bool:
+		b.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		b.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("cannot encode %v: HCL numbers must be finite", f)
+		}
+		s := strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eE") {
+			s += ".0" // keep the value a float when it is read back
+		}
+		b.WriteString(s)
+	case reflect.Slice, reflect.Array:
+		b.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			if err := writeHCLValue(b, indirectValue(v.Index(i))); err != nil {
+				return err
+			}
+		}
+		b.WriteString("]")
+	case reflect.Map:
+		b.WriteString("{\n")
+		if err := writeHCLBody(b, v); err != nil {
+			return err
+		}
+		b.WriteString("}")
+	default:
+		return fmt.Errorf("cannot encode value of type %s", v.Type())
+	}
+	return nil
+}
+
+// isHCLBlock reports whether v should be written as a block rather than an
+// attribute: maps, and lists of two or more maps. A single block loads back
+// as a map, so a list holding one map is written as a list attribute.
+func isHCLBlock(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Map:
+		return true
+	case reflect.Slice, reflect.Array:
+		if v.Len() < 2 {
+			return false
+		}
+		for i := 0; i < v.Len(); i++ {
+			if indirectValue(v.Index(i)).Kind() != reflect.Map {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// indirectValue unwraps interfaces and pointers, returning the zero Value for
+// nil.
+func indirectValue(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// hclKey returns key as a bare identifier where possible and as a quoted
+// string otherwise.
+func hclKey(key string) string {
+	if key == "" {
+		return `""`
+	}
+	for i, c := range key {
+		isLetter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		isDigit := c >= '0' && c <= '9'
+		if !isLetter && (i == 0 || (!isDigit && c != '-' && c != '.')) {
+			return strconv.Quote(key)
+		}
+	}
+	return key
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHCLFormatGood(t *testing.T) {
+	t.Run("Decodes attributes, blocks and lists", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "service.hcl")
+		src := `# Service definition
+name    = "api"
+port    = 8080
+ratio   = 0.5
+enabled = true
+tags    = ["a", "b"]
+
+service "web" {
+  port = 80
+
+  health {
+    path = "/healthz"
+  }
+}
+
+service "admin" {
+  port = 81
+}
+
+rule {
+  allow = "10.0.0.0/8"
+}
+
+rule {
+  allow = "192.168.0.0/16"
+}
+`
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatalf("Failed to write HCL file: %v", err)
+		}
+
+		got, err := (&HCLFormat{}).Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+
+		expected := map[string]interface{}{
+			"name":    "api",
+			"port":    int64(8080),
+			"ratio":   0.5,
+			"enabled": true,
+			"tags":    []interface{}{"a", "b"},
+			"service": map[string]interface{}{
+				"web": map[string]interface{}{
+					"port": int64(80),
+					"health": map[string]interface{}{
+						"path": "/healthz",
+					},
+				},
+				"admin": map[string]interface{}{
+					"port": int64(81),
+				},
+			},
+			"rule": []interface{}{
+				map[string]interface{}{"allow": "10.0.0.0/8"},
+				map[string]interface{}{"allow": "192.168.0.0/16"},
+			},
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Unexpected result.\nExpected: %#v\nGot: %#v", expected, got)
+		}
+	})
+
+	t.Run("Encodes idiomatic HCL that round-trips", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "service.hcl")
+		data := map[string]interface{}{
+			"name":       "api",
+			"port":       int64(8080),
+			"ratio":      1.0,
+			"quoted key": "needs \"quotes\"",
+			"tags":       []interface{}{"a", "b"},
+			"empty":      map[string]interface{}{},
+			"service": map[string]interface{}{
+				"web": map[string]interface{}{"port": int64(80)},
+			},
+			"rule": []interface{}{
+				map[string]interface{}{"allow": "10.0.0.0/8"},
+				map[string]interface{}{"allow": "192.168.0.0/16"},
+			},
+			"single": []interface{}{
+				map[string]interface{}{"allow": "x"},
+			},
+		}
+
+		f := &HCLFormat{}
+		if err := f.Save(path, data); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read saved file: %v", err)
+		}
+		if !strings.Contains(string(raw), `service "web" {`) {
+			t.Errorf("Expected a labelled block in output, got:\n%s", raw)
+		}
+
+		got, err := f.Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v\n%s", err, raw)
+		}
+		if !reflect.DeepEqual(data, got) {
+			t.Errorf("Round trip mismatch.\nExpected: %#v\nGot: %#v\nFile:\n%s", data, got, raw)
+		}
+	})
+}
+
+func TestHCLFormatBad(t *testing.T) {
+	t.Run("Invalid syntax", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "bad.hcl")
+		if err := os.WriteFile(path, []byte("service {\n  port = \n"), 0644); err != nil {
+			t.Fatalf("Failed to write HCL file: %v", err)
+		}
+		if _, err := (&HCLFormat{}).Load(path); err == nil {
+			t.Errorf("Expected an error for invalid HCL, but got nil")
+		}
+	})
+
+	t.Run("Infinity and NaN", func(t *testing.T) {
+		for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+			var buf bytes.Buffer
+			if err := (&HCLFormat{}).Encode(&buf, map[string]interface{}{"ratio": f}); err == nil {
+				t.Errorf("Expected an error for %v, but got %q", f, buf.String())
+			}
+		}
+	})
+
+	t.Run("Block conflicts with a value", func(t *testing.T) {
+		src := "service = \"web\"\nservice \"api\" {\n  port = 80\n}\n"
+		if _, err := (&HCLFormat{}).Decode(strings.NewReader(src)); err == nil {
+			t.Errorf("Expected an error for a labelled block named like an attribute, but got nil")
+		}
+	})
+
+	t.Run("Nil value", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nil.hcl")
+		if err := (&HCLFormat{}).Save(path, map[string]interface{}{"key": nil}); err == nil {
+			t.Errorf("Expected an error for a nil value, but got nil")
+		}
+	})
+}
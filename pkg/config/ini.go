@@ -0,0 +1,240 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// iniOptions are the options used to read and write INI files. Quotes are
+// preserved so that quoted values can be told apart from typed ones, and
+// shadow keys carry the elements of lists.
+var iniOptions = ini.LoadOptions{
+	AllowShadows:               true,
+	AllowDuplicateShadowValues: true,
+	PreserveSurroundedQuote:    true,
+	SpaceBeforeInlineComment:   true,
+}
+
+// iniListSuffix marks a key whose repeated entries form a list.
+const iniListSuffix = "[]"
+
+var (
+	iniInt   = regexp.MustCompile(`^[-+]?(0|[1-9][0-9]*)$`)
+	iniFloat = regexp.MustCompile(`^[-+]?([0-9]+\.[0-9]*|\.[0-9]+|[0-9]+)([eE][-+]?[0-9]+)?$`)
+)
+
+// decodeINI converts a parsed INI file into a nested map.
+func decodeINI(cfg *ini.File) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for _, section := range cfg.Sections() {
+		target := result
+		if section.Name() != ini.DefaultSection {
+			for _, part := range strings.Split(section.Name(), ".") {
+				switch existing := target[part].(type) {
+				case map[string]interface{}:
+					target = existing
+				case nil:
+					child := make(map[string]interface{})
+					target[part] = child
+					target = child
+				default:
+					return nil, fmt.Errorf("ini: section [%s] conflicts with key %q", section.Name(), part)
+				}
+			}
+		}
+
+		for _, key := range section.Keys() {
+			name := key.Name()
+			if strings.HasSuffix(name, iniListSuffix) {
+				var list []interface{}
+				for _, v := range key.ValueWithShadows() {
+					list = append(list, inferINIValue(v))
+				}
+				target[strings.TrimSuffix(name, iniListSuffix)] = list
+				continue
+			}
+			if _, ok := target[name].(map[string]interface{}); ok {
+				return nil, fmt.Errorf("ini: key %q in section [%s] conflicts with a section", name, section.Name())
+			}
+			target[name] = inferINIValue(key.Value())
+		}
+	}
+	return result, nil
+}
+
+// inferINIValue converts a raw INI value into a bool, int64, float64 or
+// string. Quoted values are always strings, with one level of quotes removed.
+func inferINIValue(raw string) interface{} {
+	if len(raw) >= 2 && (raw[0] == '"' || raw[0] == '\'') && raw[len(raw)-1] == raw[0] {
+		return raw[1 : len(raw)-1]
+	}
+	switch raw {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if iniInt.MatchString(raw) {
+		if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return n
+		}
+	}
+	if iniFloat.MatchString(raw) && strings.ContainsAny(raw, ".eE") {
+		if n, err := strconv.ParseFloat(raw, 64); err == nil {
+			return n
+		}
+	}
+	return raw
+}
+
+// encodeINI writes a nested map into an empty INI file.
+func encodeINI(cfg *ini.File, data map[string]interface{}) error {
+	// Flat "section.key" entries are grouped into their section first so
+	// they merge with any nested map for the same section.
+	root := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		root[key] = value
+	}
+	for key, value := range data {
+		if isINISection(reflect.ValueOf(value)) || !strings.Contains(key, ".") {
+			continue
+		}
+		delete(root, key)
+		sectionName, keyName, _ := strings.Cut(key, ".")
+		section, ok := root[sectionName].(map[string]interface{})
+		if !ok {
+			if root[sectionName] != nil {
+				return fmt.Errorf("ini: key %q conflicts with key %q", key, sectionName)
+			}
+			section = make(map[string]interface{})
+			root[sectionName] = section
+		}
+		section[keyName] = value
+	}
+	return encodeINISection(cfg, ini.DefaultSection, reflect.ValueOf(root))
+}
+
+// encodeINISection writes the scalar and list entries of m to the named
+// section and its nested maps to child sections.
+func encodeINISection(cfg *ini.File, name string, m reflect.Value) error {
+	section, err := cfg.NewSection(name)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, m.Len())
+	values := make(map[string]reflect.Value, m.Len())
+	for _, k := range m.MapKeys() {
+		key := fmt.Sprint(k.Interface())
+		keys = append(keys, key)
+		values[key] = indirectValue(m.MapIndex(k))
+	}
+	sort.Strings(keys)
+
+	var children []string
+	for _, key := range keys {
+		value := values[key]
+		switch {
+		case isINISection(value):
+			children = append(children, key)
+		case value.Kind() == reflect.Slice || value.Kind() == reflect.Array:
+			if err := encodeINIList(section, key, value); err != nil {
+				return err
+			}
+		default:
+			s, err := encodeINIValue(value)
+			if err != nil {
+				return fmt.Errorf("ini: key %q: %w", key, err)
+			}
+			if _, err := section.NewKey(key, s); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, key := range children {
+		childName := key
+		if name != ini.DefaultSection {
+			childName = name + "." + key
+		}
+		if err := encodeINISection(cfg, childName, values[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeINIList writes a list as repeated "key[]" entries. Empty lists are
+// omitted because INI cannot represent them.
+func encodeINIList(section *ini.Section, key string, list reflect.Value) error {
+	if list.Kind() == reflect.Slice && list.Type().Elem().Kind() == reflect.Uint8 {
+		_, err := section.NewKey(key, string(list.Bytes()))
+		return err
+	}
+	var k *ini.Key
+	for i := 0; i < list.Len(); i++ {
+		s, err := encodeINIValue(indirectValue(list.Index(i)))
+		if err != nil {
+			return fmt.Errorf("ini: key %q: %w", key, err)
+		}
+		if k == nil {
+			if k, err = section.NewKey(key+iniListSuffix, s); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := k.AddShadow(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeINIValue formats a scalar so that inferINIValue returns an equal
+// value.
+func encodeINIValue(v reflect.Value) (string, error) {
+	if s, ok := timeText(v); ok {
+		return encodeINIValue(reflect.ValueOf(s))
+	}
+	switch v.Kind() {
+	case reflect.Invalid:
+		return "", nil
+	case reflect.String:
+		s := v.String()
+		if _, isString := inferINIValue(s).(string); !isString || inferINIValue(s) != s || strings.TrimSpace(s) != s {
+			return `"` + s + `"`, nil
+		}
+		return s, nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			// INI has no such numbers; "NaN", "+Inf" and "-Inf" are
+			// read back as text.
+			return strconv.FormatFloat(f, 'g', -1, 64), nil
+		}
+		s := strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eE") {
+			s += ".0"
+		}
+		return s, nil
+	}
+	return "", fmt.Errorf("cannot encode value of type %s", v.Type())
+}
+
+// isINISection reports whether v is a map and so should become a section.
+func isINISection(v reflect.Value) bool {
+	return indirectValue(v).Kind() == reflect.Map
+}
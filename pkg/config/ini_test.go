@@ -0,0 +1,149 @@
+package config
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestINIFormatGood(t *testing.T) {
+	t.Run("Round-trips nested maps, lists and typed values", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.ini")
+		data := map[string]interface{}{
+			"name":    "demo",
+			"debug":   true,
+			"workers": int64(4),
+			"ratio":   0.75,
+			"version": "1.0",
+			"enabled": "true",
+			"padded":  "  spaced  ",
+			"quoted":  `"already quoted"`,
+			"comment": "a # b ; c",
+			"empty":   "",
+			"server": map[string]interface{}{
+				"host":  "localhost",
+				"port":  int64(8080),
+				"hosts": []interface{}{"a", "b", "a"},
+				"ports": []interface{}{int64(80), int64(443)},
+				"tls": map[string]interface{}{
+					"enabled": false,
+				},
+			},
+			"empty_section": map[string]interface{}{},
+		}
+
+		f := &INIFormat{}
+		if err := f.Save(path, data); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		got, err := f.Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		if !reflect.DeepEqual(data, got) {
+			raw, _ := os.ReadFile(path)
+			t.Errorf("Round trip mismatch.\nExpected: %#v\nGot: %#v\nFile:\n%s", data, got, raw)
+		}
+	})
+
+	t.Run("Infers types in hand-written files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.ini")
+		src := `name = demo
+zip = 01234
+[server]
+port = 8080
+ratio = .5
+secure = true
+label = "8080"
+tags[] = web
+tags[] = api
+
+[server.tls]
+cert = /etc/cert.pem
+`
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		got, err := (&INIFormat{}).Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		expected := map[string]interface{}{
+			"name": "demo",
+			"zip":  "01234",
+			"server": map[string]interface{}{
+				"port":   int64(8080),
+				"ratio":  0.5,
+				"secure": true,
+				"label":  "8080",
+				"tags":   []interface{}{"web", "api"},
+				"tls": map[string]interface{}{
+					"cert": "/etc/cert.pem",
+				},
+			},
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Unexpected result.\nExpected: %#v\nGot: %#v", expected, got)
+		}
+	})
+
+	t.Run("Writes infinity and NaN as text", func(t *testing.T) {
+		var buf bytes.Buffer
+		data := map[string]interface{}{"nan": math.NaN(), "max": math.Inf(1), "min": math.Inf(-1), "whole": 2.0}
+		if err := (&INIFormat{}).Encode(&buf, data); err != nil {
+			t.Fatalf("Encode() failed: %v", err)
+		}
+		for _, want := range []string{"nan   = NaN\n", "max   = +Inf\n", "min   = -Inf\n", "whole = 2.0\n"} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("Expected %q in output:\n%s", want, buf.String())
+			}
+		}
+	})
+
+	t.Run("Save accepts flat section.key entries", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.ini")
+		data := map[string]interface{}{
+			"general.setting1": "value1",
+			"network.retries":  3,
+		}
+		f := &INIFormat{}
+		if err := f.Save(path, data); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		got, err := f.Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		expected := map[string]interface{}{
+			"general": map[string]interface{}{"setting1": "value1"},
+			"network": map[string]interface{}{"retries": int64(3)},
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Unexpected result.\nExpected: %#v\nGot: %#v", expected, got)
+		}
+	})
+}
+
+func TestINIFormatBad(t *testing.T) {
+	t.Run("Section conflicts with a key", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.ini")
+		if err := os.WriteFile(path, []byte("server = x\n[server]\nport = 1\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if _, err := (&INIFormat{}).Load(path); err == nil {
+			t.Errorf("Expected an error for a conflicting section, but got nil")
+		}
+	})
+
+	t.Run("Nested lists are not supported", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.ini")
+		data := map[string]interface{}{"matrix": []interface{}{[]interface{}{1}}}
+		if err := (&INIFormat{}).Save(path, data); err == nil {
+			t.Errorf("Expected an error for a nested list, but got nil")
+		}
+	})
+}
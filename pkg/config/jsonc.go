@@ -0,0 +1,902 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// JSONCFormat implements the ConfigFormat interface for JSON with comments
+// (.jsonc). It accepts // and /* */ comments and trailing commas in objects
+// and arrays.
+//
+// Save preserves the layout of an existing file: comments, whitespace and key
+// order are kept for every key that still exists, only changed values are
+// rewritten, removed keys are dropped and new keys are appended in sorted
+// order. When the file does not exist yet, Save writes indented JSON.
+type JSONCFormat struct{}
+
+// Decode reads a JSONC document from r and decodes it into a map.
+func (f *JSONCFormat) Decode(r io.Reader) (map[string]interface{}, error) {
+	return decodeJSONC(r, false)
+}
+
+// Encode writes the provided map to w as indented JSON.
+func (f *JSONCFormat) Encode(w io.Writer, data map[string]interface{}) error {
+	return encodeJSONC(w, data, false)
+}
+
+// Load reads a JSONC file and decodes it into a map.
+func (f *JSONCFormat) Load(path string) (map[string]interface{}, error) {
+	return loadFile(path, f.Decode)
+}
+
+// Save writes the provided map to a JSONC file, preserving the comments and
+// key order of the existing file where possible.
+func (f *JSONCFormat) Save(path string, data map[string]interface{}) error {
+	return saveJSONC(path, data, false)
+}
+
+// JSON5Format implements the ConfigFormat interface for JSON5 (.json5) files.
+// In addition to everything JSONCFormat accepts, JSON5 allows unquoted
+// identifier keys, single-quoted strings, hexadecimal numbers, leading or
+// trailing decimal points, explicit plus signs, Infinity and NaN.
+//
+// Save preserves the layout of an existing file in the same way as
+// JSONCFormat, including the original spelling of unchanged values.
+type JSON5Format struct{}
+
+// Load reads a JSON5 file and decodes it into a map.
+func (f *JSON5Format) Load(path string) (map[string]interface{}, error) {
+	return loadFile(path, f.Decode)
+}
+
+// Save writes the provided map to a JSON5 file, preserving the comments and
+// key order of the existing file where possible.
+func (f *JSON5Format) Save(path string, data map[string]interface{}) error {
+	return saveJSONC(path, data, true)
+}
+
+// Decode reads a JSON5 document from r and decodes it into a map.
+func (f *JSON5Format) Decode(r io.Reader) (map[string]interface{}, error) {
+	return decodeJSONC(r, true)
+}
+
+// Encode writes the provided map to w as indented JSON, which is valid JSON5.
+func (f *JSON5Format) Encode(w io.Writer, data map[string]interface{}) error {
+	return encodeJSONC(w, data, true)
+}
+
+// decodeJSONC reads and decodes a JSONC or JSON5 document whose root is an
+// object.
+func decodeJSONC(r io.Reader, json5 bool) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseJSONC(string(data), json5)
+	if err != nil {
+		return nil, err
+	}
+	result, ok := doc.value.decode().(map[string]interface{})
+	if !ok {
+		return nil, errors.New("jsonc: document root must be an object")
+	}
+	return result, nil
+}
+
+// encodeJSONC writes data to w as a new document.
+func encodeJSONC(w io.Writer, data map[string]interface{}, json5 bool) error {
+	out, err := mergeJSONC(nil, data, json5)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// saveJSONC writes data to path, merging it into the existing document when
+// the file exists and can be parsed.
+func saveJSONC(path string, data map[string]interface{}, json5 bool) error {
+	existing, err := readExisting(path)
+	if err != nil {
+		return err
+	}
+	out, err := mergeJSONC(existing, data, json5)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, out, 0644)
+}
+
+// mergeJSONC returns the encoding of data, reusing the layout and comments of
+// the existing document. If existing is empty or cannot be parsed, data is
+// encoded as indented JSON. Infinity and NaN are written as JSON5 literals,
+// and are an error in JSONC.
+func mergeJSONC(existing []byte, data interface{}, json5 bool) ([]byte, error) {
+	canonical, err := Canonicalize(data)
+	if err != nil {
+		// Structs, such as the Service itself, are encoded by their JSON
+		// form.
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		var decoded interface{}
+		if err := unmarshalJSON(raw, &decoded); err != nil {
+			return nil, err
+		}
+		if canonical, err = Canonicalize(decoded); err != nil {
+			return nil, err
+		}
+	}
+	if !json5 {
+		if err := checkFinite("", canonical); err != nil {
+			return nil, err
+		}
+	}
+
+	doc, err := parseJSONC(string(existing), json5)
+	if len(existing) == 0 || err != nil {
+		var b strings.Builder
+		writeJSONCValue(&b, canonical, "", "  ", false)
+		return []byte(b.String()), nil
+	}
+
+	unit := detectIndentUnit(string(existing))
+	doc.value = doc.value.merge(canonical, "", unit)
+	var b strings.Builder
+	doc.render(&b, false)
+	return []byte(b.String()), nil
+}
+
+// jsoncKind identifies the type of a jsoncNode.
+type jsoncKind int
+
+const (
+	jsoncScalar jsoncKind = iota
+	jsoncObject
+	jsoncArray
+)
+
+// jsoncNode is a value in a parsed JSONC/JSON5 document. Scalars keep their
+// original source text so unchanged values are written back verbatim.
+type jsoncNode struct {
+	kind          jsoncKind
+	raw           string       // source text of a scalar
+	value         interface{}  // decoded value of a scalar
+	items         []*jsoncItem // object members or array elements
+	closing       string       // whitespace and comments before the closing bracket
+	trailingComma bool         // whether the last item is followed by a comma
+}
+
+// jsoncItem is an object member or array element together with the
+// whitespace and comments surrounding it. For array elements key and keyRaw
+// are empty.
+type jsoncItem struct {
+	lead        string // trivia before the key (or value)
+	key         string // decoded key
+	keyRaw      string // key as written in the source
+	mid         string // trivia and colon between key and value
+	value       *jsoncNode
+	beforeComma string // trivia between the value and its comma
+	comma       bool   // whether the source had a comma after the value
+	trailing    string // comments on the same line after the comma
+}
+
+// render writes an item, adding a comma if requested.
+func (it *jsoncItem) render(b *strings.Builder, comma bool) {
+	b.WriteString(it.lead)
+	b.WriteString(it.keyRaw)
+	b.WriteString(it.mid)
+	it.value.render(b)
+	b.WriteString(it.beforeComma)
+	if comma {
+		b.WriteByte(',')
+	}
+	b.WriteString(it.trailing)
+}
+
+// render writes the node back out in its source form.
+func (n *jsoncNode) render(b *strings.Builder) {
+	switch n.kind {
+	case jsoncScalar:
+		b.WriteString(n.raw)
+		return
+	case jsoncObject:
+		b.WriteByte('{')
+	case jsoncArray:
+		b.WriteByte('[')
+	}
+	for i, it := range n.items {
+		it.render(b, i < len(n.items)-1 || n.trailingComma)
+	}
+	b.WriteString(n.closing)
+	if n.kind == jsoncObject {
+		b.WriteByte('}')
+	} else {
+		b.WriteByte(']')
+	}
+}
+
+// decode returns the Go value represented by the node, using the same types
+// as encoding/json.
+func (n *jsoncNode) decode() interface{} {
+	switch n.kind {
+	case jsoncObject:
+		m := make(map[string]interface{}, len(n.items))
+		for _, it := range n.items {
+			m[it.key] = it.value.decode()
+		}
+		return m
+	case jsoncArray:
+		list := make([]interface{}, 0, len(n.items))
+		for _, it := range n.items {
+			list = append(list, it.value.decode())
+		}
+		return list
+	}
+	return n.value
+}
+
+// merge returns a node representing v that reuses n's layout where the shape
+// still matches. indent is the indentation of the line holding n and unit is
+// one level of indentation.
+func (n *jsoncNode) merge(v interface{}, indent, unit string) *jsoncNode {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if n.kind != jsoncObject {
+			break
+		}
+		childIndent, inline := n.childLayout(indent, unit)
+		hadItems := len(n.items) > 0
+		seen := make(map[string]bool, len(val))
+		items := n.items[:0:0]
+		for _, it := range n.items {
+			newVal, ok := val[it.key]
+			if !ok || seen[it.key] {
+				continue
+			}
+			seen[it.key] = true
+			it.value = it.value.merge(newVal, itemIndent(it.lead, childIndent), unit)
+			items = append(items, it)
+		}
+		var added []string
+		for key := range val {
+			if !seen[key] {
+				added = append(added, key)
+			}
+		}
+		sort.Strings(added)
+		for _, key := range added {
+			keyRaw, _ := json.Marshal(key)
+			items = append(items, &jsoncItem{
+				lead:   n.newItemLead(childIndent, inline),
+				key:    key,
+				keyRaw: string(keyRaw),
+				mid:    ": ",
+				value:  freshJSONCNode(val[key], childIndent, unit, inline),
+			})
+		}
+		n.items = items
+		n.fixClosing(hadItems, indent)
+		return n
+	case []interface{}:
+		if n.kind != jsoncArray {
+			break
+		}
+		childIndent, inline := n.childLayout(indent, unit)
+		hadItems := len(n.items) > 0
+		if len(n.items) > len(val) {
+			n.items = n.items[:len(val)]
+		}
+		for i, it := range n.items {
+			it.value = it.value.merge(val[i], itemIndent(it.lead, childIndent), unit)
+		}
+		for _, elem := range val[len(n.items):] {
+			n.items = append(n.items, &jsoncItem{
+				lead:  n.newItemLead(childIndent, inline),
+				value: freshJSONCNode(elem, childIndent, unit, inline),
+			})
+		}
+		n.fixClosing(hadItems, indent)
+		return n
+	default:
+		if n.kind == jsoncScalar && (convertedEqual(reflect.ValueOf(n.value), reflect.ValueOf(v)) || isNaN(n.value) && isNaN(v)) {
+			return n
+		}
+	}
+	return freshJSONCNode(v, indent, unit, false)
+}
+
+// childLayout returns the indentation used by the node's items and whether
+// they are written on a single line.
+func (n *jsoncNode) childLayout(indent, unit string) (string, bool) {
+	for _, it := range n.items {
+		if i := strings.LastIndexByte(it.lead, '\n'); i >= 0 {
+			return it.lead[i+1:], false
+		}
+	}
+	return indent + unit, len(n.items) > 0
+}
+
+// newItemLead returns the leading trivia for an appended item.
+func (n *jsoncNode) newItemLead(childIndent string, inline bool) string {
+	if inline {
+		return " "
+	}
+	return "\n" + childIndent
+}
+
+// fixClosing moves the closing bracket onto its own line when items were
+// added to a previously empty container.
+func (n *jsoncNode) fixClosing(hadItems bool, indent string) {
+	if !hadItems && len(n.items) > 0 && !strings.Contains(n.closing, "\n") {
+		n.closing += "\n" + indent
+	}
+}
+
+// itemIndent returns the indentation of the line an item starts on.
+func itemIndent(lead, fallback string) string {
+	if i := strings.LastIndexByte(lead, '\n'); i >= 0 && strings.TrimSpace(lead[i+1:]) == "" {
+		return lead[i+1:]
+	}
+	return fallback
+}
+
+// freshJSONCNode encodes v as a new node with no source layout to preserve.
+func freshJSONCNode(v interface{}, indent, unit string, inline bool) *jsoncNode {
+	var b strings.Builder
+	writeJSONCValue(&b, v, indent, unit, inline)
+	return &jsoncNode{kind: jsoncScalar, raw: b.String(), value: v}
+}
+
+// writeJSONCValue writes a canonical value as json.MarshalIndent does with
+// prefix indent, or as json.Marshal does if inline is set, except that
+// infinite and NaN floats are written as the JSON5 literals Infinity,
+// -Infinity and NaN.
+func writeJSONCValue(b *strings.Builder, v interface{}, indent, unit string, inline bool) {
+	newline := func(indent string) {
+		if !inline {
+			b.WriteString("\n" + indent)
+		}
+	}
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) == 0 {
+			b.WriteString("{}")
+			return
+		}
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		b.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			newline(indent + unit)
+			keyRaw, _ := json.Marshal(key)
+			b.Write(keyRaw)
+			b.WriteByte(':')
+			if !inline {
+				b.WriteByte(' ')
+			}
+			writeJSONCValue(b, val[key], indent+unit, unit, inline)
+		}
+		newline(indent)
+		b.WriteByte('}')
+	case []interface{}:
+		if len(val) == 0 {
+			b.WriteString("[]")
+			return
+		}
+		b.WriteByte('[')
+		for i, elem := range val {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			newline(indent + unit)
+			writeJSONCValue(b, elem, indent+unit, unit, inline)
+		}
+		newline(indent)
+		b.WriteByte(']')
+	case float64:
+		switch {
+		case math.IsNaN(val):
+			b.WriteString("NaN")
+		case math.IsInf(val, 1):
+			b.WriteString("Infinity")
+		case math.IsInf(val, -1):
+			b.WriteString("-Infinity")
+		default:
+			raw, _ := json.Marshal(val)
+			b.Write(raw)
+		}
+	default:
+		raw, _ := json.Marshal(val)
+		b.Write(raw)
+	}
+}
+
+// checkFinite returns an error if the canonical value v, found at path,
+// holds an infinite or NaN float, which JSON cannot represent.
+func checkFinite(path string, v interface{}) error {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for key, child := range val {
+			if err := checkFinite(joinPath(path, key), child); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, elem := range val {
+			if err := checkFinite(fmt.Sprintf("%s[%d]", path, i), elem); err != nil {
+				return err
+			}
+		}
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return canonicalError(path, "%v cannot be written in JSON; use JSON5", val)
+		}
+	}
+	return nil
+}
+
+// isNaN reports whether v is a NaN float, which is not equal to itself.
+func isNaN(v interface{}) bool {
+	f, ok := v.(float64)
+	return ok && math.IsNaN(f)
+}
+
+// detectIndentUnit returns the indentation of the first indented line in
+// src, defaulting to two spaces.
+func detectIndentUnit(src string) string {
+	for _, line := range strings.Split(src, "\n")[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) < len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "  "
+}
+
+// jsoncParser parses JSONC, or JSON5 when json5 is set, into a tree of
+// jsoncNodes that retains all whitespace and comments.
+type jsoncParser struct {
+	src   string
+	pos   int
+	json5 bool
+}
+
+// parseJSONC parses a complete document. The returned item holds the root
+// value, with the document's leading and trailing trivia in lead and
+// trailing.
+func parseJSONC(src string, json5 bool) (*jsoncItem, error) {
+	p := &jsoncParser{src: src, json5: json5}
+	lead := ""
+	if strings.HasPrefix(src, "\ufeff") {
+		lead = "\ufeff"
+		p.pos = len(lead)
+	}
+	t, err := p.trivia()
+	if err != nil {
+		return nil, err
+	}
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	tail, err := p.trivia()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q after document", p.src[p.pos])
+	}
+	return &jsoncItem{lead: lead + t, value: value, trailing: tail}, nil
+}
+
+func (p *jsoncParser) errorf(format string, args ...interface{}) error {
+	line := 1 + strings.Count(p.src[:p.pos], "\n")
+	col := p.pos - strings.LastIndexByte(p.src[:p.pos], '\n')
+	return fmt.Errorf("jsonc: line %d, column %d: %s", line, col, fmt.Sprintf(format, args...))
+}
+
+// trivia consumes whitespace and comments.
+func (p *jsoncParser) trivia() (string, error) {
+	start := p.pos
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.pos++
+		case p.json5 && (c == '\v' || c == '\f'):
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "//"):
+			p.skipLineComment()
+		case strings.HasPrefix(p.src[p.pos:], "/*"):
+			if err := p.skipBlockComment(); err != nil {
+				return "", err
+			}
+		default:
+			return p.src[start:p.pos], nil
+		}
+	}
+	return p.src[start:p.pos], nil
+}
+
+// sameLineTrivia consumes spaces and comments up to (but not including) the
+// end of the current line.
+func (p *jsoncParser) sameLineTrivia() (string, error) {
+	start := p.pos
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t':
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "//"):
+			p.skipLineComment()
+		case strings.HasPrefix(p.src[p.pos:], "/*"):
+			if err := p.skipBlockComment(); err != nil {
+				return "", err
+			}
+		default:
+			return p.src[start:p.pos], nil
+		}
+	}
+	return p.src[start:p.pos], nil
+}
+
+func (p *jsoncParser) skipLineComment() {
+	if end := strings.IndexByte(p.src[p.pos:], '\n'); end >= 0 {
+		p.pos += end
+	} else {
+		p.pos = len(p.src)
+	}
+}
+
+func (p *jsoncParser) skipBlockComment() error {
+	end := strings.Index(p.src[p.pos+2:], "*/")
+	if end < 0 {
+		return p.errorf("unterminated block comment")
+	}
+	p.pos += end + 4
+	return nil
+}
+
+// value parses any JSON value.
+func (p *jsoncParser) value() (*jsoncNode, error) {
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("unexpected end of input")
+	}
+	switch c := p.src[p.pos]; {
+	case c == '{':
+		return p.container(jsoncObject)
+	case c == '[':
+		return p.container(jsoncArray)
+	case c == '"' || (p.json5 && c == '\''):
+		start := p.pos
+		s, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		return &jsoncNode{kind: jsoncScalar, raw: p.src[start:p.pos], value: s}, nil
+	}
+	return p.literal()
+}
+
+// container parses an object or an array.
+func (p *jsoncParser) container(kind jsoncKind) (*jsoncNode, error) {
+	closer := byte('}')
+	if kind == jsoncArray {
+		closer = ']'
+	}
+	n := &jsoncNode{kind: kind}
+	p.pos++ // opening bracket
+
+	pending := ""
+	for {
+		lead, err := p.trivia()
+		if err != nil {
+			return nil, err
+		}
+		lead = pending + lead
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unexpected end of input")
+		}
+		if p.src[p.pos] == closer {
+			p.pos++
+			n.closing = lead
+			n.trailingComma = len(n.items) > 0 && n.items[len(n.items)-1].comma
+			return n, nil
+		}
+		if len(n.items) > 0 && !n.items[len(n.items)-1].comma {
+			return nil, p.errorf("expected ',' or %q", closer)
+		}
+
+		it := &jsoncItem{lead: lead}
+		if kind == jsoncObject {
+			if err := p.member(it); err != nil {
+				return nil, err
+			}
+		}
+		if it.value, err = p.value(); err != nil {
+			return nil, err
+		}
+
+		trailing, err := p.sameLineTrivia()
+		if err != nil {
+			return nil, err
+		}
+		rest, err := p.trivia()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.src) && p.src[p.pos] == ',' {
+			p.pos++
+			it.beforeComma = trailing + rest
+			it.comma = true
+			if it.trailing, err = p.sameLineTrivia(); err != nil {
+				return nil, err
+			}
+			pending = ""
+		} else {
+			it.trailing = trailing
+			pending = rest
+		}
+		n.items = append(n.items, it)
+	}
+}
+
+// member parses an object key and the colon that follows it.
+func (p *jsoncParser) member(it *jsoncItem) error {
+	start := p.pos
+	if p.pos < len(p.src) && (p.src[p.pos] == '"' || (p.json5 && p.src[p.pos] == '\'')) {
+		key, err := p.string()
+		if err != nil {
+			return err
+		}
+		it.key = key
+	} else if p.json5 {
+		for p.pos < len(p.src) {
+			r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			if !(r == '_' || r == '$' || unicode.IsLetter(r) || (p.pos > start && unicode.IsDigit(r))) {
+				break
+			}
+			p.pos += size
+		}
+		if p.pos == start {
+			return p.errorf("expected object key")
+		}
+		it.key = p.src[start:p.pos]
+	} else {
+		return p.errorf("expected string key")
+	}
+	it.keyRaw = p.src[start:p.pos]
+
+	before, err := p.trivia()
+	if err != nil {
+		return err
+	}
+	if p.pos >= len(p.src) || p.src[p.pos] != ':' {
+		return p.errorf("expected ':' after object key")
+	}
+	p.pos++
+	after, err := p.trivia()
+	if err != nil {
+		return err
+	}
+	it.mid = before + ":" + after
+	return nil
+}
+
+// string parses a quoted string and returns its decoded value.
+func (p *jsoncParser) string() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c == '\n' || c == '\r':
+			return "", p.errorf("newline in string")
+		case c < 0x20 && c != '\t':
+			return "", p.errorf("control character in string")
+		case c != '\\':
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+
+		p.pos++ // backslash
+		if p.pos >= len(p.src) {
+			return "", p.errorf("unterminated string")
+		}
+		e := p.src[p.pos]
+		p.pos++
+		switch e {
+		case '"', '\\', '/':
+			b.WriteByte(e)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			r, err := p.unicodeEscape()
+			if err != nil {
+				return "", err
+			}
+			b.WriteRune(r)
+		default:
+			if !p.json5 {
+				return "", p.errorf("invalid escape sequence \\%c", e)
+			}
+			switch e {
+			case '\'':
+				b.WriteByte('\'')
+			case 'v':
+				b.WriteByte('\v')
+			case '0':
+				b.WriteByte(0)
+			case 'x':
+				if p.pos+2 > len(p.src) {
+					return "", p.errorf("invalid \\x escape")
+				}
+				n, err := strconv.ParseUint(p.src[p.pos:p.pos+2], 16, 8)
+				if err != nil {
+					return "", p.errorf("invalid \\x escape")
+				}
+				b.WriteRune(rune(n))
+				p.pos += 2
+			case '\n':
+				// Line continuation.
+			case '\r':
+				if p.pos < len(p.src) && p.src[p.pos] == '\n' {
+					p.pos++
+				}
+			default:
+				b.WriteByte(e)
+			}
+		}
+	}
+}
+
+// unicodeEscape decodes the hex digits of a \u escape, combining surrogate
+// pairs.
+func (p *jsoncParser) unicodeEscape() (rune, error) {
+	read := func() (rune, error) {
+		if p.pos+4 > len(p.src) {
+			return 0, p.errorf("invalid \\u escape")
+		}
+		n, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 16)
+		if err != nil {
+			return 0, p.errorf("invalid \\u escape")
+		}
+		p.pos += 4
+		return rune(n), nil
+	}
+	r, err := read()
+	if err != nil {
+		return 0, err
+	}
+	if utf16.IsSurrogate(r) && strings.HasPrefix(p.src[p.pos:], `\u`) {
+		p.pos += 2
+		r2, err := read()
+		if err != nil {
+			return 0, err
+		}
+		return utf16.DecodeRune(r, r2), nil
+	}
+	return r, nil
+}
+
+// json5Number matches decimal JSON5 numbers, which may have a leading sign
+// and leading or trailing decimal points.
+var json5Number = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// literal parses true, false, null and numbers.
+func (p *jsoncParser) literal() (*jsoncNode, error) {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if !(c == '+' || c == '-' || c == '.' || c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')) {
+			break
+		}
+		p.pos++
+	}
+	raw := p.src[start:p.pos]
+	node := &jsoncNode{kind: jsoncScalar, raw: raw}
+
+	switch raw {
+	case "":
+		return nil, p.errorf("unexpected %q", p.src[p.pos])
+	case "true":
+		node.value = true
+		return node, nil
+	case "false":
+		node.value = false
+		return node, nil
+	case "null":
+		return node, nil
+	}
+
+	if !p.json5 {
+		if !json.Valid([]byte(raw)) {
+			p.pos = start
+			return nil, p.errorf("invalid value %q", raw)
+		}
+		node.value = parseJSONNumber(raw)
+		return node, nil
+	}
+
+	unsigned := strings.TrimLeft(raw, "+-")
+	negative := strings.HasPrefix(raw, "-")
+	if len(raw)-len(unsigned) > 1 {
+		p.pos = start
+		return nil, p.errorf("invalid number %q", raw)
+	}
+	switch {
+	case unsigned == "Infinity":
+		node.value = math.Inf(1)
+		if negative {
+			node.value = math.Inf(-1)
+		}
+	case unsigned == "NaN":
+		node.value = math.NaN()
+	case strings.HasPrefix(unsigned, "0x") || strings.HasPrefix(unsigned, "0X"):
+		n, err := strconv.ParseUint(unsigned[2:], 16, 64)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid number %q", raw)
+		}
+		switch {
+		case n <= math.MaxInt64 && negative:
+			node.value = -int64(n)
+		case n <= math.MaxInt64:
+			node.value = int64(n)
+		case negative:
+			node.value = -float64(n)
+		default:
+			node.value = float64(n)
+		}
+	case json5Number.MatchString(raw):
+		node.value = parseJSONNumber(raw)
+	default:
+		p.pos = start
+		return nil, p.errorf("invalid value %q", raw)
+	}
+	return node, nil
+}
+
+// parseJSONNumber parses a decimal number literal, returning an int64 for
+// integers that fit and a float64 otherwise.
+func parseJSONNumber(raw string) interface{} {
+	if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return n
+	}
+	f, _ := strconv.ParseFloat(raw, 64)
+	return f
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONCFormatGood(t *testing.T) {
+	t.Run("Parses comments and trailing commas", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "settings.jsonc")
+		src := `// Application settings
+{
+  /* display */
+  "theme": "dark", // or "light"
+  "sizes": [1, 2, 3,],
+  "nested": {"enabled": true,},
+}
+`
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		got, err := (&JSONCFormat{}).Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		expected := map[string]interface{}{
+			"theme":  "dark",
+			"sizes":  []interface{}{int64(1), int64(2), int64(3)},
+			"nested": map[string]interface{}{"enabled": true},
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Unexpected result.\nExpected: %v\nGot: %v", expected, got)
+		}
+	})
+
+	t.Run("Save preserves comments and key order", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "settings.jsonc")
+		src := `// Application settings
+{
+  /* display */
+  "theme": "dark", // or "light"
+  "language": "en",
+  "removed": 1,
+  "nested": {
+    // inner comment
+    "enabled": true,
+  },
+  "list": [
+    1, // first
+    2,
+  ]
+}
+`
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		f := &JSONCFormat{}
+		data, err := f.Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		data["theme"] = "light"
+		delete(data, "removed")
+		data["nested"].(map[string]interface{})["level"] = 3
+		data["list"] = []interface{}{1.0, 5.0, 6.0}
+		data["added"] = "new"
+
+		if err := f.Save(path, data); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+
+		expected := `// Application settings
+{
+  /* display */
+  "theme": "light", // or "light"
+  "language": "en",
+  "nested": {
+    // inner comment
+    "enabled": true,
+    "level": 3,
+  },
+  "list": [
+    1, // first
+    5,
+    6,
+  ],
+  "added": "new"
+}
+`
+		if string(raw) != expected {
+			t.Errorf("Unexpected output.\nExpected:\n%s\nGot:\n%s", expected, raw)
+		}
+	})
+
+	t.Run("Save without an existing file writes JSON", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "settings.jsonc")
+		if err := (&JSONCFormat{}).Save(path, map[string]interface{}{"a": 1}); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		raw, _ := os.ReadFile(path)
+		if string(raw) != "{\n  \"a\": 1\n}" {
+			t.Errorf("Unexpected output: %q", raw)
+		}
+	})
+}
+
+func TestJSON5FormatGood(t *testing.T) {
+	t.Run("Parses JSON5 syntax", func(t *testing.T) {
+		src := `{
+  unquoted: 'single \'quoted\'',
+  hex: 0xFF,
+  leading: .5,
+  trailing: 5.,
+  positive: +1,
+  inf: -Infinity,
+  multi: 'line \
+continued',
+}`
+		doc, err := parseJSONC(src, true)
+		if err != nil {
+			t.Fatalf("parseJSONC() failed: %v", err)
+		}
+		got := doc.value.decode().(map[string]interface{})
+		expected := map[string]interface{}{
+			"unquoted": "single 'quoted'",
+			"hex":      int64(255),
+			"leading":  0.5,
+			"trailing": 5.0,
+			"positive": int64(1),
+			"inf":      math.Inf(-1),
+			"multi":    "line continued",
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Unexpected result.\nExpected: %v\nGot: %v", expected, got)
+		}
+	})
+
+	t.Run("Save keeps the spelling of unchanged values", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "settings.json5")
+		src := "{\n  // port in hex\n  port: 0x1F90,\n  name: 'demo',\n}\n"
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		f := &JSON5Format{}
+		data, err := f.Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		data["name"] = "changed"
+		if err := f.Save(path, data); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		raw, _ := os.ReadFile(path)
+		expected := "{\n  // port in hex\n  port: 0x1F90,\n  name: \"changed\",\n}\n"
+		if string(raw) != expected {
+			t.Errorf("Unexpected output.\nExpected:\n%s\nGot:\n%s", expected, raw)
+		}
+	})
+
+	t.Run("Infinity and NaN survive a round trip", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "limits.json5")
+		src := "{\n  max: Infinity,\n  min: -Infinity,\n  missing: NaN,\n}\n"
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		f := &JSON5Format{}
+		data, err := f.Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		data["ratio"] = math.NaN()
+		data["limits"] = []interface{}{math.Inf(1), 1.5}
+		if err := f.Save(path, data); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		raw, _ := os.ReadFile(path)
+		expected := "{\n  max: Infinity,\n  min: -Infinity,\n  missing: NaN,\n  \"limits\": [\n    Infinity,\n    1.5\n  ],\n  \"ratio\": NaN,\n}\n"
+		if string(raw) != expected {
+			t.Errorf("Unexpected output.\nExpected:\n%s\nGot:\n%s", expected, raw)
+		}
+
+		var b bytes.Buffer
+		if err := f.Encode(&b, data); err != nil {
+			t.Fatalf("Encode() failed: %v", err)
+		}
+		got, err := f.Decode(&b)
+		if err != nil {
+			t.Fatalf("Decode() failed: %v", err)
+		}
+		if !math.IsInf(got["max"].(float64), 1) || !math.IsInf(got["min"].(float64), -1) || !math.IsNaN(got["missing"].(float64)) || !math.IsNaN(got["ratio"].(float64)) {
+			t.Errorf("Unexpected round trip result %v", got)
+		}
+	})
+}
+
+func TestJSONCFormatBad(t *testing.T) {
+	testCases := []struct {
+		name  string
+		src   string
+		json5 bool
+	}{
+		{"unterminated comment", `{"a": 1 /* }`, false},
+		{"missing comma", `{"a": 1 "b": 2}`, false},
+		{"unquoted key in JSONC", `{a: 1}`, false},
+		{"single quotes in JSONC", `{"a": 'x'}`, false},
+		{"hex in JSONC", `{"a": 0x10}`, false},
+		{"invalid literal", `{a: nope}`, true},
+		{"trailing garbage", `{"a": 1} x`, false},
+		{"unterminated string", `{"a": "x}`, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseJSONC(tc.src, tc.json5); err == nil {
+				t.Errorf("Expected an error for %q, but got nil", tc.src)
+			}
+		})
+	}
+
+	t.Run("Infinity cannot be written as JSONC", func(t *testing.T) {
+		var b bytes.Buffer
+		err := (&JSONCFormat{}).Encode(&b, map[string]interface{}{"max": math.Inf(1)})
+		if err == nil || !strings.Contains(err.Error(), "max") {
+			t.Errorf("Expected an error naming the key, got %v", err)
+		}
+	})
+
+	t.Run("Root must be an object", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "list.jsonc")
+		if err := os.WriteFile(path, []byte("[1, 2]"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if _, err := (&JSONCFormat{}).Load(path); err == nil {
+			t.Errorf("Expected an error for a non-object root, but got nil")
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+
+	"howett.net/plist"
+)
+
+// PlistFormat implements the ConfigFormat interface for Apple and GNUstep
+// property lists (.plist). Load accepts XML, binary, OpenStep and GNUstep
+// property lists. Dictionaries decode to maps, arrays to slices, integers to
+// int64, reals to float64, dates to time.Time and data to base64 strings, as
+// described by Canonicalize.
+//
+// Save writes XML property lists unless Binary is set. When the file already
+// exists, its current variant is kept, so a binary plist stays binary.
+type PlistFormat struct {
+	// Binary selects the binary variant for new files.
+	Binary bool
+}
+
+// Decode reads a property list from r and decodes it into a map.
+func (f *PlistFormat) Decode(r io.Reader) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	if _, err := plist.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return canonicalDocument("plist", result)
+}
+
+// Encode writes the provided map to w as a property list, using the binary
+// variant if Binary is set. Property lists cannot represent nil values.
+func (f *PlistFormat) Encode(w io.Writer, data map[string]interface{}) error {
+	format := plist.XMLFormat
+	if f.Binary {
+		format = plist.BinaryFormat
+	}
+	out, err := encodePlist(data, format)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// Load reads a property list from the given path and decodes it into a map.
+func (f *PlistFormat) Load(path string) (map[string]interface{}, error) {
+	return loadFile(path, f.Decode)
+}
+
+// Save encodes the provided map as a property list and writes it to the
+// given path, keeping the variant of an existing file.
+func (f *PlistFormat) Save(path string, data map[string]interface{}) error {
+	format := plist.XMLFormat
+	if f.Binary {
+		format = plist.BinaryFormat
+	}
+	existing, err := readExisting(path)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		var discard interface{}
+		if detected, err := plist.Unmarshal(existing, &discard); err == nil {
+			format = detected
+		}
+	}
+	out, err := encodePlist(data, format)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0644)
+}
+
+// encodePlist encodes data as a property list in the given variant.
+func encodePlist(data map[string]interface{}, format int) ([]byte, error) {
+	if err := checkPlistValue("", reflect.ValueOf(data)); err != nil {
+		return nil, err
+	}
+	var out []byte
+	var err error
+	if format == plist.BinaryFormat {
+		out, err = plist.Marshal(data, format)
+	} else {
+		out, err = plist.MarshalIndent(data, format, "\t")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("plist: %w", err)
+	}
+	return out, nil
+}
+
+// checkPlistValue returns an error for nil values anywhere in v, which the
+// plist encoder would otherwise drop silently.
+func checkPlistValue(key string, v reflect.Value) error {
+	v = indirectValue(v)
+	switch v.Kind() {
+	case reflect.Invalid:
+		return fmt.Errorf("plist: key %q: cannot encode a nil value", key)
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			child := fmt.Sprint(k.Interface())
+			if key != "" {
+				child = key + "." + child
+			}
+			if err := checkPlistValue(child, v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := checkPlistValue(fmt.Sprintf("%s[%d]", key, i), v.Index(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlistFormatGood(t *testing.T) {
+	t.Run("Decodes an XML property list", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "Info.plist")
+		src := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>CFBundleName</key>
+	<string>Demo</string>
+	<key>Build</key>
+	<integer>42</integer>
+	<key>Offset</key>
+	<integer>-3</integer>
+	<key>Scale</key>
+	<real>1.5</real>
+	<key>Enabled</key>
+	<true/>
+	<key>Released</key>
+	<date>2024-01-02T03:04:05Z</date>
+	<key>Icon</key>
+	<data>AAEC</data>
+	<key>Languages</key>
+	<array>
+		<string>en</string>
+		<string>fr</string>
+	</array>
+	<key>Window</key>
+	<dict>
+		<key>Width</key>
+		<integer>800</integer>
+	</dict>
+</dict>
+</plist>
+`
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatalf("Failed to write plist file: %v", err)
+		}
+
+		got, err := (&PlistFormat{}).Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+
+		expected := map[string]interface{}{
+			"CFBundleName": "Demo",
+			"Build":        int64(42),
+			"Offset":       int64(-3),
+			"Scale":        1.5,
+			"Enabled":      true,
+			"Released":     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			"Icon":         "AAEC",
+			"Languages":    []interface{}{"en", "fr"},
+			"Window":       map[string]interface{}{"Width": int64(800)},
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Unexpected result.\nExpected: %#v\nGot: %#v", expected, got)
+		}
+	})
+
+	t.Run("Round trips XML and binary property lists", func(t *testing.T) {
+		data := map[string]interface{}{
+			"name":     "demo",
+			"count":    int64(7),
+			"ratio":    0.25,
+			"enabled":  false,
+			"updated":  time.Date(2023, 6, 7, 8, 9, 10, 0, time.UTC),
+			"blob":     []byte("hello"),
+			"tags":     []interface{}{"a", int64(1)},
+			"settings": map[string]interface{}{"theme": "dark"},
+		}
+		// Data loads as a base64 string.
+		expected := make(map[string]interface{}, len(data))
+		for k, v := range data {
+			expected[k] = v
+		}
+		expected["blob"] = "aGVsbG8="
+		for _, binary := range []bool{false, true} {
+			path := filepath.Join(t.TempDir(), "test.plist")
+			format := &PlistFormat{Binary: binary}
+			if err := format.Save(path, data); err != nil {
+				t.Fatalf("Save() failed: %v", err)
+			}
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read plist file: %v", err)
+			}
+			if isBinary := bytes.HasPrefix(raw, []byte("bplist")); isBinary != binary {
+				t.Errorf("Expected binary=%v output, got:\n%s", binary, raw)
+			}
+
+			got, err := format.Load(path)
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			if !reflect.DeepEqual(expected, got) {
+				t.Errorf("Round trip (binary=%v) mismatch.\nExpected: %#v\nGot: %#v", binary, expected, got)
+			}
+		}
+	})
+
+	t.Run("Keeps the binary variant of an existing file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "test.plist")
+		if err := (&PlistFormat{Binary: true}).Save(path, map[string]interface{}{"a": "1"}); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		if err := (&PlistFormat{}).Save(path, map[string]interface{}{"a": "2"}); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read plist file: %v", err)
+		}
+		if !bytes.HasPrefix(raw, []byte("bplist")) {
+			t.Errorf("Expected the file to stay binary, got:\n%s", raw)
+		}
+	})
+
+	t.Run("Decodes a GNUstep property list", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "defaults.plist")
+		src := `{ Name = "Demo"; Size = <*I12>; Tags = (a, b); }`
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatalf("Failed to write plist file: %v", err)
+		}
+		got, err := (&PlistFormat{}).Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		expected := map[string]interface{}{
+			"Name": "Demo",
+			"Size": int64(12),
+			"Tags": []interface{}{"a", "b"},
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Unexpected result.\nExpected: %#v\nGot: %#v", expected, got)
+		}
+	})
+}
+
+func TestPlistFormatBad(t *testing.T) {
+	t.Run("Malformed property list", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "bad.plist")
+		if err := os.WriteFile(path, []byte("<plist><dict><key>a</key></plist>"), 0644); err != nil {
+			t.Fatalf("Failed to write plist file: %v", err)
+		}
+		if _, err := (&PlistFormat{}).Load(path); err == nil {
+			t.Error("Expected an error for a malformed property list, but got nil")
+		}
+	})
+
+	t.Run("Nil values", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nil.plist")
+		err := (&PlistFormat{}).Save(path, map[string]interface{}{"missing": nil})
+		if err == nil || !strings.Contains(err.Error(), "plist") {
+			t.Errorf("Expected a plist error for a nil value, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// PropertiesFormat implements the ConfigFormat interface for Java .properties
+// files. It follows the rules of java.util.Properties: "=", ":" or whitespace
+// separate keys from values, "#" and "!" start comments, a trailing backslash
+// continues a value on the next line and \uXXXX escapes encode unicode
+// characters.
+//
+// Keys are kept as flat, dotted strings (e.g., "server.port"). Save flattens
+// nested maps, such as those INIFormat.Load produces, into dotted keys, and
+// INIFormat.Save splits dotted keys back into sections, so data can be
+// converted between the two formats.
+type PropertiesFormat struct{}
+
+// Decode reads a .properties document from r and decodes it into a map. All
+// values are returned as strings.
+func (f *PropertiesFormat) Decode(r io.Reader) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseProperties(string(data))
+}
+
+// Encode writes a map of key-value pairs to w in .properties format. Keys
+// are written in sorted order and non-ASCII characters are written as
+// \uXXXX escapes so the output can be read by any JVM.
+func (f *PropertiesFormat) Encode(w io.Writer, data map[string]interface{}) error {
+	values := make(map[string]string, len(data))
+	flattenProperties("", data, values)
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(escapeProperty(key, true))
+		b.WriteByte('=')
+		b.WriteString(escapeProperty(values[key], false))
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Load reads a .properties file and decodes it into a map.
+func (f *PropertiesFormat) Load(path string) (map[string]interface{}, error) {
+	return loadFile(path, f.Decode)
+}
+
+// Save writes a map of key-value pairs to a .properties file.
+func (f *PropertiesFormat) Save(path string, data map[string]interface{}) error {
+	return saveFile(path, data, f.Encode)
+}
+
+// flattenProperties adds the entries of data to out, joining the keys of
+// nested maps with dots.
+func flattenProperties(prefix string, data map[string]interface{}, out map[string]string) {
+	for key, value := range data {
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenProperties(prefix+key+".", nested, out)
+			continue
+		}
+		out[prefix+key] = formatText(value)
+	}
+}
+
+// parseProperties decodes the contents of a .properties file.
+func parseProperties(src string) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+
+		// Join continuation lines: an odd number of trailing backslashes
+		// means the logical line carries on.
+		for endsWithContinuation(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[i], " \t\f")
+		}
+		if endsWithContinuation(line) {
+			line = line[:len(line)-1]
+		}
+
+		keyEnd := len(line)
+		for j := 0; j < len(line); j++ {
+			c := line[j]
+			if c == '\\' {
+				j++
+				continue
+			}
+			if c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' {
+				keyEnd = j
+				break
+			}
+		}
+
+		rest := strings.TrimLeft(line[keyEnd:], " \t\f")
+		if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+			rest = strings.TrimLeft(rest[1:], " \t\f")
+		}
+
+		key, err := unescapeProperty(line[:keyEnd])
+		if err != nil {
+			return nil, fmt.Errorf("properties line %d: %w", lineNo, err)
+		}
+		value, err := unescapeProperty(rest)
+		if err != nil {
+			return nil, fmt.Errorf("properties line %d: %w", lineNo, err)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// endsWithContinuation reports whether line ends in an odd number of
+// backslashes.
+func endsWithContinuation(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// unescapeProperty resolves the escape sequences allowed in keys and values.
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var units []uint16
+	var b strings.Builder
+	flush := func() {
+		if len(units) > 0 {
+			b.WriteString(string(utf16.Decode(units)))
+			units = units[:0]
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' {
+			flush()
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(s) {
+			// Like java.util.Properties, drop a lone trailing backslash.
+			flush()
+			break
+		}
+		if s[i] == 'u' {
+			if i+5 > len(s) {
+				return "", fmt.Errorf("malformed \\u escape in %q", s)
+			}
+			n, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\u escape in %q", s)
+			}
+			units = append(units, uint16(n))
+			i += 4
+			continue
+		}
+		flush()
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	flush()
+	return b.String(), nil
+}
+
+// escapeProperty escapes a key or value for writing. Keys additionally have
+// their separators and spaces escaped; values only escape a leading space.
+func escapeProperty(s string, isKey bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '=', ':', '#', '!':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case ' ':
+			if isKey || i == 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(' ')
+		default:
+			if r < 0x20 || r > 0x7e {
+				for _, u := range utf16.Encode([]rune{r}) {
+					fmt.Fprintf(&b, `\u%04X`, u)
+				}
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPropertiesFormatGood(t *testing.T) {
+	t.Run("Parses separators, continuations, escapes and comments", func(t *testing.T) {
+		src := "# comment\n" +
+			"! another comment\n" +
+			"server.host=localhost\n" +
+			"server.port : 8080\n" +
+			"server.name   demo server\n" +
+			"  indented.key = value\n" +
+			"message = first \\\n" +
+			"          second \\\n" +
+			"          third\n" +
+			"unicode=caf\\u00e9 \\uD83D\\uDE00\n" +
+			"escaped\\ key\\=with\\:seps = tab\\tnewline\\n\n" +
+			"path=C:\\\\temp\n" +
+			"empty=\n" +
+			"bare\n"
+
+		got, err := parseProperties(src)
+		if err != nil {
+			t.Fatalf("parseProperties() failed: %v", err)
+		}
+
+		expected := map[string]interface{}{
+			"server.host":           "localhost",
+			"server.port":           "8080",
+			"server.name":           "demo server",
+			"indented.key":          "value",
+			"message":               "first second third",
+			"unicode":               "café 😀",
+			"escaped key=with:seps": "tab\tnewline\n",
+			"path":                  `C:\temp`,
+			"empty":                 "",
+			"bare":                  "",
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Unexpected result.\nExpected: %q\nGot: %q", expected, got)
+		}
+	})
+
+	t.Run("Drops a lone trailing backslash", func(t *testing.T) {
+		got, err := parseProperties("key=value\\")
+		if err != nil {
+			t.Fatalf("parseProperties() failed: %v", err)
+		}
+		if got["key"] != "value" {
+			t.Errorf("Expected %q, got %q", "value", got["key"])
+		}
+		for in, expected := range map[string]string{`value\`: "value", `caf\u00e9\`: "café", `\`: ""} {
+			if got, err := unescapeProperty(in); err != nil || got != expected {
+				t.Errorf("unescapeProperty(%q) = %q, %v, expected %q", in, got, err, expected)
+			}
+		}
+	})
+
+	t.Run("Save escapes keys and values so they round-trip", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "test.properties")
+		data := map[string]interface{}{
+			"key with spaces": " leading space",
+			"sep=:#!":         "value=with:seps#and!marks",
+			"unicode":         "naïve 😀",
+			"multi":           "line1\nline2",
+			"number":          8080,
+		}
+
+		f := &PropertiesFormat{}
+		if err := f.Save(path, data); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		got, err := f.Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+
+		expected := map[string]interface{}{
+			"key with spaces": " leading space",
+			"sep=:#!":         "value=with:seps#and!marks",
+			"unicode":         "naïve 😀",
+			"multi":           "line1\nline2",
+			"number":          "8080",
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Round trip mismatch.\nExpected: %q\nGot: %q", expected, got)
+		}
+	})
+
+	t.Run("Converts to and from INI sections", func(t *testing.T) {
+		dir := t.TempDir()
+		iniPath := filepath.Join(dir, "test.ini")
+		propsPath := filepath.Join(dir, "test.properties")
+
+		ini := &INIFormat{}
+		props := &PropertiesFormat{}
+		if err := ini.Save(iniPath, map[string]interface{}{"server": map[string]interface{}{"host": "localhost"}, "name": "demo"}); err != nil {
+			t.Fatalf("INI Save() failed: %v", err)
+		}
+		iniData, err := ini.Load(iniPath)
+		if err != nil {
+			t.Fatalf("INI Load() failed: %v", err)
+		}
+		if err := props.Save(propsPath, iniData); err != nil {
+			t.Fatalf("Properties Save() failed: %v", err)
+		}
+		got, err := props.Load(propsPath)
+		if err != nil {
+			t.Fatalf("Properties Load() failed: %v", err)
+		}
+
+		expected := map[string]interface{}{"server.host": "localhost", "name": "demo"}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Conversion mismatch.\nExpected: %v\nGot: %v", expected, got)
+		}
+
+		// And back again: dotted keys become INI sections.
+		if err := ini.Save(iniPath, got); err != nil {
+			t.Fatalf("INI Save() failed: %v", err)
+		}
+		back, err := ini.Load(iniPath)
+		if err != nil {
+			t.Fatalf("INI Load() failed: %v", err)
+		}
+		if !reflect.DeepEqual(iniData, back) {
+			t.Errorf("Conversion mismatch.\nExpected: %v\nGot: %v", iniData, back)
+		}
+	})
+}
+
+func TestPropertiesFormatBad(t *testing.T) {
+	t.Run("Malformed unicode escape", func(t *testing.T) {
+		if _, err := parseProperties("key=\\u00zz\n"); err == nil {
+			t.Errorf("Expected an error for a malformed unicode escape, but got nil")
+		}
+	})
+
+	t.Run("Truncated unicode escape", func(t *testing.T) {
+		if _, err := parseProperties("key=\\u00"); err == nil {
+			t.Errorf("Expected an error for a truncated unicode escape, but got nil")
+		}
+	})
+}
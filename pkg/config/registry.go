@@ -0,0 +1,497 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/parser"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v3"
+)
+
+// FormatFactory returns a new instance of a ConfigFormat.
+type FormatFactory func() ConfigFormat
+
+// FormatSniffer reports whether data looks like the content of a file in a
+// particular format.
+type FormatSniffer func(data []byte) bool
+
+// FormatInfo describes a registered format.
+type FormatInfo struct {
+	// Extensions are the file extensions handled by the format, including
+	// the leading dot.
+	Extensions []string
+	// MIMETypes are the media types associated with the format. The first
+	// one is used when a single type is needed.
+	MIMETypes []string
+}
+
+// formatRegistration is the result of one call to RegisterFormat.
+type formatRegistration struct {
+	exts    []string
+	factory FormatFactory
+}
+
+// formatSniffer associates a sniffer with the extension it detects.
+type formatSniffer struct {
+	ext   string
+	sniff FormatSniffer
+}
+
+// formatRegistry maps file extensions, media types and content to formats.
+type formatRegistry struct {
+	mu       sync.RWMutex
+	byExt    map[string]*formatRegistration
+	mimes    map[string][]string // extension to media types
+	byMIME   map[string]string   // media type to extension
+	sniffers []formatSniffer
+}
+
+// formats is the registry used by GetConfigFormat and the Service methods.
+var formats = newFormatRegistry()
+
+// newFormatRegistry returns a registry holding the built-in formats.
+func newFormatRegistry() *formatRegistry {
+	r := &formatRegistry{
+		byExt:  make(map[string]*formatRegistration),
+		mimes:  make(map[string][]string),
+		byMIME: make(map[string]string),
+	}
+	builtins := []struct {
+		exts    []string
+		factory FormatFactory
+		mimes   []string
+		sniff   FormatSniffer
+	}{
+		{[]string{".plist"}, func() ConfigFormat { return &PlistFormat{} }, []string{"application/x-plist"}, sniffPlist},
+		{[]string{".xml"}, func() ConfigFormat { return &XMLFormat{} }, []string{"application/xml", "text/xml"}, sniffXML},
+		{[]string{".json"}, func() ConfigFormat { return &JSONFormat{} }, []string{"application/json"}, json.Valid},
+		{[]string{".jsonc"}, func() ConfigFormat { return &JSONCFormat{} }, []string{"application/jsonc"}, sniffJSONC(false)},
+		{[]string{".json5"}, func() ConfigFormat { return &JSON5Format{} }, []string{"application/json5"}, sniffJSONC(true)},
+		{[]string{".ndjson", ".jsonl"}, func() ConfigFormat { return &NDJSONFormat{} }, []string{"application/x-ndjson", "application/jsonl"}, sniffNDJSON},
+		{[]string{".cbor"}, func() ConfigFormat { return &CBORFormat{} }, []string{"application/cbor"}, sniffCBOR},
+		{[]string{".msgpack"}, func() ConfigFormat { return &MsgpackFormat{} }, []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}, sniffMsgpack},
+		{[]string{".ini"}, func() ConfigFormat { return &INIFormat{} }, []string{"text/x-ini"}, sniffINI},
+		{[]string{".hcl"}, func() ConfigFormat { return &HCLFormat{} }, []string{"application/hcl"}, sniffHCL},
+		{[]string{".yaml", ".yml"}, func() ConfigFormat { return &YAMLFormat{} }, []string{"application/yaml", "application/x-yaml", "text/yaml"}, sniffYAML},
+		{[]string{".env"}, func() ConfigFormat { return &EnvFormat{} }, []string{"text/x-dotenv"}, sniffEnv},
+		{[]string{".properties"}, func() ConfigFormat { return &PropertiesFormat{} }, []string{"text/x-java-properties"}, nil},
+	}
+	for _, b := range builtins {
+		if err := r.register(b.exts, b.factory); err != nil {
+			panic(err)
+		}
+		if err := r.registerMIMETypes(b.exts[0], b.mimes); err != nil {
+			panic(err)
+		}
+		if b.sniff != nil {
+			r.sniffers = append(r.sniffers, formatSniffer{ext: b.exts[0], sniff: b.sniff})
+		}
+	}
+	return r
+}
+
+// RegisterFormat makes a format available for the given file extensions,
+// replacing any format previously registered for them. Extensions are
+// matched case-insensitively and the leading dot is optional. Media types
+// associated with an extension are kept when its format is replaced.
+//
+// Example:
+//
+//	err := config.RegisterFormat([]string{".toml"}, func() config.ConfigFormat {
+//		return &TOMLFormat{}
+//	})
+func RegisterFormat(exts []string, factory FormatFactory) error {
+	return formats.register(exts, factory)
+}
+
+// RegisterMIMEType associates media types, such as "application/toml", with
+// the format registered for ext. A media type can only belong to one
+// extension; registering it again moves it.
+func RegisterMIMEType(ext string, mimeTypes ...string) error {
+	return formats.registerMIMETypes(ext, mimeTypes)
+}
+
+// RegisterSniffer adds a content check used to detect the format of files
+// without a recognised extension. Sniffers registered later are tried
+// first, so applications can take precedence over the built-in checks.
+func RegisterSniffer(ext string, sniff FormatSniffer) error {
+	return formats.registerSniffer(ext, sniff)
+}
+
+// Formats returns the registered formats, sorted by their first extension.
+func Formats() []FormatInfo {
+	return formats.list()
+}
+
+// GetConfigFormat returns a ConfigFormat implementation based on the file
+// extension of the provided path. This allows the config service to dynamically
+// handle different file formats.
+//
+// Example:
+//
+//	format, err := GetConfigFormat("settings.json")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	// format is now a JSONFormat
+func GetConfigFormat(path string) (ConfigFormat, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	format, ok := formats.lookup(ext)
+	if !ok {
+		return nil, fmt.Errorf("unsupported config format: %s", ext)
+	}
+	return format, nil
+}
+
+// GetConfigFormatForMIMEType returns the format associated with a media
+// type. Parameters such as "; charset=utf-8" are ignored.
+func GetConfigFormatForMIMEType(mimeType string) (ConfigFormat, error) {
+	ext, err := ExtensionForMIMEType(mimeType)
+	if err != nil {
+		return nil, err
+	}
+	format, ok := formats.lookup(ext)
+	if !ok {
+		return nil, fmt.Errorf("unsupported config format: %s", ext)
+	}
+	return format, nil
+}
+
+// ExtensionForMIMEType returns the extension whose format is associated with
+// a media type.
+func ExtensionForMIMEType(mimeType string) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return "", fmt.Errorf("invalid media type %q: %w", mimeType, err)
+	}
+	formats.mu.RLock()
+	defer formats.mu.RUnlock()
+	ext, ok := formats.byMIME[mediaType]
+	if !ok {
+		return "", fmt.Errorf("unsupported media type: %s", mediaType)
+	}
+	return ext, nil
+}
+
+// MIMEType returns the primary media type of the format used for path, or
+// "application/octet-stream" if none is associated with its extension.
+func MIMEType(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	formats.mu.RLock()
+	defer formats.mu.RUnlock()
+	if reg, ok := formats.byExt[ext]; ok {
+		if mimes := formats.mimeTypes(reg); len(mimes) > 0 {
+			return mimes[0]
+		}
+	}
+	return "application/octet-stream"
+}
+
+// DetectFormat inspects data and returns the extension of the first format
+// whose sniffer recognises it.
+func DetectFormat(data []byte) (string, error) {
+	formats.mu.RLock()
+	sniffers := formats.sniffers
+	formats.mu.RUnlock()
+	if len(bytes.TrimSpace(data)) > 0 {
+		for _, s := range sniffers {
+			if s.sniff(data) {
+				return s.ext, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("unable to detect config format from content")
+}
+
+// LoadFile loads a config file of any registered format, chosen by its
+// extension or, for a file without one, detected from its content.
+//
+// Example:
+//
+//	data, err := config.LoadFile("settings.yaml")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func LoadFile(path string) (map[string]interface{}, error) {
+	format, err := resolveFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := format.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+	}
+	return data, nil
+}
+
+// resolveFormat returns the format for path, using its extension where it has
+// a registered one and otherwise sniffing the content of the existing file.
+func resolveFormat(path string) (ConfigFormat, error) {
+	if format, err := GetConfigFormat(path); err == nil || filepath.Ext(path) != "" {
+		return format, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("unable to determine config format of %s: no file extension", filepath.Base(path))
+		}
+		return nil, err
+	}
+	ext, err := DetectFormat(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine config format of %s: %w", filepath.Base(path), err)
+	}
+	return GetConfigFormat(ext)
+}
+
+// normalizeExt lowercases ext and adds the leading dot if it is missing.
+func normalizeExt(ext string) (string, error) {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext == "" || ext == "." {
+		return "", fmt.Errorf("invalid format extension %q", ext)
+	}
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext, nil
+}
+
+func (r *formatRegistry) register(exts []string, factory FormatFactory) error {
+	if factory == nil {
+		return fmt.Errorf("format factory must not be nil")
+	}
+	if len(exts) == 0 {
+		return fmt.Errorf("format must have at least one extension")
+	}
+	reg := &formatRegistration{factory: factory}
+	for _, ext := range exts {
+		normalized, err := normalizeExt(ext)
+		if err != nil {
+			return err
+		}
+		reg.exts = append(reg.exts, normalized)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, ext := range reg.exts {
+		if old, ok := r.byExt[ext]; ok {
+			old.exts = removeString(old.exts, ext)
+		}
+		r.byExt[ext] = reg
+	}
+	return nil
+}
+
+func (r *formatRegistry) registerMIMETypes(ext string, mimeTypes []string) error {
+	ext, err := normalizeExt(ext)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.byExt[ext]; !ok {
+		return fmt.Errorf("unsupported config format: %s", ext)
+	}
+	for _, m := range mimeTypes {
+		mediaType, _, err := mime.ParseMediaType(m)
+		if err != nil {
+			return fmt.Errorf("invalid media type %q: %w", m, err)
+		}
+		if old, ok := r.byMIME[mediaType]; ok {
+			r.mimes[old] = removeString(r.mimes[old], mediaType)
+		}
+		r.byMIME[mediaType] = ext
+		r.mimes[ext] = append(r.mimes[ext], mediaType)
+	}
+	return nil
+}
+
+func (r *formatRegistry) registerSniffer(ext string, sniff FormatSniffer) error {
+	if sniff == nil {
+		return fmt.Errorf("format sniffer must not be nil")
+	}
+	ext, err := normalizeExt(ext)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.byExt[ext]; !ok {
+		return fmt.Errorf("unsupported config format: %s", ext)
+	}
+	sniffers := make([]formatSniffer, 0, len(r.sniffers)+1)
+	sniffers = append(sniffers, formatSniffer{ext: ext, sniff: sniff})
+	r.sniffers = append(sniffers, r.sniffers...)
+	return nil
+}
+
+func (r *formatRegistry) lookup(ext string) (ConfigFormat, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	reg, ok := r.byExt[ext]
+	if !ok {
+		return nil, false
+	}
+	return reg.factory(), true
+}
+
+func (r *formatRegistry) list() []FormatInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	seen := make(map[*formatRegistration]bool)
+	var infos []FormatInfo
+	for _, reg := range r.byExt {
+		if seen[reg] || len(reg.exts) == 0 {
+			continue
+		}
+		seen[reg] = true
+		infos = append(infos, FormatInfo{
+			Extensions: append([]string(nil), reg.exts...),
+			MIMETypes:  r.mimeTypes(reg),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Extensions[0] < infos[j].Extensions[0]
+	})
+	return infos
+}
+
+// mimeTypes returns the media types associated with any extension of reg.
+// The caller must hold r.mu.
+func (r *formatRegistry) mimeTypes(reg *formatRegistration) []string {
+	var mimes []string
+	for _, ext := range reg.exts {
+		mimes = append(mimes, r.mimes[ext]...)
+	}
+	return mimes
+}
+
+// removeString returns list without any occurrence of s.
+func removeString(list []string, s string) []string {
+	out := list[:0]
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// The built-in sniffers below are tried in registration order: binary and
+// markup formats with distinctive prefixes first, then JSON and its
+// supersets, and finally the looser text formats.
+
+func sniffPlist(data []byte) bool {
+	if bytes.HasPrefix(data, []byte("bplist")) {
+		return true
+	}
+	head := data
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) && bytes.Contains(head, []byte("<plist"))
+}
+
+func sniffXML(data []byte) bool {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+		return false
+	}
+	_, err := parseXML(data)
+	return err == nil
+}
+
+func sniffJSONC(json5 bool) FormatSniffer {
+	return func(data []byte) bool {
+		trimmed := bytes.TrimSpace(data)
+		if !bytes.HasPrefix(trimmed, []byte("{")) && !bytes.HasPrefix(trimmed, []byte("/")) {
+			return false
+		}
+		_, err := parseJSONC(string(data), json5)
+		return err == nil
+	}
+}
+
+func sniffNDJSON(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(trimmed, []byte("{")) || !bytes.Contains(trimmed, []byte("\n")) {
+		return false
+	}
+	docs, err := (&NDJSONFormat{}).DecodeAll(bytes.NewReader(data))
+	return err == nil && len(docs) > 1
+}
+
+func sniffCBOR(data []byte) bool {
+	// A top-level map (major type 5), optionally behind the self-describe
+	// tag 55799.
+	if !bytes.HasPrefix(data, []byte{0xd9, 0xd9, 0xf7}) && (data[0] < 0xa0 || data[0] > 0xbf) {
+		return false
+	}
+	var m map[string]interface{}
+	return cborDecMode.Unmarshal(data, &m) == nil
+}
+
+func sniffMsgpack(data []byte) bool {
+	// A top-level fixmap, map16 or map32.
+	if (data[0] < 0x80 || data[0] > 0x8f) && data[0] != 0xde && data[0] != 0xdf {
+		return false
+	}
+	var m map[string]interface{}
+	return msgpack.Unmarshal(data, &m) == nil
+}
+
+func sniffINI(data []byte) bool {
+	hasSection := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			hasSection = true
+			break
+		}
+	}
+	if !hasSection {
+		return false
+	}
+	_, err := ini.LoadSources(iniOptions, data)
+	return err == nil
+}
+
+func sniffHCL(data []byte) bool {
+	file, err := parser.Parse(data)
+	if err != nil {
+		return false
+	}
+	list, ok := file.Node.(*ast.ObjectList)
+	if !ok {
+		return false
+	}
+	for _, item := range list.Items {
+		if !item.Assign.IsValid() {
+			return true // only HCL has blocks
+		}
+	}
+	return false
+}
+
+func sniffYAML(data []byte) bool {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return false
+	}
+	return len(doc.Content) > 0 && doc.Content[0].Kind == yaml.MappingNode
+}
+
+func sniffEnv(data []byte) bool {
+	m, err := parseEnv(string(data))
+	return err == nil && len(m) > 0
+}
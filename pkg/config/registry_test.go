@@ -0,0 +1,265 @@
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// withRegistry runs fn against a fresh registry of built-in formats and
+// restores the package registry afterwards.
+func withRegistry(t *testing.T, fn func()) {
+	t.Helper()
+	saved := formats
+	formats = newFormatRegistry()
+	defer func() { formats = saved }()
+	fn()
+}
+
+// upperFormat is a test format that stores a single "value" key.
+type upperFormat struct{}
+
+func (f *upperFormat) Decode(r io.Reader) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"value": string(data)}, nil
+}
+
+func (f *upperFormat) Encode(w io.Writer, data map[string]interface{}) error {
+	_, err := io.WriteString(w, data["value"].(string))
+	return err
+}
+
+func (f *upperFormat) Load(path string) (map[string]interface{}, error) {
+	return loadFile(path, f.Decode)
+}
+
+func (f *upperFormat) Save(path string, data map[string]interface{}) error {
+	return saveFile(path, data, f.Encode)
+}
+
+func TestFormatRegistryGood(t *testing.T) {
+	t.Run("Formats lists the built-in formats", func(t *testing.T) {
+		withRegistry(t, func() {
+			var yamlInfo *FormatInfo
+			infos := Formats()
+			for i := range infos {
+				if infos[i].Extensions[0] == ".yaml" {
+					yamlInfo = &infos[i]
+				}
+				if i > 0 && infos[i-1].Extensions[0] >= infos[i].Extensions[0] {
+					t.Errorf("Formats() is not sorted: %v before %v", infos[i-1].Extensions, infos[i].Extensions)
+				}
+			}
+			if len(infos) != 13 {
+				t.Errorf("Expected 13 built-in formats, got %d", len(infos))
+			}
+			if yamlInfo == nil {
+				t.Fatal("YAML format is missing from Formats()")
+			}
+			expected := FormatInfo{
+				Extensions: []string{".yaml", ".yml"},
+				MIMETypes:  []string{"application/yaml", "application/x-yaml", "text/yaml"},
+			}
+			if !reflect.DeepEqual(expected, *yamlInfo) {
+				t.Errorf("Expected %v, got %v", expected, *yamlInfo)
+			}
+		})
+	})
+
+	t.Run("RegisterFormat adds and overrides formats", func(t *testing.T) {
+		withRegistry(t, func() {
+			factory := func() ConfigFormat { return &upperFormat{} }
+			if err := RegisterFormat([]string{"UPPER", ".json"}, factory); err != nil {
+				t.Fatalf("RegisterFormat() failed: %v", err)
+			}
+			for _, path := range []string{"a.upper", "a.UPPER", "a.json"} {
+				format, err := GetConfigFormat(path)
+				if err != nil {
+					t.Fatalf("GetConfigFormat(%q) failed: %v", path, err)
+				}
+				if _, ok := format.(*upperFormat); !ok {
+					t.Errorf("GetConfigFormat(%q) returned %T", path, format)
+				}
+			}
+
+			// The JSON media type follows the extension to the new format.
+			format, err := GetConfigFormatForMIMEType("application/json; charset=utf-8")
+			if err != nil {
+				t.Fatalf("GetConfigFormatForMIMEType() failed: %v", err)
+			}
+			if _, ok := format.(*upperFormat); !ok {
+				t.Errorf("Expected the overriding format, got %T", format)
+			}
+		})
+	})
+
+	t.Run("MIME types", func(t *testing.T) {
+		withRegistry(t, func() {
+			if got := MIMEType("settings.YML"); got != "application/yaml" {
+				t.Errorf("Expected application/yaml, got %q", got)
+			}
+			if got := MIMEType("notes.txt"); got != "application/octet-stream" {
+				t.Errorf("Expected application/octet-stream, got %q", got)
+			}
+			if err := RegisterFormat([]string{".upper"}, func() ConfigFormat { return &upperFormat{} }); err != nil {
+				t.Fatalf("RegisterFormat() failed: %v", err)
+			}
+			if err := RegisterMIMEType(".upper", "text/x-upper"); err != nil {
+				t.Fatalf("RegisterMIMEType() failed: %v", err)
+			}
+			ext, err := ExtensionForMIMEType("TEXT/X-UPPER")
+			if err != nil || ext != ".upper" {
+				t.Errorf("Expected .upper, got %q (%v)", ext, err)
+			}
+		})
+	})
+
+	t.Run("DetectFormat recognises saved files", func(t *testing.T) {
+		nested := map[string]interface{}{
+			"name":   "demo",
+			"server": map[string]interface{}{"host": "localhost"},
+		}
+		flat := map[string]interface{}{"NAME": "demo", "PORT": "8080"}
+		testCases := []struct {
+			ext  string
+			data map[string]interface{}
+		}{
+			{".json", nested},
+			{".yaml", nested},
+			{".ini", nested},
+			{".xml", nested},
+			{".hcl", nested},
+			{".plist", nested},
+			{".cbor", nested},
+			{".msgpack", nested},
+			{".env", flat},
+		}
+		dir := t.TempDir()
+		for _, tc := range testCases {
+			path := filepath.Join(dir, "file"+tc.ext)
+			format, err := GetConfigFormat(path)
+			if err != nil {
+				t.Fatalf("GetConfigFormat() failed: %v", err)
+			}
+			if err := format.Save(path, tc.data); err != nil {
+				t.Fatalf("Save() failed for %s: %v", tc.ext, err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", path, err)
+			}
+			ext, err := DetectFormat(data)
+			if err != nil || ext != tc.ext {
+				t.Errorf("DetectFormat() for %s returned %q (%v)\n%s", tc.ext, ext, err, data)
+			}
+		}
+
+		for src, expected := range map[string]string{
+			"// settings\n{\"a\": 1,}":      ".jsonc",
+			"{a: 'single', b: 0x10}":        ".json5",
+			"<?xml version=\"1.0\"?>\n<a/>": ".xml",
+			"[server]\nport = 80\n":         ".ini",
+		} {
+			ext, err := DetectFormat([]byte(src))
+			if err != nil || ext != expected {
+				t.Errorf("DetectFormat(%q) returned %q (%v), expected %q", src, ext, err, expected)
+			}
+		}
+	})
+
+	t.Run("RegisterSniffer takes precedence", func(t *testing.T) {
+		withRegistry(t, func() {
+			if err := RegisterFormat([]string{".upper"}, func() ConfigFormat { return &upperFormat{} }); err != nil {
+				t.Fatalf("RegisterFormat() failed: %v", err)
+			}
+			sniff := func(data []byte) bool { return len(data) > 0 && data[0] == '{' }
+			if err := RegisterSniffer("upper", sniff); err != nil {
+				t.Fatalf("RegisterSniffer() failed: %v", err)
+			}
+			if ext, err := DetectFormat([]byte(`{"a": 1}`)); err != nil || ext != ".upper" {
+				t.Errorf("Expected .upper, got %q (%v)", ext, err)
+			}
+		})
+	})
+
+	t.Run("Service resolves keys through the registry", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+		withRegistry(t, func() {
+			s, err := New()
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if err := RegisterFormat([]string{".upper"}, func() ConfigFormat { return &upperFormat{} }); err != nil {
+				t.Fatalf("RegisterFormat() failed: %v", err)
+			}
+			data := map[string]interface{}{"value": "HELLO"}
+			if err := s.SaveKeyValues("greeting.upper", data); err != nil {
+				t.Fatalf("SaveKeyValues() failed: %v", err)
+			}
+			got, err := s.LoadKeyValues("greeting.upper")
+			if err != nil {
+				t.Fatalf("LoadKeyValues() failed: %v", err)
+			}
+			if !reflect.DeepEqual(data, got) {
+				t.Errorf("Expected %v, got %v", data, got)
+			}
+
+			// A file without an extension is read and rewritten in the
+			// format detected from its content.
+			path := filepath.Join(s.ConfigDir, "settings")
+			if err := os.WriteFile(path, []byte("server:\n  port: 80\n"), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			loaded, err := s.LoadKeyValues("settings")
+			if err != nil {
+				t.Fatalf("LoadKeyValues() failed: %v", err)
+			}
+			loaded["server"].(map[string]interface{})["port"] = 81
+			if err := s.SaveKeyValues("settings", loaded); err != nil {
+				t.Fatalf("SaveKeyValues() failed: %v", err)
+			}
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read file: %v", err)
+			}
+			if string(raw) != "server:\n  port: 81\n" {
+				t.Errorf("Expected YAML output, got:\n%s", raw)
+			}
+		})
+	})
+}
+
+func TestFormatRegistryBad(t *testing.T) {
+	withRegistry(t, func() {
+		if err := RegisterFormat(nil, func() ConfigFormat { return &upperFormat{} }); err == nil {
+			t.Error("Expected an error for a format without extensions")
+		}
+		if err := RegisterFormat([]string{"."}, func() ConfigFormat { return &upperFormat{} }); err == nil {
+			t.Error("Expected an error for an empty extension")
+		}
+		if err := RegisterFormat([]string{".x"}, nil); err == nil {
+			t.Error("Expected an error for a nil factory")
+		}
+		if err := RegisterMIMEType(".unknown", "text/x-unknown"); err == nil {
+			t.Error("Expected an error for an unregistered extension")
+		}
+		if err := RegisterMIMEType(".json", "not a media type"); err == nil {
+			t.Error("Expected an error for an invalid media type")
+		}
+		if _, err := GetConfigFormatForMIMEType("text/x-unknown"); err == nil {
+			t.Error("Expected an error for an unknown media type")
+		}
+		if _, err := DetectFormat([]byte("   ")); err == nil {
+			t.Error("Expected an error for empty content")
+		}
+		if _, err := resolveFormat(filepath.Join(t.TempDir(), "missing")); err == nil {
+			t.Error("Expected an error for a new file without an extension")
+		}
+	})
+}
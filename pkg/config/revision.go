@@ -0,0 +1,102 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RevisionError reports that a value was changed since the revision a
+// caller expected.
+type RevisionError struct {
+	// Key is the key of the value, or empty for the whole configuration or
+	// a file.
+	Key string
+	// Expected is the revision the caller expected, and Actual the current
+	// one.
+	Expected, Actual string
+}
+
+// Error returns the error with the key and both revisions.
+func (e RevisionError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("revision mismatch: expected %s, found %s", e.Expected, e.Actual)
+	}
+	return fmt.Sprintf("key '%s' was changed: expected revision %s, found %s", e.Key, e.Expected, e.Actual)
+}
+
+// Revision returns the revision of the whole configuration, which changes
+// whenever any value in Values changes. Revisions, also of single values and
+// auxiliary files, are hashes of the content: equal content has the same
+// revision, even across restarts, and any change gives a new one. A caller
+// that read a revision can write back with CompareAndSet, which fails if
+// the value was changed in the meantime.
+func (s *Service) Revision() string {
+	return valueRevision(s.Values())
+}
+
+// KeyRevision returns the revision of the configuration value for key.
+// Keys are matched ignoring case.
+//
+// Example:
+//
+//	rev, err := cfg.KeyRevision("language")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	// ... later, only if nobody changed the language since:
+//	err = cfg.CompareAndSet("language", rev, "fr")
+func (s *Service) KeyRevision(key string) (string, error) {
+	val, _, ok := s.field(key)
+	if !ok {
+		return "", KeyError{Key: key}
+	}
+	return valueRevision(val.Interface()), nil
+}
+
+// FileRevision returns the revision of the auxiliary file name in the config
+// directory, as read by LoadKeyValues. The error of a missing file matches
+// fs.ErrNotExist.
+func (s *Service) FileRevision(name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(s.ConfigDir, name))
+	if err != nil {
+		return "", err
+	}
+	return ContentRevision(data), nil
+}
+
+// CompareAndSet sets the configuration value for key as SetValue does, but
+// only if its revision is still expectedRevision. Otherwise it returns a
+// RevisionError and leaves the value unchanged. Like the other methods of
+// Service, it must not be called concurrently with them.
+func (s *Service) CompareAndSet(key, expectedRevision string, value interface{}) error {
+	actual, err := s.KeyRevision(key)
+	if err != nil {
+		return err
+	}
+	if actual != expectedRevision {
+		_, name, _ := s.field(key)
+		return RevisionError{Key: name, Expected: expectedRevision, Actual: actual}
+	}
+	return s.SetValue(key, value)
+}
+
+// ContentRevision returns the revision of raw content, such as a file.
+func ContentRevision(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// valueRevision returns the revision of a value by its JSON form, in which
+// map keys are sorted.
+func valueRevision(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		// Configuration values are plain data that always marshal.
+		panic(err)
+	}
+	return ContentRevision(data)
+}
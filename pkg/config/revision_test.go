@@ -0,0 +1,103 @@
+package config
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRevisionGood(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	s, err := New()
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	doc := s.Revision()
+	rev, err := s.KeyRevision("Language")
+	if err != nil {
+		t.Fatalf("KeyRevision() failed: %v", err)
+	}
+	routeRev, _ := s.KeyRevision("default_route")
+
+	if err := s.CompareAndSet("language", rev, "fr"); err != nil {
+		t.Fatalf("CompareAndSet() failed: %v", err)
+	}
+	if s.Language != "fr" {
+		t.Errorf("Expected language fr, got %q", s.Language)
+	}
+	newRev, _ := s.KeyRevision("language")
+	if newRev == rev {
+		t.Error("Expected a new key revision after a change")
+	}
+	if s.Revision() == doc {
+		t.Error("Expected a new document revision after a change")
+	}
+	if got, _ := s.KeyRevision("default_route"); got != routeRev {
+		t.Error("Expected other keys to keep their revision")
+	}
+
+	reloaded, err := New()
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if reloaded.Revision() != s.Revision() {
+		t.Error("Expected the same revision after reloading")
+	}
+
+	if err := s.SaveKeyValues("db.json", map[string]interface{}{"port": 5432}); err != nil {
+		t.Fatalf("SaveKeyValues() failed: %v", err)
+	}
+	fileRev, err := s.FileRevision("db.json")
+	if err != nil {
+		t.Fatalf("FileRevision() failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(s.ConfigDir, "db.json"))
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if fileRev != ContentRevision(data) {
+		t.Errorf("Expected the revision of the file content, got %s", fileRev)
+	}
+}
+
+func TestRevisionBad(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	s, err := New()
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	stale, _ := s.KeyRevision("features")
+	if err := s.SetString("features", "beta"); err != nil {
+		t.Fatalf("SetString() failed: %v", err)
+	}
+
+	err = s.CompareAndSet("Features", stale, []interface{}{"other"})
+	var revErr RevisionError
+	if !errors.As(err, &revErr) {
+		t.Fatalf("Expected a RevisionError for a stale revision, got %v", err)
+	}
+	current, _ := s.KeyRevision("features")
+	if revErr.Key != "features" || revErr.Expected != stale || revErr.Actual != current {
+		t.Errorf("Unexpected error %+v", revErr)
+	}
+	if !reflect.DeepEqual([]string{"beta"}, s.Features) {
+		t.Errorf("Expected the value to be unchanged, got %v", s.Features)
+	}
+
+	if _, ok := s.CompareAndSet("missing", stale, "x").(KeyError); !ok {
+		t.Error("Expected a KeyError from CompareAndSet() for an unknown key")
+	}
+	if _, err := s.KeyRevision("missing"); err == nil {
+		t.Error("Expected an error from KeyRevision() for an unknown key")
+	}
+	if _, err := s.FileRevision("missing.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist for a missing file, got %v", err)
+	}
+}
@@ -0,0 +1,505 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// Sources reported for a setting by Lookup and Settings.
+const (
+	// SourceDefault marks a setting that still has its built-in default.
+	SourceDefault = "default"
+	// SourceFile marks a setting whose key is set in config.json, even if
+	// to its default value.
+	SourceFile = "file"
+)
+
+// Setting is a configuration value together with the layer it comes from.
+type Setting struct {
+	Key    string      `json:"key" yaml:"key"`
+	Value  interface{} `json:"value" yaml:"value"`
+	Source string      `json:"source" yaml:"source"`
+}
+
+// Keys returns the keys of every persistent configuration value, in the
+// order they are stored in config.json.
+func (s *Service) Keys() []string {
+	typ := reflect.TypeOf(s).Elem()
+	var keys []string
+	for i := 0; i < typ.NumField(); i++ {
+		jsonTag := typ.Field(i).Tag.Get("json")
+		if jsonTag != "" && jsonTag != "-" {
+			keys = append(keys, strings.Split(jsonTag, ",")[0])
+		}
+	}
+	return keys
+}
+
+// Lookup returns the configuration value for key and the layer it comes
+// from: SourceFile if config.json set the key when it was loaded or the key
+// was set since, and SourceDefault otherwise. Keys are matched ignoring case.
+//
+// Example:
+//
+//	setting, err := cfg.Lookup("language")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Printf("%s = %v (%s)\n", setting.Key, setting.Value, setting.Source)
+func (s *Service) Lookup(key string) (Setting, error) {
+	val, name, ok := s.field(key)
+	if !ok {
+		return Setting{}, KeyError{Key: key}
+	}
+	source := SourceDefault
+	if s.fileKeys[name] {
+		source = SourceFile
+	}
+	return Setting{Key: name, Value: val.Interface(), Source: source}, nil
+}
+
+// Settings returns every configuration value with the layer it comes from,
+// in the order of Keys.
+func (s *Service) Settings() []Setting {
+	keys := s.Keys()
+	settings := make([]Setting, 0, len(keys))
+	for _, key := range keys {
+		setting, _ := s.Lookup(key)
+		settings = append(settings, setting)
+	}
+	return settings
+}
+
+// SetString parses value according to the type of the configuration value
+// for key, validates it, sets it and saves the configuration. Booleans and
+// numbers are parsed from their usual text form. Lists accept a JSON array
+// or a comma-separated list, and an empty string clears them. Invalid values
+// are reported as ValidationErrors.
+//
+// Example:
+//
+//	if err := cfg.SetString("features", "beta,metrics"); err != nil {
+//		log.Fatal(err)
+//	}
+func (s *Service) SetString(key, value string) error {
+	return s.SetValue(key, value)
+}
+
+// SetValue converts value, such as a value decoded from JSON or from a
+// config file in any format, to the type of the configuration value for
+// key, validates it, sets it and saves the configuration. Text is accepted
+// for lists, as SetString describes. An unknown key is reported as a
+// KeyError and an invalid value as ValidationErrors. If saving fails, the
+// value is left unchanged.
+//
+// Example:
+//
+//	if err := cfg.SetValue("features", []interface{}{"beta", "metrics"}); err != nil {
+//		log.Fatal(err)
+//	}
+func (s *Service) SetValue(key string, value interface{}) error {
+	fieldVal, name, ok := s.field(key)
+	if !ok {
+		return KeyError{Key: key}
+	}
+	newVal, verr := decodeSetting(name, fieldVal.Type(), value)
+	if verr != nil {
+		return ValidationErrors{*verr}
+	}
+	previous, wasSet := cloneValue(fieldVal), s.fileKeys[name]
+	fieldVal.Set(newVal)
+	s.setFileKey(name, true)
+	if err := s.Save(); err != nil {
+		fieldVal.Set(previous)
+		s.setFileKey(name, wasSet)
+		return err
+	}
+	return nil
+}
+
+// Unset restores the built-in default of the configuration value for key and
+// saves the configuration. If saving fails, the value is left unchanged.
+//
+// Example:
+//
+//	if err := cfg.Unset("language"); err != nil {
+//		log.Fatal(err)
+//	}
+func (s *Service) Unset(key string) error {
+	fieldVal, name, ok := s.field(key)
+	if !ok {
+		return KeyError{Key: key}
+	}
+	previous, wasSet := cloneValue(fieldVal), s.fileKeys[name]
+	fieldVal.Set(s.defaultValue(name, fieldVal.Type()))
+	s.setFileKey(name, false)
+	if err := s.Save(); err != nil {
+		fieldVal.Set(previous)
+		s.setFileKey(name, wasSet)
+		return err
+	}
+	return nil
+}
+
+// setFileKey records whether config.json sets key.
+func (s *Service) setFileKey(key string, set bool) {
+	if s.fileKeys == nil {
+		s.fileKeys = make(map[string]bool)
+	}
+	if set {
+		s.fileKeys[key] = true
+	} else {
+		delete(s.fileKeys, key)
+	}
+}
+
+// Suggestions returns values to offer when completing the value of key on
+// the command line, in the text form SetString accepts: "true" and "false"
+// for booleans, otherwise the current value followed by the default if they
+// differ. Empty values are left out, and an unknown key has none.
+//
+// Example:
+//
+//	for _, v := range cfg.Suggestions("language") {
+//		fmt.Println(v)
+//	}
+func (s *Service) Suggestions(key string) []string {
+	fieldVal, name, ok := s.field(key)
+	if !ok {
+		return nil
+	}
+	if fieldVal.Kind() == reflect.Bool {
+		return []string{"true", "false"}
+	}
+	var suggestions []string
+	for _, v := range []reflect.Value{fieldVal, s.defaultValue(name, fieldVal.Type())} {
+		text := settingText(v)
+		if text == "" || (len(suggestions) > 0 && suggestions[0] == text) {
+			continue
+		}
+		suggestions = append(suggestions, text)
+	}
+	return suggestions
+}
+
+// settingText formats a value in the text form SetString accepts, with
+// lists as comma-separated items.
+func settingText(v reflect.Value) string {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// KeyError reports a key that names no configuration value.
+type KeyError struct {
+	Key string
+}
+
+// Error returns the error as "key '<key>' not found in config".
+func (e KeyError) Error() string {
+	return fmt.Sprintf("key '%s' not found in config", e.Key)
+}
+
+// ValidationError describes a configuration value that cannot be applied.
+type ValidationError struct {
+	// Key is the key of the value, or empty for errors about the whole
+	// configuration.
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+	// Message explains what is wrong with the value.
+	Message string `json:"message" yaml:"message"`
+}
+
+// Error returns the error as "key: message".
+func (e ValidationError) Error() string {
+	if e.Key == "" {
+		return e.Message
+	}
+	return e.Key + ": " + e.Message
+}
+
+// ValidationErrors lists every problem found by Validate, sorted by key.
+type ValidationErrors []ValidationError
+
+// Error joins the messages of all errors.
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks that data, a map of keys to values as returned by Values
+// or loaded from a config file in any format, can be applied to the
+// configuration. It returns ValidationErrors listing unknown keys, values of
+// the wrong type and values outside their allowed range, or nil.
+func (s *Service) Validate(data map[string]interface{}) error {
+	if _, errs := s.decodeSettings(data); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Apply validates data as Validate does and replaces the whole configuration
+// with it, saving config.json atomically. Keys missing from data are reset to
+// their defaults. If validation or saving fails, the configuration is left
+// unchanged.
+//
+// Example:
+//
+//	data, err := (&config.YAMLFormat{}).Load("edited.yaml")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	if err := cfg.Apply(data); err != nil {
+//		log.Fatal(err)
+//	}
+func (s *Service) Apply(data map[string]interface{}) error {
+	decoded, errs := s.decodeSettings(data)
+	if len(errs) > 0 {
+		return errs
+	}
+	previous := make(map[string]reflect.Value)
+	for key, value := range s.Values() {
+		previous[key] = reflect.ValueOf(value)
+	}
+	previousKeys := s.fileKeys
+	s.setValues(decoded)
+	s.fileKeys = make(map[string]bool, len(decoded))
+	for key := range decoded {
+		s.fileKeys[key] = true
+	}
+	if err := s.Save(); err != nil {
+		s.setValues(previous)
+		s.fileKeys = previousKeys
+		return err
+	}
+	return nil
+}
+
+// Update validates data as Validate does and sets only the keys it holds,
+// saving config.json atomically. Unlike Apply, other keys keep their values
+// and stay defaults if they were. If validation or saving fails, the
+// configuration is left unchanged.
+//
+// Example:
+//
+//	if err := cfg.Update(map[string]interface{}{"language": "fr"}); err != nil {
+//		log.Fatal(err)
+//	}
+func (s *Service) Update(data map[string]interface{}) error {
+	decoded, errs := s.decodeSettings(data)
+	if len(errs) > 0 {
+		return errs
+	}
+	previous := make(map[string]reflect.Value, len(decoded))
+	previousKeys := make(map[string]bool, len(s.fileKeys))
+	for key := range s.fileKeys {
+		previousKeys[key] = true
+	}
+	for key, v := range decoded {
+		fieldVal, _, _ := s.field(key)
+		previous[key] = cloneValue(fieldVal)
+		fieldVal.Set(v)
+		s.setFileKey(key, true)
+	}
+	if err := s.Save(); err != nil {
+		for key, v := range previous {
+			fieldVal, _, _ := s.field(key)
+			fieldVal.Set(v)
+		}
+		s.fileKeys = previousKeys
+		return err
+	}
+	return nil
+}
+
+// setValues sets every persistent field to its value in values, or to its
+// default if values has none.
+func (s *Service) setValues(values map[string]reflect.Value) {
+	for _, key := range s.Keys() {
+		fieldVal, _, _ := s.field(key)
+		if v, ok := values[key]; ok {
+			fieldVal.Set(v)
+		} else {
+			fieldVal.Set(s.defaultValue(key, fieldVal.Type()))
+		}
+	}
+}
+
+// decodeSettings converts every value in data to the type of its field and
+// checks it, returning the values by key and any problems found.
+func (s *Service) decodeSettings(data map[string]interface{}) (map[string]reflect.Value, ValidationErrors) {
+	decoded := make(map[string]reflect.Value, len(data))
+	var errs ValidationErrors
+	for _, key := range sortedMapKeys(reflect.ValueOf(data)) {
+		fieldVal, name, ok := s.field(key)
+		if !ok {
+			errs = append(errs, ValidationError{Key: key, Message: "unknown key"})
+			continue
+		}
+		if _, dup := decoded[name]; dup {
+			errs = append(errs, ValidationError{Key: key, Message: fmt.Sprintf("duplicate of key %q", name)})
+			continue
+		}
+		v, err := decodeSetting(name, fieldVal.Type(), data[key])
+		if err != nil {
+			err.Key = key
+			errs = append(errs, *err)
+			continue
+		}
+		decoded[name] = v
+	}
+	return decoded, errs
+}
+
+// decodeSetting converts src to typ and checks the result against the rules
+// for key. Text is accepted for lists, as SetString describes.
+func decodeSetting(key string, typ reflect.Type, src interface{}) (reflect.Value, *ValidationError) {
+	if text, ok := src.(string); ok && typ.Kind() == reflect.Slice && typ.Elem().Kind() != reflect.Uint8 {
+		list, err := parseList(text)
+		if err != nil {
+			return reflect.Value{}, &ValidationError{Key: key, Message: err.Error()}
+		}
+		src = list
+	}
+	v := reflect.New(typ).Elem()
+	if err := decodeValue(src, v, ""); err != nil {
+		return reflect.Value{}, &ValidationError{Key: key, Message: err.Error()}
+	}
+	if msg := checkSetting(key, v); msg != "" {
+		return reflect.Value{}, &ValidationError{Key: key, Message: msg}
+	}
+	return v, nil
+}
+
+// pathKeys are the keys of the locations the service reads and writes
+// files in.
+var pathKeys = []string{"configPath", "userHomeDir", "rootDir", "cacheDir", "configDir", "dataDir", "workspaceDir"}
+
+// IsPathKey reports whether key, matched ignoring case, is one of the keys
+// locating the files of the configuration, such as configDir. Changing them
+// changes where files are written, so remote clients must not set them.
+func IsPathKey(key string) bool {
+	for _, name := range pathKeys {
+		if strings.EqualFold(name, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSetting returns a message if v is not an allowed value for key.
+func checkSetting(key string, v reflect.Value) string {
+	if IsPathKey(key) {
+		if v.String() != "" && !filepath.IsAbs(v.String()) {
+			return "must be an absolute path"
+		}
+		return ""
+	}
+	switch key {
+	case "default_route":
+		if !strings.HasPrefix(v.String(), "/") {
+			return `must start with "/"`
+		}
+	case "language":
+		if strings.TrimSpace(v.String()) == "" {
+			return "must not be empty"
+		}
+	case "features":
+		for i := 0; i < v.Len(); i++ {
+			if strings.TrimSpace(v.Index(i).String()) == "" {
+				return fmt.Sprintf("item %d must not be empty", i)
+			}
+		}
+	}
+	return ""
+}
+
+// Values returns a copy of every persistent configuration value by key, in
+// the shape Apply accepts.
+func (s *Service) Values() map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, key := range s.Keys() {
+		val, _, _ := s.field(key)
+		values[key] = cloneValue(val).Interface()
+	}
+	return values
+}
+
+// WithDefaults returns the configuration that a config.json holding values
+// resolves to: values laid over Defaults, so keys values leaves out have
+// their built-in default.
+func (s *Service) WithDefaults(values map[string]interface{}) map[string]interface{} {
+	resolved := s.Defaults()
+	for key, value := range values {
+		resolved[key] = value
+	}
+	return resolved
+}
+
+// Defaults returns a copy of the built-in default of every persistent
+// configuration value by key, in the shape Values returns.
+func (s *Service) Defaults() map[string]interface{} {
+	defaults := make(map[string]interface{})
+	for _, key := range s.Keys() {
+		val, _, _ := s.field(key)
+		defaults[key] = s.defaultValue(key, val.Type()).Interface()
+	}
+	return defaults
+}
+
+// defaultValue returns a copy of the built-in default for key. Services that
+// were not created by New or NewWithOptions have no recorded defaults, so
+// the zero value is used.
+func (s *Service) defaultValue(key string, typ reflect.Type) reflect.Value {
+	if def, ok := s.defaults[key]; ok {
+		return cloneValue(reflect.ValueOf(def))
+	}
+	return reflect.Zero(typ)
+}
+
+// cloneValue returns a copy of v that is not the field v may be, and does not
+// share a slice with it.
+func cloneValue(v reflect.Value) reflect.Value {
+	clone := reflect.New(v.Type()).Elem()
+	if v.Kind() != reflect.Slice || v.IsNil() {
+		clone.Set(v)
+		return clone
+	}
+	clone.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+	reflect.Copy(clone, v)
+	return clone
+}
+
+// parseList splits a list given on the command line, either as a JSON array
+// or as comma-separated items.
+func parseList(value string) ([]interface{}, error) {
+	trimmed := strings.TrimSpace(value)
+	if strings.HasPrefix(trimmed, "[") {
+		var list []interface{}
+		if err := unmarshalJSON([]byte(trimmed), &list); err != nil {
+			return nil, err
+		}
+		canonical, err := Canonicalize(list)
+		if err != nil {
+			return nil, err
+		}
+		return canonical.([]interface{}), nil
+	}
+	list := []interface{}{}
+	if trimmed == "" {
+		return list, nil
+	}
+	for _, item := range strings.Split(trimmed, ",") {
+		list = append(list, strings.TrimSpace(item))
+	}
+	return list, nil
+}
@@ -0,0 +1,390 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSettingsGood(t *testing.T) {
+	t.Run("Keys follow config.json", func(t *testing.T) {
+		s := &Service{}
+		expected := []string{"configPath", "userHomeDir", "rootDir", "cacheDir", "configDir", "dataDir", "workspaceDir", "default_route", "features", "language"}
+		if got := s.Keys(); !reflect.DeepEqual(expected, got) {
+			t.Errorf("Expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("Lookup reports the source layer", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		if err := s.Set("language", "de"); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+
+		got, err := s.Lookup("Language")
+		if err != nil {
+			t.Fatalf("Lookup() failed: %v", err)
+		}
+		expected := Setting{Key: "language", Value: "de", Source: SourceFile}
+		if got != expected {
+			t.Errorf("Expected %+v, got %+v", expected, got)
+		}
+
+		sources := make(map[string]string)
+		for _, setting := range s.Settings() {
+			sources[setting.Key] = setting.Source
+		}
+		if len(sources) != len(s.Keys()) {
+			t.Errorf("Expected %d settings, got %d", len(s.Keys()), len(sources))
+		}
+		if sources["language"] != SourceFile || sources["default_route"] != SourceDefault {
+			t.Errorf("Unexpected sources: %v", sources)
+		}
+	})
+
+	t.Run("Keys set in config.json come from the file", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		if err := os.WriteFile(s.ConfigPath, []byte(`{"language": "en"}`), 0644); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+		reloaded, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		if setting, _ := reloaded.Lookup("language"); setting.Source != SourceFile {
+			t.Errorf("Expected a key set to its default in the file to come from it, got %q", setting.Source)
+		}
+		if setting, _ := reloaded.Lookup("default_route"); setting.Source != SourceDefault {
+			t.Errorf("Expected a key missing from the file to be a default, got %q", setting.Source)
+		}
+
+		if err := reloaded.SetValue("default_route", "/"); err != nil {
+			t.Fatalf("SetValue() failed: %v", err)
+		}
+		raw, err := os.ReadFile(s.ConfigPath)
+		if err != nil {
+			t.Fatalf("Failed to read config file: %v", err)
+		}
+		expected := "{\n  \"default_route\": \"/\",\n  \"language\": \"en\"\n}"
+		if string(raw) != expected {
+			t.Errorf("Expected only the keys that were set to be saved.\nExpected:\n%s\nGot:\n%s", expected, raw)
+		}
+	})
+
+	t.Run("SetString coerces values to the field type", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		testCases := []struct {
+			value    string
+			expected []string
+		}{
+			{"beta, metrics", []string{"beta", "metrics"}},
+			{`["a,b", "c"]`, []string{"a,b", "c"}},
+			{"", []string{}},
+		}
+		for _, tc := range testCases {
+			if err := s.SetString("features", tc.value); err != nil {
+				t.Fatalf("SetString(%q) failed: %v", tc.value, err)
+			}
+			if !reflect.DeepEqual(tc.expected, s.Features) {
+				t.Errorf("SetString(%q): expected %v, got %v", tc.value, tc.expected, s.Features)
+			}
+		}
+
+		if err := s.SetString("default_route", "/home"); err != nil {
+			t.Fatalf("SetString() failed: %v", err)
+		}
+		raw, err := os.ReadFile(s.ConfigPath)
+		if err != nil {
+			t.Fatalf("Failed to read config file: %v", err)
+		}
+		var saved map[string]interface{}
+		if err := json.Unmarshal(raw, &saved); err != nil {
+			t.Fatalf("Failed to parse config file: %v", err)
+		}
+		if saved["default_route"] != "/home" {
+			t.Errorf("Expected default_route to be saved, got %v", saved["default_route"])
+		}
+	})
+
+	t.Run("SetValue accepts decoded values", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		if err := s.SetValue("features", []interface{}{"beta", "metrics"}); err != nil {
+			t.Fatalf("SetValue() failed: %v", err)
+		}
+		if expected := []string{"beta", "metrics"}; !reflect.DeepEqual(expected, s.Features) {
+			t.Errorf("Expected %v, got %v", expected, s.Features)
+		}
+		if err := s.SetValue("Language", "fr"); err != nil {
+			t.Fatalf("SetValue() failed: %v", err)
+		}
+		reloaded, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		if reloaded.Language != "fr" {
+			t.Errorf("Expected language to be saved, got %q", reloaded.Language)
+		}
+	})
+
+	t.Run("Suggestions offer the current and default values", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		if got := s.Suggestions("language"); !reflect.DeepEqual([]string{"en"}, got) {
+			t.Errorf("Expected [en], got %v", got)
+		}
+		if err := s.SetString("features", "beta,metrics"); err != nil {
+			t.Fatalf("SetString() failed: %v", err)
+		}
+		if err := s.SetString("language", "fr"); err != nil {
+			t.Fatalf("SetString() failed: %v", err)
+		}
+		if got := s.Suggestions("Language"); !reflect.DeepEqual([]string{"fr", "en"}, got) {
+			t.Errorf("Expected [fr en], got %v", got)
+		}
+		if got := s.Suggestions("features"); !reflect.DeepEqual([]string{"beta,metrics"}, got) {
+			t.Errorf("Expected [beta,metrics], got %v", got)
+		}
+		if got := s.Suggestions("missing"); got != nil {
+			t.Errorf("Expected no suggestions for an unknown key, got %v", got)
+		}
+	})
+
+	t.Run("Unset restores the default", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		if err := s.SetString("features", "beta"); err != nil {
+			t.Fatalf("SetString() failed: %v", err)
+		}
+		if err := s.Unset("features"); err != nil {
+			t.Fatalf("Unset() failed: %v", err)
+		}
+		if !reflect.DeepEqual([]string{}, s.Features) {
+			t.Errorf("Expected default features, got %v", s.Features)
+		}
+
+		reloaded, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		setting, err := reloaded.Lookup("features")
+		if err != nil {
+			t.Fatalf("Lookup() failed: %v", err)
+		}
+		if setting.Source != SourceDefault {
+			t.Errorf("Expected source %q after Unset, got %q", SourceDefault, setting.Source)
+		}
+	})
+}
+
+func TestApplyGood(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	s, err := New()
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := s.Set("default_route", "/old"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+
+	data := s.Values()
+	data["language"] = "fr"
+	data["features"] = []interface{}{"beta"}
+	delete(data, "default_route")
+	if err := s.Validate(data); err != nil {
+		t.Fatalf("Validate() failed: %v", err)
+	}
+	if err := s.Apply(data); err != nil {
+		t.Fatalf("Apply() failed: %v", err)
+	}
+	if s.Language != "fr" || !reflect.DeepEqual([]string{"beta"}, s.Features) {
+		t.Errorf("Values were not applied: language=%q features=%v", s.Language, s.Features)
+	}
+	if s.DefaultRoute != "/" {
+		t.Errorf("Expected a missing key to be reset to its default, got %q", s.DefaultRoute)
+	}
+
+	reloaded, err := New()
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if !reflect.DeepEqual(s.Values(), reloaded.Values()) {
+		t.Errorf("Saved configuration differs.\nExpected: %v\nGot: %v", s.Values(), reloaded.Values())
+	}
+
+	before := s.Values()
+	if err := s.Apply(before); err != nil {
+		t.Fatalf("Apply() rejected the values of Values(): %v", err)
+	}
+	if !reflect.DeepEqual(before, s.Values()) {
+		t.Errorf("Applying Values() changed the configuration.\nExpected: %v\nGot: %v", before, s.Values())
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	s, err := New()
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := s.Update(map[string]interface{}{"Language": "fr"}); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if setting, _ := s.Lookup("language"); setting.Value != "fr" || setting.Source != SourceFile {
+		t.Errorf("Expected the language to be set, got %+v", setting)
+	}
+	if setting, _ := s.Lookup("features"); setting.Source != SourceDefault {
+		t.Errorf("Expected other keys to stay defaults, got %+v", setting)
+	}
+
+	err = s.Update(map[string]interface{}{"language": "de", "default_route": "home"})
+	if _, ok := err.(ValidationErrors); !ok {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+	if s.Language != "fr" {
+		t.Errorf("A failed Update() changed the language to %q", s.Language)
+	}
+
+	s.ConfigPath = filepath.Join(t.TempDir(), "missing", "config.json")
+	if err := s.Update(map[string]interface{}{"default_route": "/home"}); err == nil {
+		t.Fatal("Expected an error from Update() with an unwritable config path")
+	}
+	if setting, _ := s.Lookup("default_route"); setting.Value != "/" || setting.Source != SourceDefault {
+		t.Errorf("Expected the value to be restored after a failed save, got %+v", setting)
+	}
+}
+
+func TestApplyBad(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	s, err := New()
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	before, err := os.ReadFile(s.ConfigPath)
+	if err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+
+	data := map[string]interface{}{
+		"language":      "",
+		"default_route": "home",
+		"dataDir":       "relative/data",
+		"features":      map[string]interface{}{"a": true},
+		"colour":        "blue",
+	}
+	err = s.Apply(data)
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+	expected := ValidationErrors{
+		{Key: "colour", Message: "unknown key"},
+		{Key: "dataDir", Message: "must be an absolute path"},
+		{Key: "default_route", Message: `must start with "/"`},
+		{Key: "features", Message: "cannot decode map[string]interface {} into []string"},
+		{Key: "language", Message: "must not be empty"},
+	}
+	if !reflect.DeepEqual(expected, errs) {
+		t.Errorf("Unexpected errors.\nExpected: %v\nGot: %v", expected, errs)
+	}
+
+	after, err := os.ReadFile(s.ConfigPath)
+	if err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+	if string(before) != string(after) || s.Language != "en" {
+		t.Error("A failed Apply() changed the configuration")
+	}
+}
+
+func TestSettingsBad(t *testing.T) {
+	s := &Service{}
+	if _, err := s.Lookup("missing"); err == nil {
+		t.Error("Expected an error from Lookup() for an unknown key")
+	}
+	if err := s.SetString("missing", "x"); err == nil {
+		t.Error("Expected an error from SetString() for an unknown key")
+	}
+	if err := s.Unset("missing"); err == nil {
+		t.Error("Expected an error from Unset() for an unknown key")
+	}
+	if err := s.SetString("features", "[1, "); err == nil {
+		t.Error("Expected an error from SetString() for a malformed list")
+	}
+	if _, ok := s.SetString("default_route", "home").(ValidationErrors); !ok {
+		t.Error("Expected ValidationErrors from SetString() for an invalid value")
+	}
+	if err, ok := s.SetValue("missing", "x").(KeyError); !ok || err.Key != "missing" {
+		t.Errorf("Expected a KeyError from SetValue() for an unknown key, got %v", err)
+	}
+	if _, ok := s.SetValue("features", map[string]interface{}{"a": 1}).(ValidationErrors); !ok {
+		t.Error("Expected ValidationErrors from SetValue() for a value of the wrong type")
+	}
+	s.Features = []string{"kept"}
+	if err := s.SetValue("features", "a,b"); err == nil {
+		t.Error("Expected an error from SetValue() without a config path")
+	} else if !reflect.DeepEqual([]string{"kept"}, s.Features) {
+		t.Errorf("Expected the value to be restored after a failed save, got %v", s.Features)
+	}
+
+	t.Run("Unset keeps the value if saving fails", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		if err := s.SetValue("language", "fr"); err != nil {
+			t.Fatalf("SetValue() failed: %v", err)
+		}
+		s.ConfigPath = filepath.Join(t.TempDir(), "missing", "config.json")
+		if err := s.Unset("language"); err == nil {
+			t.Fatal("Expected an error from Unset() with an unwritable config path")
+		}
+		if setting, _ := s.Lookup("language"); setting.Value != "fr" || setting.Source != SourceFile {
+			t.Errorf("Expected the value to be restored after a failed save, got %+v", setting)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// loadFile opens path and decodes its content with decode. It implements
+// ConfigFormat.Load for formats whose Decode does all the work.
+func loadFile(path string, decode func(io.Reader) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return decode(file)
+}
+
+// saveFile encodes data with encode and writes the result to path. The data
+// is encoded in memory first, so an encoding error leaves an existing file
+// untouched.
+func saveFile(path string, data map[string]interface{}, encode func(io.Writer, map[string]interface{}) error) error {
+	var buf bytes.Buffer
+	if err := encode(&buf, data); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// readExisting returns the content of path, or nil if it does not exist. It
+// is used by formats whose Save keeps details of the file it replaces.
+func readExisting(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return data, nil
+}
+
+// writeFileAtomic writes data to path through a temporary file in the same
+// directory that is renamed over path, so readers never see a partly written
+// file and a failed write leaves the old content in place. An existing file
+// keeps its permissions; perm is used for new files.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
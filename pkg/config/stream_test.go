@@ -0,0 +1,129 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStreamingFormatsGood(t *testing.T) {
+	data := map[string]interface{}{
+		"name":   "demo",
+		"server": map[string]interface{}{"host": "localhost"},
+	}
+	flat := map[string]interface{}{"NAME": "demo", "HOST": "localhost"}
+
+	for _, info := range Formats() {
+		ext := info.Extensions[0]
+		t.Run(ext, func(t *testing.T) {
+			format, err := GetConfigFormat(ext)
+			if err != nil {
+				t.Fatalf("GetConfigFormat() failed: %v", err)
+			}
+			input := data
+			if ext == ".env" || ext == ".properties" {
+				input = flat
+			}
+
+			var buf bytes.Buffer
+			if err := format.Encode(&buf, input); err != nil {
+				t.Fatalf("Encode() failed: %v", err)
+			}
+
+			// Encode writes the same bytes as Save for a new file.
+			path := filepath.Join(t.TempDir(), "stream"+ext)
+			if err := format.Save(path, input); err != nil {
+				t.Fatalf("Save() failed: %v", err)
+			}
+			saved, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read file: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), saved) {
+				t.Errorf("Encode() and Save() output differ.\nEncode:\n%s\nSave:\n%s", buf.Bytes(), saved)
+			}
+
+			got, err := format.Decode(&buf)
+			if err != nil {
+				t.Fatalf("Decode() failed: %v", err)
+			}
+			if !reflect.DeepEqual(input, got) {
+				t.Errorf("Round trip mismatch.\nExpected: %#v\nGot: %#v", input, got)
+			}
+		})
+	}
+
+	t.Run("Decodes from any reader", func(t *testing.T) {
+		got, err := (&YAMLFormat{}).Decode(strings.NewReader("name: demo\n"))
+		if err != nil {
+			t.Fatalf("Decode() failed: %v", err)
+		}
+		if got["name"] != "demo" {
+			t.Errorf("Expected name 'demo', got %v", got["name"])
+		}
+	})
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := writeFileAtomic(path, []byte("one"), 0600); err != nil {
+		t.Fatalf("writeFileAtomic() failed: %v", err)
+	}
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatalf("Chmod() failed: %v", err)
+	}
+	if err := writeFileAtomic(path, []byte("two"), 0600); err != nil {
+		t.Fatalf("writeFileAtomic() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if string(data) != "two" {
+		t.Errorf("Expected %q, got %q", "two", data)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() failed: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0640 {
+		t.Errorf("Expected permissions 0640 to be kept, got %o", perm)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files to remain, got %d entries", len(entries))
+	}
+}
+
+func TestStreamingFormatsBad(t *testing.T) {
+	t.Run("Failed save leaves the file untouched", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keep.json")
+		if err := os.WriteFile(path, []byte(`{"a": 1}`), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if err := (&JSONFormat{}).Save(path, map[string]interface{}{"ch": make(chan int)}); err == nil {
+			t.Fatal("Expected an error for an unsupported value")
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		if string(data) != `{"a": 1}` {
+			t.Errorf("Expected the file to be unchanged, got %s", data)
+		}
+	})
+
+	t.Run("Invalid input", func(t *testing.T) {
+		if _, err := (&JSONFormat{}).Decode(strings.NewReader("{")); err == nil {
+			t.Error("Expected an error for truncated JSON")
+		}
+	})
+}
@@ -0,0 +1,493 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// structTag is the struct tag read by SaveStructAs and LoadStructAs. Fields
+// without it fall back to their json tag and then to the field name.
+const structTag = "config"
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// SaveStructAs saves a struct to a file in the config directory using the
+// format registered for ext, such as ".yaml", ".ini" or ".xml". The file is
+// named key+ext. Fields are named by their `config:"name"` tag, falling back
+// to the json tag and then the field name, and support the "omitempty" and
+// "-" options:
+//
+//	type Database struct {
+//		Host    string        `config:"host"`
+//		Port    int           `config:"port"`
+//		Timeout time.Duration `config:"timeout,omitempty"`
+//	}
+//	err := cfg.SaveStructAs("database", ".yaml", Database{Host: "localhost", Port: 5432})
+//
+// Nested structs become nested maps and values implementing
+// encoding.TextMarshaler, such as time.Time, are saved as strings so they
+// can be stored in every format.
+func (s *Service) SaveStructAs(key, ext string, data interface{}) error {
+	format, path, err := s.structFile(key, ext)
+	if err != nil {
+		return err
+	}
+	m, err := structToMap(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode struct for key '%s': %w", key, err)
+	}
+	return format.Save(path, m)
+}
+
+// LoadStructAs loads a struct saved with SaveStructAs from the file key+ext
+// in the config directory. Values are converted to the field types where
+// the format does not keep them, so a port stored as "8080" in a dotenv file
+// still loads into an int field, and a time.Duration can be written as
+// "30s" as well as in nanoseconds. Keys without a matching field are ignored.
+// If the file does not exist, data is left unchanged and nil is returned.
+//
+// Example:
+//
+//	var db Database
+//	err := cfg.LoadStructAs("database", ".yaml", &db)
+func (s *Service) LoadStructAs(key, ext string, data interface{}) error {
+	format, path, err := s.structFile(key, ext)
+	if err != nil {
+		return err
+	}
+	target := reflect.ValueOf(data)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return fmt.Errorf("output argument must be a non-nil pointer")
+	}
+	m, err := format.Load(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read struct file for key '%s': %w", key, err)
+	}
+	if err := decodeValue(m, target.Elem(), ""); err != nil {
+		return fmt.Errorf("failed to decode struct for key '%s': %w", key, err)
+	}
+	return nil
+}
+
+// structFile returns the format and path used for a struct saved as key+ext.
+func (s *Service) structFile(key, ext string) (ConfigFormat, string, error) {
+	ext, err := normalizeExt(ext)
+	if err != nil {
+		return nil, "", err
+	}
+	format, err := GetConfigFormat(ext)
+	if err != nil {
+		return nil, "", err
+	}
+	return format, filepath.Join(s.ConfigDir, key+ext), nil
+}
+
+// structField describes an exported struct field and the key it maps to.
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// structFields returns the fields of t in declaration order. The fields of
+// embedded structs without a name are promoted, as with encoding/json.
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup(structTag)
+		if !ok {
+			tag = f.Tag.Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for _, inner := range structFields(ft) {
+				inner.index = append([]int{i}, inner.index...)
+				fields = append(fields, inner)
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, structField{
+			name:      name,
+			index:     []int{i},
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+		})
+	}
+	return fields
+}
+
+// structToMap converts a struct (or a pointer to one, or a map) into the
+// map[string]interface{} accepted by ConfigFormat.Save.
+func structToMap(data interface{}) (map[string]interface{}, error) {
+	v, err := encodeValue(reflect.ValueOf(data))
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot save %T as a struct, it must be a struct or a map", data)
+	}
+	return m, nil
+}
+
+// encodeValue converts v into plain maps, slices and scalars.
+func encodeValue(v reflect.Value) (interface{}, error) {
+	v = indirectValue(v)
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.Type().Implements(textMarshalerType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return string(text), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		m := make(map[string]interface{})
+		for _, f := range structFields(v.Type()) {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok || (f.omitEmpty && fv.IsZero()) {
+				continue
+			}
+			value, err := encodeValue(fv)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+			m[f.name] = value
+		}
+		return m, nil
+	case reflect.Map:
+		m := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			value, err := encodeValue(v.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(k.Interface())] = value
+		}
+		return m, nil
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if v.Kind() == reflect.Slice {
+				return v.Bytes(), nil
+			}
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return b, nil
+		}
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			value, err := encodeValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			list[i] = value
+		}
+		return list, nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() <= math.MaxInt64 {
+			return int64(v.Uint()), nil
+		}
+		return v.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// fieldByIndex returns the field at index, reporting false if it is reached
+// through a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// decodeValue stores src, as returned by ConfigFormat.Load, in dst,
+// converting between strings, numbers and bools where needed. path names the
+// value in error messages.
+func decodeValue(src interface{}, dst reflect.Value, path string) error {
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return decodeValue(src, dst.Elem(), path)
+	}
+	if dst.Kind() == reflect.Interface && dst.NumMethod() == 0 {
+		dst.Set(reflect.ValueOf(src))
+		return nil
+	}
+
+	fail := func() error {
+		if path == "" {
+			return fmt.Errorf("cannot decode %T into %s", src, dst.Type())
+		}
+		return fmt.Errorf("%s: cannot decode %T into %s", path, src, dst.Type())
+	}
+
+	if dst.Type() == timeType {
+		if t, ok := src.(time.Time); ok {
+			dst.Set(reflect.ValueOf(t))
+			return nil
+		}
+	}
+	if dst.Type() == durationType {
+		if s, ok := src.(string); ok {
+			if d, err := time.ParseDuration(strings.TrimSpace(s)); err == nil {
+				dst.SetInt(int64(d))
+				return nil
+			}
+		}
+	}
+	if reflect.PtrTo(dst.Type()).Implements(textUnmarshalerType) {
+		if s, ok := src.(string); ok {
+			if err := dst.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			return nil
+		}
+	}
+
+	switch dst.Kind() {
+	case reflect.Struct:
+		m, ok := src.(map[string]interface{})
+		if !ok {
+			return fail()
+		}
+		for _, f := range structFields(dst.Type()) {
+			value, ok := m[f.name]
+			if !ok {
+				continue
+			}
+			fv, err := allocFieldByIndex(dst, f.index)
+			if err != nil {
+				return err
+			}
+			if err := decodeValue(value, fv, joinPath(path, f.name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		m, ok := src.(map[string]interface{})
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			return fail()
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(dst.Type(), len(m)))
+		}
+		for k, value := range m {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if err := decodeValue(value, elem, joinPath(path, k)); err != nil {
+				return err
+			}
+			dst.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), elem)
+		}
+		return nil
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			switch b := src.(type) {
+			case []byte:
+				dst.SetBytes(append([]byte(nil), b...))
+				return nil
+			case string:
+				dst.SetBytes([]byte(b))
+				return nil
+			}
+		}
+		// Any list is accepted, such as the []string values of Values.
+		list := reflect.ValueOf(src)
+		if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+			return fail()
+		}
+		out := reflect.MakeSlice(dst.Type(), list.Len(), list.Len())
+		for i := 0; i < list.Len(); i++ {
+			if err := decodeValue(list.Index(i).Interface(), out.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(out)
+		return nil
+	case reflect.String:
+		switch s := src.(type) {
+		case string:
+			dst.SetString(s)
+		case []byte:
+			dst.SetString(string(s))
+		case bool, int64, uint64, float64:
+			dst.SetString(fmt.Sprint(s))
+		default:
+			return fail()
+		}
+		return nil
+	case reflect.Bool:
+		switch b := src.(type) {
+		case bool:
+			dst.SetBool(b)
+		case string:
+			parsed, err := strconv.ParseBool(b)
+			if err != nil {
+				return fail()
+			}
+			dst.SetBool(parsed)
+		default:
+			return fail()
+		}
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := toInt64(src)
+		if !ok || dst.OverflowInt(n) {
+			return fail()
+		}
+		dst.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := toUint64(src)
+		if !ok || dst.OverflowUint(n) {
+			return fail()
+		}
+		dst.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		n, ok := toFloat64(src)
+		if !ok {
+			return fail()
+		}
+		dst.SetFloat(n)
+		return nil
+	}
+	return fail()
+}
+
+// allocFieldByIndex returns the field at index, allocating nil embedded
+// pointers on the way.
+func allocFieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot set embedded pointer to unexported struct %s", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+// joinPath appends key to a dotted path.
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// toInt64 converts a loaded number or numeric string to an int64.
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case uint64:
+		if n <= math.MaxInt64 {
+			return int64(n), true
+		}
+	case float64:
+		if n == math.Trunc(n) && n >= math.MinInt64 && n < math.MaxInt64 {
+			return int64(n), true
+		}
+	case string:
+		if i, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64); err == nil {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// toUint64 converts a loaded number or numeric string to a uint64.
+func toUint64(v interface{}) (uint64, bool) {
+	switch n := v.(type) {
+	case uint64:
+		return n, true
+	case string:
+		if u, err := strconv.ParseUint(strings.TrimSpace(n), 10, 64); err == nil {
+			return u, true
+		}
+	default:
+		if i, ok := toInt64(v); ok && i >= 0 {
+			return uint64(i), true
+		}
+	}
+	return 0, false
+}
+
+// toFloat64 converts a loaded number or numeric string to a float64.
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(n), 64); err == nil {
+			return f, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type structTestServer struct {
+	Host string `config:"host"`
+	Port int    `config:"port"`
+}
+
+type structTestBase struct {
+	Version string `config:"version"`
+}
+
+type structTestConfig struct {
+	structTestBase
+	Name     string            `config:"name"`
+	Count    uint16            `config:"count"`
+	Ratio    float64           `config:"ratio"`
+	Enabled  bool              `config:"enabled"`
+	Started  time.Time         `config:"started"`
+	Tags     []string          `config:"tags"`
+	Server   structTestServer  `config:"server"`
+	Backup   *structTestServer `config:"backup,omitempty"`
+	Limits   map[string]int    `config:"limits"`
+	JSONName string            `json:"json_name"`
+	Skipped  string            `config:"-"`
+	internal string
+}
+
+func TestStructFormatsGood(t *testing.T) {
+	t.Run("Round trips a struct in every structured format", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+
+		expected := structTestConfig{
+			structTestBase: structTestBase{Version: "1.2"},
+			Name:           "demo",
+			Count:          3,
+			Ratio:          0.5,
+			Enabled:        true,
+			Started:        time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC),
+			Tags:           []string{"a", "b"},
+			Server:         structTestServer{Host: "localhost", Port: 8080},
+			Backup:         &structTestServer{Host: "backup", Port: 8081},
+			Limits:         map[string]int{"cpu": 2},
+			JSONName:       "from json tag",
+			Skipped:        "not saved",
+		}
+		want := expected
+		want.Skipped = ""
+
+		for _, ext := range []string{".json", ".jsonc", ".json5", ".yaml", ".ini", ".xml", ".hcl", ".plist", ".cbor", ".msgpack"} {
+			t.Run(ext, func(t *testing.T) {
+				if err := s.SaveStructAs("typed", ext, expected); err != nil {
+					t.Fatalf("SaveStructAs() failed: %v", err)
+				}
+				var got structTestConfig
+				if err := s.LoadStructAs("typed", ext, &got); err != nil {
+					t.Fatalf("LoadStructAs() failed: %v", err)
+				}
+				if !reflect.DeepEqual(want, got) {
+					t.Errorf("Round trip mismatch.\nExpected: %+v\nGot: %+v", want, got)
+				}
+			})
+		}
+	})
+
+	t.Run("Converts string values from flat formats", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+
+		type flat struct {
+			Host    string        `config:"HOST"`
+			Port    int           `config:"PORT"`
+			Debug   bool          `config:"DEBUG"`
+			Timeout time.Duration `config:"TIMEOUT"`
+		}
+		expected := flat{Host: "example.com", Port: 443, Debug: true, Timeout: 5 * time.Second}
+		for _, ext := range []string{"env", ".properties"} {
+			if err := s.SaveStructAs("flat", ext, expected); err != nil {
+				t.Fatalf("SaveStructAs(%s) failed: %v", ext, err)
+			}
+			var got flat
+			if err := s.LoadStructAs("flat", ext, &got); err != nil {
+				t.Fatalf("LoadStructAs(%s) failed: %v", ext, err)
+			}
+			if got != expected {
+				t.Errorf("%s: expected %+v, got %+v", ext, expected, got)
+			}
+		}
+	})
+
+	t.Run("Durations can be written as text", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		path := filepath.Join(s.ConfigDir, "timeouts.yaml")
+		if err := os.WriteFile(path, []byte("read: 30s\nwrite: 1m30s\nidle: 5000000000\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		var got struct {
+			Read  time.Duration `config:"read"`
+			Write time.Duration `config:"write"`
+			Idle  time.Duration `config:"idle"`
+		}
+		if err := s.LoadStructAs("timeouts", ".yaml", &got); err != nil {
+			t.Fatalf("LoadStructAs() failed: %v", err)
+		}
+		if got.Read != 30*time.Second || got.Write != 90*time.Second || got.Idle != 5*time.Second {
+			t.Errorf("Unexpected durations: %+v", got)
+		}
+	})
+
+	t.Run("Writes names from the config tag", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		if err := s.SaveStructAs("server", ".yaml", structTestServer{Host: "h", Port: 1}); err != nil {
+			t.Fatalf("SaveStructAs() failed: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(s.ConfigDir, "server.yaml"))
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		if string(data) != "host: h\nport: 1\n" {
+			t.Errorf("Unexpected YAML:\n%s", data)
+		}
+	})
+
+	t.Run("Missing file leaves the struct unchanged", func(t *testing.T) {
+		_, cleanup := setupTestEnv(t)
+		defer cleanup()
+		s, err := New()
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		got := structTestServer{Host: "default"}
+		if err := s.LoadStructAs("missing", ".yaml", &got); err != nil {
+			t.Fatalf("LoadStructAs() failed: %v", err)
+		}
+		if got.Host != "default" {
+			t.Errorf("Expected the struct to be unchanged, got %+v", got)
+		}
+	})
+}
+
+func TestStructFormatsBad(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+	s, err := New()
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	t.Run("Unsupported extension", func(t *testing.T) {
+		if err := s.SaveStructAs("x", ".txt", structTestServer{}); err == nil {
+			t.Error("Expected an error for an unsupported extension")
+		}
+	})
+
+	t.Run("Non-pointer target", func(t *testing.T) {
+		if err := s.LoadStructAs("x", ".json", structTestServer{}); err == nil {
+			t.Error("Expected an error for a non-pointer target")
+		}
+	})
+
+	t.Run("Non-struct value", func(t *testing.T) {
+		if err := s.SaveStructAs("x", ".json", 42); err == nil {
+			t.Error("Expected an error for a non-struct value")
+		}
+	})
+
+	t.Run("Mismatched value type", func(t *testing.T) {
+		path := filepath.Join(s.ConfigDir, "bad.yaml")
+		if err := os.WriteFile(path, []byte("host: h\nport: eighty\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		var got structTestServer
+		err := s.LoadStructAs("bad", ".yaml", &got)
+		if err == nil || !strings.Contains(err.Error(), "port") {
+			t.Errorf("Expected an error naming the port field, got %v", err)
+		}
+	})
+}
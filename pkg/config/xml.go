@@ -0,0 +1,398 @@
+package config
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// xmlRoot is the name of the root element written by XMLFormat.
+	xmlRoot = "config"
+	// xmlTypeAttr holds the type hint of an element.
+	xmlTypeAttr = "type"
+	// xmlListAttr marks an element as the only element of a list.
+	xmlListAttr = "list"
+	// xmlEntry is the element used for keys that are not valid XML names,
+	// and for the legacy flat layout.
+	xmlEntry = "entry"
+	// xmlKeyAttr holds the original key of an entry element.
+	xmlKeyAttr = "key"
+	// xmlText is the map key that holds the text of an element that also
+	// has attributes.
+	xmlText = "#text"
+	// xmlAttrPrefix marks map keys that are written as attributes.
+	xmlAttrPrefix = "@"
+)
+
+// xmlNode is a generic XML element.
+type xmlNode struct {
+	name     string
+	attrs    []xml.Attr
+	children []*xmlNode
+	text     string
+}
+
+// attr returns the value of the named attribute.
+func (n *xmlNode) attr(name string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// key returns the map key an element represents.
+func (n *xmlNode) key() string {
+	if n.name == xmlEntry {
+		if key, ok := n.attr(xmlKeyAttr); ok {
+			return key
+		}
+	}
+	return n.name
+}
+
+// parseXML reads a document into a tree of xmlNodes and returns its root.
+func parseXML(data []byte) (*xmlNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var stack []*xmlNode
+	var root *xmlNode
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if root != nil && len(stack) == 0 {
+				return root, nil
+			}
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("xml: document has no root element")
+			}
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: t.Name.Local, attrs: t.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if root == nil {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+}
+
+// decodeXMLDocument converts the root element of a document into a map,
+// accepting both the structured layout and the legacy flat list of
+// <entry><key/><value/></entry> elements.
+func decodeXMLDocument(root *xmlNode) (map[string]interface{}, error) {
+	if isLegacyXML(root) {
+		result := make(map[string]interface{}, len(root.children))
+		for _, entry := range root.children {
+			var key, value string
+			for _, child := range entry.children {
+				switch child.name {
+				case "key":
+					key = child.text
+				case "value":
+					value = child.text
+				}
+			}
+			result[key] = value
+		}
+		return result, nil
+	}
+
+	value, err := decodeXMLElement(root)
+	if err != nil {
+		return nil, err
+	}
+	result, ok := value.(map[string]interface{})
+	if !ok {
+		if value == nil || value == "" {
+			return make(map[string]interface{}), nil
+		}
+		return nil, fmt.Errorf("xml: root element <%s> does not contain a map", root.name)
+	}
+	return result, nil
+}
+
+// isLegacyXML reports whether root uses the original flat entry layout.
+func isLegacyXML(root *xmlNode) bool {
+	if len(root.children) == 0 {
+		return false
+	}
+	for _, child := range root.children {
+		if child.name != xmlEntry {
+			return false
+		}
+		if _, ok := child.attr(xmlKeyAttr); ok {
+			return false
+		}
+		hasKey := false
+		for _, c := range child.children {
+			if c.name == "key" {
+				hasKey = true
+			}
+		}
+		if !hasKey {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeXMLElement converts an element into a Go value, honouring type
+// hints. Elements without hints decode to a map if they have child elements
+// or attributes and to a string otherwise.
+func decodeXMLElement(n *xmlNode) (interface{}, error) {
+	hint, _ := n.attr(xmlTypeAttr)
+	switch hint {
+	case "null":
+		return nil, nil
+	case "list":
+		return []interface{}{}, nil
+	case "int":
+		v, err := strconv.ParseInt(strings.TrimSpace(n.text), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("xml: element <%s>: %w", n.name, err)
+		}
+		return v, nil
+	case "float":
+		v, err := strconv.ParseFloat(strings.TrimSpace(n.text), 64)
+		if err != nil {
+			return nil, fmt.Errorf("xml: element <%s>: %w", n.name, err)
+		}
+		return v, nil
+	case "bool":
+		v, err := strconv.ParseBool(strings.TrimSpace(n.text))
+		if err != nil {
+			return nil, fmt.Errorf("xml: element <%s>: %w", n.name, err)
+		}
+		return v, nil
+	case "", "string", "map":
+	default:
+		return nil, fmt.Errorf("xml: element <%s> has unknown type %q", n.name, hint)
+	}
+
+	var attrs []xml.Attr
+	for _, a := range n.attrs {
+		switch a.Name.Local {
+		case xmlTypeAttr, xmlListAttr:
+		case xmlKeyAttr:
+			if n.name != xmlEntry {
+				attrs = append(attrs, a)
+			}
+		default:
+			attrs = append(attrs, a)
+		}
+	}
+
+	if len(n.children) == 0 && len(attrs) == 0 && hint != "map" {
+		return n.text, nil
+	}
+
+	result := make(map[string]interface{})
+	for _, a := range attrs {
+		result[xmlAttrPrefix+a.Name.Local] = a.Value
+	}
+	if len(n.children) == 0 {
+		if hint != "map" && n.text != "" {
+			result[xmlText] = n.text
+		}
+		return result, nil
+	}
+	if text := strings.TrimSpace(n.text); text != "" {
+		result[xmlText] = text
+	}
+
+	lists := make(map[string]bool)
+	for _, child := range n.children {
+		key := child.key()
+		value, err := decodeXMLElement(child)
+		if err != nil {
+			return nil, err
+		}
+		existing, seen := result[key]
+		_, marked := child.attr(xmlListAttr)
+		switch {
+		case lists[key]:
+			result[key] = append(existing.([]interface{}), value)
+		case seen:
+			result[key] = []interface{}{existing, value}
+			lists[key] = true
+		case marked:
+			result[key] = []interface{}{value}
+			lists[key] = true
+		default:
+			result[key] = value
+		}
+	}
+	return result, nil
+}
+
+// encodeXMLDocument writes data as the children of the root element.
+func encodeXMLDocument(data map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := encodeXMLElement(enc, xmlRoot, reflect.ValueOf(data), nil); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeXMLElement writes v as an element named key. extra holds attributes
+// added by the caller, such as the list marker.
+func encodeXMLElement(enc *xml.Encoder, key string, v reflect.Value, extra []xml.Attr) error {
+	v = indirectValue(v)
+	start := xml.StartElement{Name: xml.Name{Local: key}, Attr: extra}
+	if !isXMLName(key) {
+		start.Name.Local = xmlEntry
+		start.Attr = append([]xml.Attr{{Name: xml.Name{Local: xmlKeyAttr}, Value: key}}, extra...)
+	}
+	hint := func(t string) {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: xmlTypeAttr}, Value: t})
+	}
+
+	var text string
+	switch v.Kind() {
+	case reflect.Invalid:
+		hint("null")
+	case reflect.String:
+		text = v.String()
+	case reflect.Bool:
+		hint("bool")
+		text = strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		hint("int")
+		text = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		hint("int")
+		text = strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		hint("float")
+		text = strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case reflect.Map:
+		if v.Len() == 0 {
+			hint("map")
+		}
+		return encodeXMLMap(enc, start, v)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			text = string(v.Bytes())
+			break
+		}
+		for i := 0; i < v.Len(); i++ {
+			if elem := indirectValue(v.Index(i)); elem.Kind() == reflect.Slice || elem.Kind() == reflect.Array {
+				return fmt.Errorf("xml: key %q: nested lists are not supported", key)
+			}
+		}
+		switch v.Len() {
+		case 0:
+			hint("list")
+		case 1:
+			return encodeXMLElement(enc, key, v.Index(0), append(extra, xml.Attr{Name: xml.Name{Local: xmlListAttr}, Value: "true"}))
+		default:
+			for i := 0; i < v.Len(); i++ {
+				if err := encodeXMLElement(enc, key, v.Index(i), extra); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	default:
+		text = formatText(v.Interface())
+	}
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if text != "" {
+		if err := enc.EncodeToken(xml.CharData(text)); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// encodeXMLMap writes a map as an element whose "@" keys with string values
+// become attributes, "#text" becomes text content and other keys, including
+// "@" keys with other values, become child elements.
+func encodeXMLMap(enc *xml.Encoder, start xml.StartElement, m reflect.Value) error {
+	keys := make([]string, 0, m.Len())
+	values := make(map[string]reflect.Value, m.Len())
+	for _, k := range m.MapKeys() {
+		key := fmt.Sprint(k.Interface())
+		keys = append(keys, key)
+		values[key] = indirectValue(m.MapIndex(k))
+	}
+	sort.Strings(keys)
+
+	var children []string
+	var text string
+	for _, key := range keys {
+		switch {
+		case key == xmlText:
+			text = fmt.Sprint(values[key].Interface())
+		case strings.HasPrefix(key, xmlAttrPrefix) && isXMLName(key[1:]) && !isReservedXMLAttr(key[1:]) && values[key].Kind() == reflect.String:
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: key[1:]}, Value: values[key].String()})
+		default:
+			children = append(children, key)
+		}
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if text != "" {
+		if err := enc.EncodeToken(xml.CharData(text)); err != nil {
+			return err
+		}
+	}
+	for _, key := range children {
+		if err := encodeXMLElement(enc, key, values[key], nil); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// isReservedXMLAttr reports whether name is an attribute XMLFormat uses for
+// its own markup.
+func isReservedXMLAttr(name string) bool {
+	return name == xmlTypeAttr || name == xmlListAttr || name == xmlKeyAttr
+}
+
+// isXMLName reports whether s can be used as an element or attribute name.
+func isXMLName(s string) bool {
+	if s == "" || s == xmlEntry {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || unicode.IsLetter(r) {
+			continue
+		}
+		if i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r)) {
+			continue
+		}
+		return false
+	}
+	return !strings.HasPrefix(strings.ToLower(s), "xml")
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestXMLFormatGood(t *testing.T) {
+	t.Run("Round-trips nested maps, lists, attributes and types", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.xml")
+		data := map[string]interface{}{
+			"name":    "demo",
+			"port":    int64(8080),
+			"ratio":   0.5,
+			"debug":   true,
+			"nothing": nil,
+			"tags":    []interface{}{"a", "b"},
+			"single":  []interface{}{int64(1)},
+			"none":    []interface{}{},
+			"empty":   map[string]interface{}{},
+			"my key":  "not a valid element name",
+			"entry":   "reserved element name",
+			"server": map[string]interface{}{
+				"@tls": "on",
+				"host": "localhost",
+				"endpoints": []interface{}{
+					map[string]interface{}{"path": "/a"},
+					map[string]interface{}{"path": "/b"},
+				},
+			},
+			"link": map[string]interface{}{
+				"@href": "https://example.com",
+				"#text": "Example",
+			},
+			"event": map[string]interface{}{
+				"@id":        "e1",
+				"@timestamp": int64(1700000000),
+				"@tags":      []interface{}{"a"},
+			},
+		}
+
+		f := &XMLFormat{}
+		if err := f.Save(path, data); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		for _, want := range []string{`<port type="int">8080</port>`, `<server tls="on">`, `<tags>a</tags>`, `<entry key="my key">`, `<event id="e1">`, `<entry key="@timestamp" type="int">1700000000</entry>`} {
+			if !strings.Contains(string(raw), want) {
+				t.Errorf("Expected output to contain %s, got:\n%s", want, raw)
+			}
+		}
+
+		got, err := f.Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		if !reflect.DeepEqual(data, got) {
+			t.Errorf("Round trip mismatch.\nExpected: %#v\nGot: %#v\nFile:\n%s", data, got, raw)
+		}
+	})
+
+	t.Run("Loads the legacy entry layout", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "legacy.xml")
+		src := `<config>
+  <entry>
+    <key>key1</key>
+    <value>value1</value>
+  </entry>
+  <entry>
+    <key>key2</key>
+    <value>123</value>
+  </entry>
+</config>`
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		got, err := (&XMLFormat{}).Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		expected := map[string]interface{}{"key1": "value1", "key2": "123"}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Unexpected result.\nExpected: %v\nGot: %v", expected, got)
+		}
+	})
+
+	t.Run("Loads untyped hand-written XML as strings and maps", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.xml")
+		src := `<?xml version="1.0"?>
+<settings version="2">
+  <!-- database -->
+  <database>
+    <host>db.local</host>
+    <port>5432</port>
+  </database>
+  <mirror>one</mirror>
+  <mirror>two</mirror>
+</settings>`
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		got, err := (&XMLFormat{}).Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		expected := map[string]interface{}{
+			"@version": "2",
+			"database": map[string]interface{}{"host": "db.local", "port": "5432"},
+			"mirror":   []interface{}{"one", "two"},
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Unexpected result.\nExpected: %v\nGot: %v", expected, got)
+		}
+	})
+}
+
+func TestXMLFormatBad(t *testing.T) {
+	t.Run("Invalid type hint value", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.xml")
+		if err := os.WriteFile(path, []byte(`<config><port type="int">abc</port></config>`), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if _, err := (&XMLFormat{}).Load(path); err == nil {
+			t.Errorf("Expected an error for an invalid int, but got nil")
+		}
+	})
+
+	t.Run("Nested lists", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.xml")
+		data := map[string]interface{}{"matrix": []interface{}{[]interface{}{1}}}
+		if err := (&XMLFormat{}).Save(path, data); err == nil {
+			t.Errorf("Expected an error for a nested list, but got nil")
+		}
+	})
+
+	t.Run("Malformed document", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.xml")
+		if err := os.WriteFile(path, []byte(`<config><open></config>`), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if _, err := (&XMLFormat{}).Load(path); err == nil {
+			t.Errorf("Expected an error for malformed XML, but got nil")
+		}
+	})
+}
@@ -0,0 +1,262 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// mergeYAML returns the YAML encoding of data. When existing holds a YAML
+// document whose root is a mapping, the document's node tree is updated in
+// place so that comments, key order, anchors, aliases and quoting styles are
+// kept for every value that did not change. Otherwise data is encoded from
+// scratch.
+func mergeYAML(existing []byte, data map[string]interface{}) ([]byte, error) {
+	var doc yaml.Node
+	if len(bytes.TrimSpace(existing)) == 0 || yaml.Unmarshal(existing, &doc) != nil ||
+		doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return encodeYAML(data, 2)
+	}
+
+	if _, err := mergeYAMLNode(&doc, data); err != nil {
+		return nil, err
+	}
+	return encodeYAML(&doc, detectYAMLIndent(existing))
+}
+
+// mergeYAMLNode updates a document node whose root is a mapping so that it
+// represents data, and returns it.
+func mergeYAMLNode(doc *yaml.Node, data map[string]interface{}) (*yaml.Node, error) {
+	m := &yamlMerger{replaced: make(map[*yaml.Node]*yaml.Node)}
+	root, err := m.merge(doc.Content[0], data)
+	if err != nil {
+		return nil, err
+	}
+	doc.Content[0] = root
+	clearMergeTags(doc)
+	return doc, nil
+}
+
+// clearMergeTags resets the tag of every merge key ("<<"). yaml.v3 writes an
+// explicit "!!merge" tag for these keys unless the tag is left for the
+// encoder to resolve.
+func clearMergeTags(n *yaml.Node) {
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Tag == "!!merge" {
+				n.Content[i].Tag = ""
+			}
+		}
+	}
+	for _, child := range n.Content {
+		clearMergeTags(child)
+	}
+}
+
+// encodeYAML encodes v using the given indentation width.
+func encodeYAML(v interface{}, indent int) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// detectYAMLIndent returns the indentation width of the first indented
+// mapping entry in src, defaulting to two spaces.
+func detectYAMLIndent(src []byte) int {
+	for _, line := range strings.Split(string(src), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || trimmed == line || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "- ") {
+			continue
+		}
+		if n := len(line) - len(trimmed); n >= 2 {
+			return n
+		}
+	}
+	return 2
+}
+
+// yamlMerger applies new values to an existing YAML node tree.
+type yamlMerger struct {
+	// replaced maps anchored nodes that were swapped for new nodes to their
+	// replacements, so aliases keep pointing at the live node.
+	replaced map[*yaml.Node]*yaml.Node
+}
+
+// target returns the node an alias currently refers to.
+func (m *yamlMerger) target(alias *yaml.Node) *yaml.Node {
+	if r, ok := m.replaced[alias.Alias]; ok {
+		alias.Alias = r
+	}
+	return alias.Alias
+}
+
+// merge returns a node representing v, reusing n (and its comments, style
+// and anchors) where the value or shape is unchanged.
+func (m *yamlMerger) merge(n *yaml.Node, v interface{}) (*yaml.Node, error) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		// An alias is kept only while it still resolves to the wanted value.
+		var current interface{}
+		if err := m.target(n).Decode(&current); err == nil && yamlEqual(current, v) {
+			return n, nil
+		}
+	case yaml.MappingNode:
+		if data, ok := v.(map[string]interface{}); ok {
+			return m.mergeMapping(n, data)
+		}
+	case yaml.SequenceNode:
+		if list, ok := v.([]interface{}); ok {
+			return m.mergeSequence(n, list)
+		}
+	case yaml.ScalarNode:
+		var current interface{}
+		if err := n.Decode(&current); err == nil && yamlEqual(current, v) {
+			return n, nil
+		}
+	}
+	r, err := replaceYAMLNode(n, v)
+	if err != nil {
+		return nil, err
+	}
+	if n.Anchor != "" {
+		m.replaced[n] = r
+	}
+	return r, nil
+}
+
+// mergeMapping updates the entries of a mapping node. Keys that are no
+// longer present are removed, existing keys keep their position and new keys
+// are appended in sorted order. Merge keys ("<<") are kept, and values
+// inherited through them are not duplicated.
+func (m *yamlMerger) mergeMapping(n *yaml.Node, data map[string]interface{}) (*yaml.Node, error) {
+	inherited := make(map[string]interface{})
+	seen := make(map[string]bool, len(data))
+	content := make([]*yaml.Node, 0, len(n.Content))
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.Tag == "!!merge" || key.Value == "<<" {
+			if value.Kind == yaml.AliasNode {
+				m.target(value)
+			}
+			var merged map[string]interface{}
+			if err := value.Decode(&merged); err == nil {
+				for k, v := range merged {
+					inherited[k] = v
+				}
+			}
+			content = append(content, key, value)
+			continue
+		}
+
+		newValue, ok := data[key.Value]
+		if !ok || seen[key.Value] {
+			continue
+		}
+		seen[key.Value] = true
+		merged, err := m.merge(value, newValue)
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, key, merged)
+	}
+
+	var added []string
+	for key, value := range data {
+		if seen[key] {
+			continue
+		}
+		if base, ok := inherited[key]; ok && yamlEqual(base, value) {
+			continue
+		}
+		added = append(added, key)
+	}
+	sort.Strings(added)
+	for _, key := range added {
+		value, err := newYAMLNode(data[key])
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+	}
+
+	n.Content = content
+	return n, nil
+}
+
+// mergeSequence updates the elements of a sequence node by index.
+func (m *yamlMerger) mergeSequence(n *yaml.Node, list []interface{}) (*yaml.Node, error) {
+	if len(n.Content) > len(list) {
+		n.Content = n.Content[:len(list)]
+	}
+	for i, elem := range n.Content {
+		merged, err := m.merge(elem, list[i])
+		if err != nil {
+			return nil, err
+		}
+		n.Content[i] = merged
+	}
+	for _, value := range list[len(n.Content):] {
+		elem, err := newYAMLNode(value)
+		if err != nil {
+			return nil, err
+		}
+		n.Content = append(n.Content, elem)
+	}
+	return n, nil
+}
+
+// replaceYAMLNode builds a node for v that takes over the comments, anchor
+// and (for strings) quoting style of the node it replaces.
+func replaceYAMLNode(old *yaml.Node, v interface{}) (*yaml.Node, error) {
+	n, err := newYAMLNode(v)
+	if err != nil {
+		return nil, err
+	}
+	n.HeadComment = old.HeadComment
+	n.LineComment = old.LineComment
+	n.FootComment = old.FootComment
+	if old.Kind != yaml.AliasNode {
+		n.Anchor = old.Anchor
+	}
+	if old.Kind == yaml.ScalarNode && n.Kind == yaml.ScalarNode && old.Tag == "!!str" && n.Tag == "!!str" {
+		n.Style = old.Style
+		if n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 && !strings.Contains(n.Value, "\n") {
+			n.Style = 0
+		}
+	}
+	return n, nil
+}
+
+// newYAMLNode encodes v as a fresh node.
+func newYAMLNode(v interface{}) (*yaml.Node, error) {
+	n := &yaml.Node{}
+	if err := n.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode YAML value: %w", err)
+	}
+	return n, nil
+}
+
+// yamlEqual reports whether a and b have the same YAML representation, which
+// treats numerically equal ints and floats as equal.
+func yamlEqual(a, b interface{}) bool {
+	ay, err := yaml.Marshal(a)
+	if err != nil {
+		return false
+	}
+	by, err := yaml.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ay, by)
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestYAMLFormatGood(t *testing.T) {
+	t.Run("Save preserves comments, order, anchors and quoting", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.yaml")
+		src := `# Application settings
+name: "demo" # display name
+defaults: &defaults
+  timeout: 30
+  retries: 3
+# Services are listed in priority order.
+services:
+  web:
+    <<: *defaults
+    port: 8080
+  worker:
+    settings: *defaults
+tags:
+  - 'alpha'
+  - beta
+removed: true
+`
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		f := &YAMLFormat{}
+		data, err := f.Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		data["name"] = "renamed"
+		delete(data, "removed")
+		data["services"].(map[string]interface{})["web"].(map[string]interface{})["port"] = int64(9090)
+		data["tags"] = []interface{}{"alpha", "gamma"}
+		data["added"] = int64(1)
+
+		if err := f.Save(path, data); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+
+		expected := `# Application settings
+name: "renamed" # display name
+defaults: &defaults
+  timeout: 30
+  retries: 3
+# Services are listed in priority order.
+services:
+  web:
+    <<: *defaults
+    port: 9090
+  worker:
+    settings: *defaults
+tags:
+  - 'alpha'
+  - gamma
+added: 1
+`
+		if string(raw) != expected {
+			t.Errorf("Unexpected output.\nExpected:\n%s\nGot:\n%s", expected, raw)
+		}
+
+		reloaded, err := f.Load(path)
+		if err != nil {
+			t.Fatalf("Load() after Save() failed: %v", err)
+		}
+		if !reflect.DeepEqual(data, reloaded) {
+			t.Errorf("Reloaded data does not match.\nExpected: %v\nGot: %v", data, reloaded)
+		}
+	})
+
+	t.Run("Changing an anchor updates its aliases", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.yaml")
+		src := "base: &base 1\ncopy: *base\n"
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		f := &YAMLFormat{}
+		if err := f.Save(path, map[string]interface{}{"base": 2, "copy": 1}); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		got, err := f.Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		expected := map[string]interface{}{"base": int64(2), "copy": int64(1)}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Unexpected result.\nExpected: %v\nGot: %v", expected, got)
+		}
+	})
+}
+
+func TestYAMLFormatUgly(t *testing.T) {
+	t.Run("Save over an unparsable file rewrites it", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.yaml")
+		if err := os.WriteFile(path, []byte("key: [unclosed\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		f := &YAMLFormat{}
+		if err := f.Save(path, map[string]interface{}{"key": "value"}); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		got, err := f.Load(path)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		if got["key"] != "value" {
+			t.Errorf("Expected key to be 'value', got %v", got["key"])
+		}
+	})
+}
@@ -0,0 +1,526 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Snider/config/pkg/config"
+)
+
+// maxRequestBody limits the size of request bodies of the config API.
+const maxRequestBody = 1 << 20
+
+// Error codes of the config API, reported in the code field of error
+// responses.
+const (
+	// CodeInvalidBody marks a request body that is not the expected JSON.
+	CodeInvalidBody = "invalid_body"
+	// CodeUnknownKey marks a key that names no configuration value.
+	CodeUnknownKey = "unknown_key"
+	// CodeInvalidValue marks a value of the wrong type or outside its
+	// allowed range.
+	CodeInvalidValue = "invalid_value"
+	// CodeInvalidName marks a file name that is not a plain file name in
+	// the config directory.
+	CodeInvalidName = "invalid_name"
+	// CodeUnsupportedFormat marks a file name whose extension is not a
+	// registered config format.
+	CodeUnsupportedFormat = "unsupported_format"
+	// CodeReadOnly marks a key that cannot be changed through the API,
+	// such as configDir: the keys locating files would otherwise let a
+	// client write files anywhere.
+	CodeReadOnly = "read_only"
+	// CodeFileNotFound marks an auxiliary file that does not exist.
+	CodeFileNotFound = "file_not_found"
+	// CodePreconditionRequired marks a write without an If-Match header.
+	CodePreconditionRequired = "precondition_required"
+	// CodeRevisionMismatch marks a write whose If-Match header does not
+	// match the current revision, because someone else changed the
+	// resource since it was read.
+	CodeRevisionMismatch = "revision_mismatch"
+	// CodeInternal marks a failure of the server, such as a file that
+	// cannot be written.
+	CodeInternal = "internal_error"
+)
+
+// APIError is the body of an error response of the config API.
+type APIError struct {
+	// Code identifies the kind of error; see the Code constants.
+	Code string `json:"code"`
+	// Message describes the error.
+	Message string `json:"message"`
+	// Errors lists the problems found in an invalid value.
+	Errors config.ValidationErrors `json:"errors,omitempty"`
+}
+
+// Error returns the message of the error.
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// status returns the HTTP status code of the error.
+func (e *APIError) status() int {
+	switch e.Code {
+	case CodeUnknownKey, CodeFileNotFound:
+		return http.StatusNotFound
+	case CodeInvalidValue:
+		return http.StatusUnprocessableEntity
+	case CodeReadOnly:
+		return http.StatusForbidden
+	case CodePreconditionRequired:
+		return http.StatusPreconditionRequired
+	case CodeRevisionMismatch:
+		return http.StatusPreconditionFailed
+	case CodeInternal:
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+// valueBody is the request body of PUT /api/v1/config/{key}.
+type valueBody struct {
+	Value *json.RawMessage `json:"value"`
+}
+
+// configAPI serves a config.Service as JSON. The service is not safe for
+// concurrent use, so requests are handled one at a time.
+type configAPI struct {
+	mu  sync.Mutex
+	cfg *config.Service
+	mux *http.ServeMux
+}
+
+// NewConfigHandler returns the handler of the config API, which serves cfg
+// as JSON under /api/v1/config:
+//
+//	GET    /api/v1/config               every configuration value by key
+//	PUT    /api/v1/config               replace the whole configuration
+//	PATCH  /api/v1/config               set the values of the keys given
+//	GET    /api/v1/config/{key}         a value as {"key", "value", "source"}
+//	PUT    /api/v1/config/{key}         set a value from {"value": ...}
+//	DELETE /api/v1/config/{key}         restore the default of a value
+//	GET    /api/v1/config/files/{name}  an auxiliary file as a JSON object
+//	PUT    /api/v1/config/files/{name}  replace an auxiliary file
+//
+// Auxiliary files are read and written with LoadKeyValues and SaveKeyValues,
+// in the format of their extension. The keys locating files, for which
+// config.IsPathKey is true, are read-only: writes changing them fail with
+// 403, and PUT of the whole configuration keeps them.
+//
+// Every response carries the revision of its resource as an ETag. PUT and
+// PATCH require an If-Match header with the ETag the client last read, or
+// "*", and fail with 412 if the resource was changed since; DELETE checks
+// If-Match if it is given. A file that does not exist yet is created with
+// If-None-Match: * instead.
+//
+// Errors are answered with an APIError: 404 for unknown keys and missing
+// files, 422 for invalid values, 428 without If-Match, 412 for a stale
+// revision and 400 for malformed requests.
+func NewConfigHandler(cfg *config.Service) http.Handler {
+	api := &configAPI{cfg: cfg, mux: http.NewServeMux()}
+	api.mux.HandleFunc("GET /api/v1/config", api.getValues)
+	api.mux.HandleFunc("PUT /api/v1/config", api.putValues)
+	api.mux.HandleFunc("PATCH /api/v1/config", api.patchValues)
+	api.mux.HandleFunc("GET /api/v1/config/{key}", api.getValue)
+	api.mux.HandleFunc("PUT /api/v1/config/{key}", api.putValue)
+	api.mux.HandleFunc("DELETE /api/v1/config/{key}", api.deleteValue)
+	api.mux.HandleFunc("GET /api/v1/config/files/{name}", api.getFile)
+	api.mux.HandleFunc("PUT /api/v1/config/files/{name}", api.putFile)
+	return api
+}
+
+func (api *configAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.mux.ServeHTTP(w, r)
+}
+
+func (api *configAPI) getValues(w http.ResponseWriter, r *http.Request) {
+	writeTagged(w, r, api.cfg.Revision(), api.cfg.Values())
+}
+
+func (api *configAPI) putValues(w http.ResponseWriter, r *http.Request) {
+	data, ok := api.readDocument(w, r)
+	if !ok {
+		return
+	}
+	if err := api.keepPaths(data, true); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := api.cfg.Apply(data); err != nil {
+		writeError(w, err)
+		return
+	}
+	api.getValues(w, r)
+}
+
+func (api *configAPI) patchValues(w http.ResponseWriter, r *http.Request) {
+	patch, ok := api.readDocument(w, r)
+	if !ok {
+		return
+	}
+	if err := api.keepPaths(patch, false); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := api.cfg.Update(patch); err != nil {
+		writeError(w, err)
+		return
+	}
+	api.getValues(w, r)
+}
+
+// keepPaths reports an APIError if data changes a key locating files, and
+// otherwise removes those keys from data. For PUT, which resets keys left
+// out, keys set in config.json are added back with their current values.
+func (api *configAPI) keepPaths(data map[string]interface{}, replace bool) error {
+	for key, value := range data {
+		if !config.IsPathKey(key) {
+			continue
+		}
+		setting, _ := api.cfg.Lookup(key)
+		if value != setting.Value {
+			return readOnlyError(setting.Key)
+		}
+		delete(data, key)
+	}
+	if !replace {
+		return nil
+	}
+	for _, key := range api.cfg.Keys() {
+		if setting, _ := api.cfg.Lookup(key); config.IsPathKey(key) && setting.Source == config.SourceFile {
+			data[key] = setting.Value
+		}
+	}
+	return nil
+}
+
+// readDocument checks the If-Match header of a write to the whole
+// configuration and reads its JSON object body. It reports false after
+// answering with an error.
+func (api *configAPI) readDocument(w http.ResponseWriter, r *http.Request) (map[string]interface{}, bool) {
+	current := api.cfg.Revision()
+	expected, err := precondition(r, current)
+	if err == nil && expected != current {
+		err = config.RevisionError{Expected: expected, Actual: current}
+	}
+	if err != nil {
+		writeError(w, err)
+		return nil, false
+	}
+	data, err := readObject(w, r)
+	if err != nil {
+		writeError(w, err)
+		return nil, false
+	}
+	return data, true
+}
+
+func (api *configAPI) getValue(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	setting, err := api.cfg.Lookup(key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	rev, _ := api.cfg.KeyRevision(key)
+	writeTagged(w, r, rev, setting)
+}
+
+func (api *configAPI) putValue(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	current, err := api.cfg.KeyRevision(key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if config.IsPathKey(key) {
+		writeError(w, readOnlyError(key))
+		return
+	}
+	expected, err := precondition(r, current)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var body valueBody
+	if err := readJSON(w, r, &body); err != nil {
+		writeError(w, err)
+		return
+	}
+	if body.Value == nil {
+		writeError(w, requestError(`the body has no "value"`))
+		return
+	}
+	value, err := decodeJSON(*body.Value)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := api.cfg.CompareAndSet(key, expected, value); err != nil {
+		writeError(w, err)
+		return
+	}
+	api.getValue(w, r)
+}
+
+func (api *configAPI) deleteValue(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	current, err := api.cfg.KeyRevision(key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if config.IsPathKey(key) {
+		writeError(w, readOnlyError(key))
+		return
+	}
+	if r.Header.Get("If-Match") != "" {
+		expected, _ := precondition(r, current)
+		if expected != current {
+			setting, _ := api.cfg.Lookup(key)
+			writeError(w, config.RevisionError{Key: setting.Key, Expected: expected, Actual: current})
+			return
+		}
+	}
+	if err := api.cfg.Unset(key); err != nil {
+		writeError(w, err)
+		return
+	}
+	api.getValue(w, r)
+}
+
+func (api *configAPI) getFile(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := api.checkFileName(name); err != nil {
+		writeError(w, err)
+		return
+	}
+	rev, err := api.cfg.FileRevision(name)
+	if err != nil {
+		writeError(w, fileError(name, err))
+		return
+	}
+	data, err := api.cfg.LoadKeyValues(name)
+	if err != nil {
+		writeError(w, fileError(name, err))
+		return
+	}
+	writeTagged(w, r, rev, data)
+}
+
+func (api *configAPI) putFile(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := api.checkFileName(name); err != nil {
+		writeError(w, err)
+		return
+	}
+	current, err := api.cfg.FileRevision(name)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		writeError(w, err)
+		return
+	}
+	if r.Header.Get("If-None-Match") == "*" {
+		if exists {
+			writeError(w, &APIError{Code: CodeRevisionMismatch, Message: fmt.Sprintf("file %s already exists", name)})
+			return
+		}
+	} else {
+		expected, err := precondition(r, current)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if !exists {
+			writeError(w, &APIError{Code: CodeRevisionMismatch, Message: fmt.Sprintf("file %s does not exist; create it with If-None-Match: *", name)})
+			return
+		}
+		if expected != current {
+			writeError(w, config.RevisionError{Expected: expected, Actual: current})
+			return
+		}
+	}
+	data, err := readObject(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := api.cfg.SaveKeyValues(name, data); err != nil {
+		writeError(w, err)
+		return
+	}
+	api.getFile(w, r)
+}
+
+// checkFileName reports an APIError unless name is a plain file name in
+// the config directory with the extension of a registered format. The main
+// config file is served by the key endpoints only.
+func (api *configAPI) checkFileName(name string) error {
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return &APIError{Code: CodeInvalidName, Message: fmt.Sprintf("invalid file name %q", name)}
+	}
+	if filepath.Join(api.cfg.ConfigDir, name) == api.cfg.ConfigPath {
+		return &APIError{Code: CodeInvalidName, Message: fmt.Sprintf("%s is served under /api/v1/config", name)}
+	}
+	if _, err := config.GetConfigFormat(name); err != nil {
+		return &APIError{Code: CodeUnsupportedFormat, Message: err.Error()}
+	}
+	return nil
+}
+
+// fileError returns the APIError of a file that cannot be read.
+func fileError(name string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return &APIError{Code: CodeFileNotFound, Message: fmt.Sprintf("file %s not found", name)}
+	}
+	return err
+}
+
+// precondition returns the revision that the If-Match header of a write
+// expects: current if the header lists it or is "*", or else the first
+// revision it lists. Weak entity tags never match. It reports an APIError
+// if the header is missing.
+func precondition(r *http.Request, current string) (string, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return "", &APIError{Code: CodePreconditionRequired, Message: "the If-Match header is required; read the resource for its ETag first"}
+	}
+	first := ""
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return current, nil
+		}
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		rev := strings.Trim(tag, `"`)
+		if rev == current {
+			return current, nil
+		}
+		if first == "" {
+			first = rev
+		}
+	}
+	if first == "" {
+		first = header
+	}
+	return first, nil
+}
+
+// readOnlyError returns the APIError of a write to a key locating files.
+func readOnlyError(key string) *APIError {
+	return &APIError{Code: CodeReadOnly, Message: fmt.Sprintf("key '%s' locates files and cannot be changed through the API", key)}
+}
+
+// requestError returns the APIError of a malformed request body.
+func requestError(message string) *APIError {
+	return &APIError{Code: CodeInvalidBody, Message: message}
+}
+
+// readJSON decodes the JSON request body into v.
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	if err := dec.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return requestError("the body is empty")
+		}
+		return requestError("invalid JSON: " + err.Error())
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return requestError("invalid JSON: unexpected data after the value")
+	}
+	return nil
+}
+
+// readObject reads a JSON object request body in the canonical value model.
+func readObject(w http.ResponseWriter, r *http.Request) (map[string]interface{}, error) {
+	var raw json.RawMessage
+	if err := readJSON(w, r, &raw); err != nil {
+		return nil, err
+	}
+	value, err := decodeJSON(raw)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, requestError("the body must be a JSON object")
+	}
+	return data, nil
+}
+
+// decodeJSON decodes a JSON value to the canonical value model, keeping
+// integers as int64.
+func decodeJSON(raw json.RawMessage) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, requestError("invalid JSON: " + err.Error())
+	}
+	canonical, err := config.Canonicalize(v)
+	if err != nil {
+		return nil, requestError(err.Error())
+	}
+	return canonical, nil
+}
+
+// writeJSON writes v as the JSON body of a response with status code.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeTagged writes v with the ETag of revision rev, or answers a GET
+// request whose If-None-Match header has that ETag with 304 Not Modified.
+func writeTagged(w http.ResponseWriter, r *http.Request, rev string, v interface{}) {
+	etag := strconv.Quote(rev)
+	w.Header().Set("ETag", etag)
+	if r.Method == http.MethodGet {
+		for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+			if tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/"); tag == etag || tag == "*" {
+				w.Header().Set("Cache-Control", "no-cache")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// writeError writes err as an APIError response, choosing the status code
+// by the kind of error.
+func writeError(w http.ResponseWriter, err error) {
+	var (
+		apiErr *APIError
+		keyErr config.KeyError
+		revErr config.RevisionError
+		verrs  config.ValidationErrors
+	)
+	switch {
+	case errors.As(err, &apiErr):
+	case errors.As(err, &keyErr):
+		apiErr = &APIError{Code: CodeUnknownKey, Message: keyErr.Error()}
+	case errors.As(err, &revErr):
+		apiErr = &APIError{Code: CodeRevisionMismatch, Message: revErr.Error()}
+		w.Header().Set("ETag", strconv.Quote(revErr.Actual))
+	case errors.As(err, &verrs):
+		apiErr = &APIError{Code: CodeInvalidValue, Message: verrs.Error(), Errors: verrs}
+	default:
+		apiErr = &APIError{Code: CodeInternal, Message: err.Error()}
+	}
+	writeJSON(w, apiErr.status(), apiErr)
+}
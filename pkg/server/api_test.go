@@ -0,0 +1,337 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Snider/config/pkg/config"
+)
+
+// newTestConfig returns a config service whose files are in a temporary
+// home directory.
+func newTestConfig(t *testing.T) *config.Service {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	cfg, err := config.New()
+	if err != nil {
+		t.Fatalf("config.New() failed: %v", err)
+	}
+	return cfg
+}
+
+// anyRevision is the header of a write that does not check the revision.
+var anyRevision = map[string]string{"If-Match": "*"}
+
+// callAPI sends a request with header and a JSON body, if body is not
+// empty, and decodes the JSON response into out, if out is not nil.
+func callAPI(t *testing.T, h http.Handler, method, path, body string, header map[string]string, out interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if ct := rec.Header().Get("Content-Type"); rec.Code != http.StatusNotModified && ct != "application/json" {
+		t.Errorf("%s %s: expected a JSON response, got %q", method, path, ct)
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: invalid JSON response %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec
+}
+
+func TestConfigAPIGood(t *testing.T) {
+	cfg := newTestConfig(t)
+	opts := DefaultOptions()
+	opts.Config = cfg
+	h := NewHandler(opts)
+
+	t.Run("Get all values", func(t *testing.T) {
+		var values map[string]interface{}
+		if rec := callAPI(t, h, http.MethodGet, "/api/v1/config", "", nil, &values); rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		if len(values) != len(cfg.Keys()) || values["language"] != "en" {
+			t.Errorf("Unexpected values: %v", values)
+		}
+	})
+
+	t.Run("Get, set and delete a value", func(t *testing.T) {
+		var setting config.Setting
+		rec := callAPI(t, h, http.MethodGet, "/api/v1/config/language", "", nil, &setting)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET: expected 200, got %d", rec.Code)
+		}
+		if setting != (config.Setting{Key: "language", Value: "en", Source: config.SourceDefault}) {
+			t.Errorf("GET: unexpected setting %+v", setting)
+		}
+		etag := rec.Header().Get("ETag")
+
+		rec = callAPI(t, h, http.MethodPut, "/api/v1/config/Language", `{"value": "fr"}`, map[string]string{"If-Match": etag}, &setting)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("PUT: expected 200, got %d %s", rec.Code, rec.Body.String())
+		}
+		if newTag := rec.Header().Get("ETag"); newTag == "" || newTag == etag {
+			t.Errorf("PUT: expected a new ETag, got %q", newTag)
+		}
+		if setting != (config.Setting{Key: "language", Value: "fr", Source: config.SourceFile}) {
+			t.Errorf("PUT: unexpected setting %+v", setting)
+		}
+		if cfg.Language != "fr" {
+			t.Errorf("PUT: expected the service to be updated, got %q", cfg.Language)
+		}
+
+		if rec := callAPI(t, h, http.MethodPut, "/api/v1/config/features", `{"value": ["beta", "metrics"]}`, anyRevision, nil); rec.Code != http.StatusOK {
+			t.Fatalf("PUT: expected 200, got %d %s", rec.Code, rec.Body.String())
+		}
+		if expected := []string{"beta", "metrics"}; !reflect.DeepEqual(expected, cfg.Features) {
+			t.Errorf("PUT: expected features %v, got %v", expected, cfg.Features)
+		}
+
+		if rec := callAPI(t, h, http.MethodDelete, "/api/v1/config/language", "", nil, &setting); rec.Code != http.StatusOK {
+			t.Fatalf("DELETE: expected 200, got %d", rec.Code)
+		}
+		if setting.Value != "en" || setting.Source != config.SourceDefault {
+			t.Errorf("DELETE: expected the default, got %+v", setting)
+		}
+	})
+
+	t.Run("Get and put a file", func(t *testing.T) {
+		if rec := callAPI(t, h, http.MethodPut, "/api/v1/config/files/database.yaml", `{"host": "localhost", "port": 5432}`, map[string]string{"If-None-Match": "*"}, nil); rec.Code != http.StatusOK {
+			t.Fatalf("PUT: expected 200, got %d %s", rec.Code, rec.Body.String())
+		}
+		raw, err := os.ReadFile(filepath.Join(cfg.ConfigDir, "database.yaml"))
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		if !strings.Contains(string(raw), "port: 5432\n") {
+			t.Errorf("Expected the port to be saved as an integer, got:\n%s", raw)
+		}
+
+		var data map[string]interface{}
+		rec := callAPI(t, h, http.MethodGet, "/api/v1/config/files/database.yaml", "", nil, &data)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET: expected 200, got %d", rec.Code)
+		}
+		expected := map[string]interface{}{"host": "localhost", "port": float64(5432)}
+		if !reflect.DeepEqual(expected, data) {
+			t.Errorf("GET: expected %v, got %v", expected, data)
+		}
+		etag := rec.Header().Get("ETag")
+		if etag != strconv.Quote(config.ContentRevision(raw)) {
+			t.Errorf("GET: expected the ETag of the file content, got %q", etag)
+		}
+
+		rec = callAPI(t, h, http.MethodPut, "/api/v1/config/files/database.yaml", `{"host": "db"}`, map[string]string{"If-Match": etag}, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("PUT: expected 200, got %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Replace and patch the whole configuration", func(t *testing.T) {
+		rec := callAPI(t, h, http.MethodGet, "/api/v1/config", "", nil, nil)
+		etag := rec.Header().Get("ETag")
+		if etag != strconv.Quote(cfg.Revision()) {
+			t.Fatalf("Expected the ETag of the revision, got %q", etag)
+		}
+
+		var values map[string]interface{}
+		rec = callAPI(t, h, http.MethodPatch, "/api/v1/config", `{"Language": "de"}`, map[string]string{"If-Match": etag}, &values)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("PATCH: expected 200, got %d %s", rec.Code, rec.Body.String())
+		}
+		if values["language"] != "de" || len(cfg.Features) != 2 {
+			t.Errorf("PATCH: expected only the language to change, got %v", values)
+		}
+		for key, source := range map[string]string{"language": config.SourceFile, "features": config.SourceFile, "default_route": config.SourceDefault, "configDir": config.SourceDefault} {
+			if setting, _ := cfg.Lookup(key); setting.Source != source {
+				t.Errorf("PATCH: expected %s to come from %s, got %+v", key, source, setting)
+			}
+		}
+
+		rec = callAPI(t, h, http.MethodPatch, "/api/v1/config", `{"features": []}`, map[string]string{"If-Match": rec.Header().Get("ETag")}, &values)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("PATCH: expected 200, got %d %s", rec.Code, rec.Body.String())
+		}
+		if cfg.Language != "de" || len(cfg.Features) != 0 || cfg.DefaultRoute != "/" {
+			t.Errorf("PATCH: unexpected configuration %q %v %q", cfg.Language, cfg.Features, cfg.DefaultRoute)
+		}
+		etag = rec.Header().Get("ETag")
+
+		configDir := cfg.ConfigDir
+		body := fmt.Sprintf(`{"language": "it", "configDir": %q}`, configDir)
+		rec = callAPI(t, h, http.MethodPut, "/api/v1/config", body, map[string]string{"If-Match": etag}, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("PUT: expected 200, got %d %s", rec.Code, rec.Body.String())
+		}
+		if cfg.Language != "it" || cfg.ConfigDir != configDir || cfg.DataDir == "" {
+			t.Errorf("PUT: expected the paths to be kept, got %q %q %q", cfg.Language, cfg.ConfigDir, cfg.DataDir)
+		}
+	})
+
+	t.Run("Unchanged resources are not sent again", func(t *testing.T) {
+		etag := callAPI(t, h, http.MethodGet, "/api/v1/config/language", "", nil, nil).Header().Get("ETag")
+		rec := callAPI(t, h, http.MethodGet, "/api/v1/config/language", "", map[string]string{"If-None-Match": etag}, nil)
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Errorf("Expected 304 without a body, got %d %q", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func TestConfigAPIBad(t *testing.T) {
+	cfg := newTestConfig(t)
+	if err := cfg.SaveKeyValues("db.json", map[string]interface{}{"port": 0}); err != nil {
+		t.Fatalf("SaveKeyValues() failed: %v", err)
+	}
+	h := NewConfigHandler(cfg)
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"Get an unknown key", http.MethodGet, "/api/v1/config/missing", "", http.StatusNotFound, CodeUnknownKey},
+		{"Set an unknown key", http.MethodPut, "/api/v1/config/missing", `{"value": 1}`, http.StatusNotFound, CodeUnknownKey},
+		{"Delete an unknown key", http.MethodDelete, "/api/v1/config/missing", "", http.StatusNotFound, CodeUnknownKey},
+		{"Type mismatch", http.MethodPut, "/api/v1/config/features", `{"value": {"a": 1}}`, http.StatusUnprocessableEntity, CodeInvalidValue},
+		{"Invalid value", http.MethodPut, "/api/v1/config/default_route", `{"value": "home"}`, http.StatusUnprocessableEntity, CodeInvalidValue},
+		{"Malformed JSON", http.MethodPut, "/api/v1/config/language", `{"value": `, http.StatusBadRequest, CodeInvalidBody},
+		{"Trailing data", http.MethodPut, "/api/v1/config/language", `{"value": "de"} {}`, http.StatusBadRequest, CodeInvalidBody},
+		{"Missing value", http.MethodPut, "/api/v1/config/language", `{"language": "de"}`, http.StatusBadRequest, CodeInvalidBody},
+		{"Empty body", http.MethodPut, "/api/v1/config/language", "", http.StatusBadRequest, CodeInvalidBody},
+		{"Missing file", http.MethodGet, "/api/v1/config/files/missing.yaml", "", http.StatusNotFound, CodeFileNotFound},
+		{"Hidden file", http.MethodGet, "/api/v1/config/files/.secret.json", "", http.StatusBadRequest, CodeInvalidName},
+		{"Main config file", http.MethodGet, "/api/v1/config/files/config.json", "", http.StatusBadRequest, CodeInvalidName},
+		{"Unknown format", http.MethodPut, "/api/v1/config/files/notes.docx", `{}`, http.StatusBadRequest, CodeUnsupportedFormat},
+		{"File is not an object", http.MethodPut, "/api/v1/config/files/db.json", `[1, 2]`, http.StatusBadRequest, CodeInvalidBody},
+		{"Unknown key in the configuration", http.MethodPatch, "/api/v1/config", `{"missing": 1}`, http.StatusUnprocessableEntity, CodeInvalidValue},
+		{"File created with If-Match", http.MethodPut, "/api/v1/config/files/new.json", `{}`, http.StatusPreconditionFailed, CodeRevisionMismatch},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var apiErr APIError
+			var header map[string]string
+			if tc.method != http.MethodGet {
+				header = anyRevision
+			}
+			rec := callAPI(t, h, tc.method, tc.path, tc.body, header, &apiErr)
+			if rec.Code != tc.status || apiErr.Code != tc.code {
+				t.Errorf("Expected %d %s, got %d %s", tc.status, tc.code, rec.Code, rec.Body.String())
+			}
+			if apiErr.Message == "" {
+				t.Error("Expected an error message")
+			}
+		})
+	}
+
+	t.Run("Writes need the current revision", func(t *testing.T) {
+		stale := callAPI(t, h, http.MethodGet, "/api/v1/config/language", "", nil, nil).Header().Get("ETag")
+		staleDoc := callAPI(t, h, http.MethodGet, "/api/v1/config", "", nil, nil).Header().Get("ETag")
+		staleFile := callAPI(t, h, http.MethodGet, "/api/v1/config/files/db.json", "", nil, nil).Header().Get("ETag")
+		// Another tab changes the configuration and the file.
+		if err := cfg.SetValue("language", "fr"); err != nil {
+			t.Fatalf("SetValue() failed: %v", err)
+		}
+		if err := cfg.SaveKeyValues("db.json", map[string]interface{}{"port": 1}); err != nil {
+			t.Fatalf("SaveKeyValues() failed: %v", err)
+		}
+		current, _ := cfg.KeyRevision("language")
+
+		writes := []struct {
+			name   string
+			method string
+			path   string
+			body   string
+			header map[string]string
+			status int
+			code   string
+		}{
+			{"Value without If-Match", http.MethodPut, "/api/v1/config/language", `{"value": "de"}`, nil, http.StatusPreconditionRequired, CodePreconditionRequired},
+			{"Stale value", http.MethodPut, "/api/v1/config/language", `{"value": "de"}`, map[string]string{"If-Match": stale}, http.StatusPreconditionFailed, CodeRevisionMismatch},
+			{"Weak ETag", http.MethodPut, "/api/v1/config/language", `{"value": "de"}`, map[string]string{"If-Match": "W/" + strconv.Quote(current)}, http.StatusPreconditionFailed, CodeRevisionMismatch},
+			{"Stale delete", http.MethodDelete, "/api/v1/config/language", "", map[string]string{"If-Match": stale}, http.StatusPreconditionFailed, CodeRevisionMismatch},
+			{"Stale document", http.MethodPatch, "/api/v1/config", `{"language": "de"}`, map[string]string{"If-Match": staleDoc}, http.StatusPreconditionFailed, CodeRevisionMismatch},
+			{"Document without If-Match", http.MethodPut, "/api/v1/config", `{}`, nil, http.StatusPreconditionRequired, CodePreconditionRequired},
+			{"Stale file", http.MethodPut, "/api/v1/config/files/db.json", `{}`, map[string]string{"If-Match": staleFile}, http.StatusPreconditionFailed, CodeRevisionMismatch},
+			{"File without If-Match", http.MethodPut, "/api/v1/config/files/db.json", `{}`, nil, http.StatusPreconditionRequired, CodePreconditionRequired},
+			{"Existing file created", http.MethodPut, "/api/v1/config/files/db.json", `{}`, map[string]string{"If-None-Match": "*"}, http.StatusPreconditionFailed, CodeRevisionMismatch},
+		}
+		for _, tc := range writes {
+			var apiErr APIError
+			rec := callAPI(t, h, tc.method, tc.path, tc.body, tc.header, &apiErr)
+			if rec.Code != tc.status || apiErr.Code != tc.code {
+				t.Errorf("%s: expected %d %s, got %d %s", tc.name, tc.status, tc.code, rec.Code, rec.Body.String())
+			}
+		}
+		if cfg.Language != "fr" {
+			t.Errorf("Expected the other change to be kept, got %q", cfg.Language)
+		}
+		rec := callAPI(t, h, http.MethodPut, "/api/v1/config/language", `{"value": "de"}`, map[string]string{"If-Match": stale}, nil)
+		if got := rec.Header().Get("ETag"); got != strconv.Quote(current) {
+			t.Errorf("Expected the current ETag with a conflict, got %q", got)
+		}
+		if rec := callAPI(t, h, http.MethodPut, "/api/v1/config/language", `{"value": "de"}`, map[string]string{"If-Match": stale + ", " + strconv.Quote(current)}, nil); rec.Code != http.StatusOK {
+			t.Errorf("Expected a write with the current ETag in a list to succeed, got %d", rec.Code)
+		}
+	})
+
+	t.Run("Keys locating files are read-only", func(t *testing.T) {
+		configDir := cfg.ConfigDir
+		target := t.TempDir()
+		writes := []struct {
+			method string
+			path   string
+			body   string
+		}{
+			{http.MethodPut, "/api/v1/config/configDir", fmt.Sprintf(`{"value": %q}`, target)},
+			{http.MethodPut, "/api/v1/config/ConfigPath", fmt.Sprintf(`{"value": %q}`, filepath.Join(target, "c.json"))},
+			{http.MethodDelete, "/api/v1/config/dataDir", ""},
+			{http.MethodPatch, "/api/v1/config", fmt.Sprintf(`{"configDir": %q}`, target)},
+			{http.MethodPut, "/api/v1/config", fmt.Sprintf(`{"language": "en", "configDir": %q}`, target)},
+		}
+		for _, tc := range writes {
+			var apiErr APIError
+			rec := callAPI(t, h, tc.method, tc.path, tc.body, anyRevision, &apiErr)
+			if rec.Code != http.StatusForbidden || apiErr.Code != CodeReadOnly {
+				t.Errorf("%s %s: expected 403 %s, got %d %s", tc.method, tc.path, CodeReadOnly, rec.Code, rec.Body.String())
+			}
+		}
+		if cfg.ConfigDir != configDir {
+			t.Errorf("Expected ConfigDir to stay %q, got %q", configDir, cfg.ConfigDir)
+		}
+		if rec := callAPI(t, h, http.MethodPut, "/api/v1/config/files/evil.json", `{}`, map[string]string{"If-None-Match": "*"}, nil); rec.Code != http.StatusOK {
+			t.Fatalf("PUT: expected 200, got %d", rec.Code)
+		}
+		if _, err := os.Stat(filepath.Join(target, "evil.json")); !os.IsNotExist(err) {
+			t.Errorf("Expected no file to be written outside the config directory, got %v", err)
+		}
+	})
+
+	t.Run("Validation errors name the key", func(t *testing.T) {
+		var apiErr APIError
+		callAPI(t, h, http.MethodPut, "/api/v1/config/default_route", `{"value": "home"}`, anyRevision, &apiErr)
+		if len(apiErr.Errors) != 1 || apiErr.Errors[0].Key != "default_route" {
+			t.Errorf("Unexpected errors: %+v", apiErr.Errors)
+		}
+		if cfg.DefaultRoute != "/" {
+			t.Errorf("Expected the value to be unchanged, got %q", cfg.DefaultRoute)
+		}
+	})
+}
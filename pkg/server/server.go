@@ -0,0 +1,171 @@
+// Package server provides the HTTP server of the demo CLI: an http.Handler
+// serving the frontend and the API, and functions to run it with timeouts,
+// optional TLS and graceful shutdown. The frontend is the build embedded by
+// package ui, or a directory on disk during development.
+//
+// The handler can be tested without a network listener:
+//
+//	rec := httptest.NewRecorder()
+//	server.NewHandler(server.DefaultOptions()).ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/demo", nil))
+//
+// and served until a context is cancelled:
+//
+//	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+//	defer stop()
+//	opts := server.DefaultOptions()
+//	if err := server.ListenAndServe(ctx, opts, server.NewHandler(opts)); err != nil {
+//		log.Fatal(err)
+//	}
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Snider/config/pkg/config"
+	"github.com/Snider/config/ui"
+)
+
+// Options configures the server. The config tags name the keys read from an
+// auxiliary config file with config.Service.LoadStructAs.
+type Options struct {
+	// Addr is the TCP address to listen on, such as "localhost:8080". An
+	// address without a host, such as ":8080", listens on every interface.
+	Addr string `config:"addr"`
+	// StaticDir is a directory to serve the frontend from instead of the
+	// build embedded in the binary, such as DevStaticDir while working on
+	// the frontend. Its files are revalidated on every request.
+	StaticDir string `config:"static_dir"`
+	// TLSCert and TLSKey are the PEM files of the certificate and private
+	// key. TLS is used if both are set.
+	TLSCert string `config:"tls_cert"`
+	TLSKey  string `config:"tls_key"`
+	// ReadTimeout and WriteTimeout limit the time spent reading a request
+	// and writing a response. Zero means no limit.
+	ReadTimeout  time.Duration `config:"read_timeout"`
+	WriteTimeout time.Duration `config:"write_timeout"`
+	// ShutdownTimeout is how long requests in progress may take to finish
+	// once the server is asked to stop.
+	ShutdownTimeout time.Duration `config:"shutdown_timeout"`
+	// Config is the configuration served by the config API under
+	// /api/v1/config. The API is not served if it is nil.
+	Config *config.Service `config:"-"`
+}
+
+// DevStaticDir is the output directory of the frontend build, relative to
+// the root of the repository.
+const DevStaticDir = "./ui/" + ui.BuildDir
+
+// DefaultOptions returns the options used when nothing is configured.
+func DefaultOptions() Options {
+	return Options{
+		Addr:            "localhost:8080",
+		ReadTimeout:     15 * time.Second,
+		WriteTimeout:    15 * time.Second,
+		ShutdownTimeout: 10 * time.Second,
+	}
+}
+
+// TLS reports whether the options enable TLS.
+func (o Options) TLS() bool {
+	return o.TLSCert != "" && o.TLSKey != ""
+}
+
+// Loopback reports whether opts.Addr only accepts connections from the
+// local machine: its host is localhost or a loopback IP address.
+func (o Options) Loopback() bool {
+	host, _, err := net.SplitHostPort(o.Addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Frontend returns the files of the frontend: the directory opts.StaticDir
+// if it is set, or else the embedded build.
+func (o Options) Frontend() fs.FS {
+	if o.StaticDir != "" {
+		return os.DirFS(o.StaticDir)
+	}
+	return ui.FS()
+}
+
+// NewHandler returns the handler serving the API under /api/, including
+// the config API of NewConfigHandler if opts.Config is set, and the frontend
+// at every other path. Paths of the frontend application that are
+// not files are answered with its index.html.
+func NewHandler(opts Options) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/demo", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Hello, world!")
+	})
+	if opts.Config != nil {
+		api := NewConfigHandler(opts.Config)
+		mux.Handle("/api/v1/config", api)
+		mux.Handle("/api/v1/config/", api)
+	}
+	mux.Handle("/api/", http.NotFoundHandler())
+	mux.Handle("/", &staticHandler{fsys: opts.Frontend(), dev: opts.StaticDir != ""})
+	return mux
+}
+
+// ListenAndServe listens on opts.Addr and serves handler as Serve does.
+func ListenAndServe(ctx context.Context, opts Options, handler http.Handler) error {
+	ln, err := net.Listen("tcp", opts.Addr)
+	if err != nil {
+		return err
+	}
+	return Serve(ctx, ln, opts, handler)
+}
+
+// Serve serves handler on ln, with TLS if opts.TLS() is true, until ctx is
+// done. It then stops accepting connections and waits up to
+// opts.ShutdownTimeout for requests in progress to finish. It returns nil
+// after a graceful shutdown.
+func Serve(ctx context.Context, ln net.Listener, opts Options, handler http.Handler) error {
+	srv := &http.Server{
+		Handler:      handler,
+		ReadTimeout:  opts.ReadTimeout,
+		WriteTimeout: opts.WriteTimeout,
+	}
+	if opts.TLS() {
+		cert, err := tls.LoadX509KeyPair(opts.TLSCert, opts.TLSKey)
+		if err != nil {
+			ln.Close()
+			return fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+		ln = tls.NewListener(ln, srv.TLSConfig)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(ln)
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHandlerGood(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<app-root></app-root>"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	opts := DefaultOptions()
+	opts.StaticDir = dir
+	handler := NewHandler(opts)
+
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{"/api/v1/demo", "Hello, world!"},
+		{"/", "<app-root></app-root>"},
+		{"/settings", "<app-root></app-root>"},
+	}
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != tc.expected {
+			t.Errorf("GET %s: expected 200 %q, got %d %q", tc.path, tc.expected, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestHandlerBad(t *testing.T) {
+	opts := DefaultOptions()
+	opts.StaticDir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(opts.StaticDir, "index.html"), []byte("<app-root></app-root>"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	NewHandler(opts).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown API path, got %d", rec.Code)
+	}
+}
+
+func TestOptionsLoopback(t *testing.T) {
+	testCases := []struct {
+		addr     string
+		expected bool
+	}{
+		{"localhost:8080", true},
+		{"127.0.0.1:8080", true},
+		{"[::1]:8080", true},
+		{":8080", false},
+		{"0.0.0.0:8080", false},
+		{"192.168.1.2:8080", false},
+		{"example.com:8080", false},
+		{"localhost", false},
+	}
+	if !DefaultOptions().Loopback() {
+		t.Error("Expected the default address to be loopback")
+	}
+	for _, tc := range testCases {
+		if got := (Options{Addr: tc.addr}).Loopback(); got != tc.expected {
+			t.Errorf("Loopback() for %q = %v, expected %v", tc.addr, got, tc.expected)
+		}
+	}
+}
+
+// startServer runs Serve on a free local port and returns its URL, a function
+// that stops it and the channel receiving Serve's result.
+func startServer(t *testing.T, opts Options, handler http.Handler) (string, context.CancelFunc, chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, ln, opts, handler)
+	}()
+	return ln.Addr().String(), cancel, done
+}
+
+func TestServeGood(t *testing.T) {
+	t.Run("Waits for requests in progress on shutdown", func(t *testing.T) {
+		started := make(chan struct{})
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			io.WriteString(w, "done")
+		})
+		addr, stop, done := startServer(t, DefaultOptions(), handler)
+
+		result := make(chan string, 1)
+		go func() {
+			resp, err := http.Get("http://" + addr)
+			if err != nil {
+				result <- err.Error()
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			result <- string(body)
+		}()
+		<-started
+		stop()
+
+		if got := <-result; got != "done" {
+			t.Errorf("Expected the request to finish, got %q", got)
+		}
+		if err := <-done; err != nil {
+			t.Errorf("Serve() returned %v after a graceful shutdown", err)
+		}
+	})
+
+	t.Run("Serves TLS with a self-signed certificate", func(t *testing.T) {
+		dir := t.TempDir()
+		opts := DefaultOptions()
+		opts.TLSCert = filepath.Join(dir, "tls", "cert.pem")
+		opts.TLSKey = filepath.Join(dir, "tls", "key.pem")
+		generated, err := EnsureSelfSigned(opts.TLSCert, opts.TLSKey, []string{"localhost", "127.0.0.1"})
+		if err != nil || !generated {
+			t.Fatalf("EnsureSelfSigned() = %v, %v", generated, err)
+		}
+		if generated, err := EnsureSelfSigned(opts.TLSCert, opts.TLSKey, nil); err != nil || generated {
+			t.Errorf("Expected the existing certificate to be kept, got %v, %v", generated, err)
+		}
+		if info, err := os.Stat(opts.TLSKey); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("Expected the key to be private, got %v (%v)", info.Mode(), err)
+		}
+
+		addr, stop, done := startServer(t, opts, NewHandler(opts))
+		defer func() {
+			stop()
+			<-done
+		}()
+
+		pemData, err := os.ReadFile(opts.TLSCert)
+		if err != nil {
+			t.Fatalf("Failed to read certificate: %v", err)
+		}
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(pemData)
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+		resp, err := client.Get("https://" + addr + "/api/v1/demo")
+		if err != nil {
+			t.Fatalf("GET over TLS failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != "Hello, world!" {
+			t.Errorf("Unexpected body %q", body)
+		}
+	})
+}
+
+func TestServeBad(t *testing.T) {
+	t.Run("Missing certificate", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.TLSCert = filepath.Join(t.TempDir(), "missing.pem")
+		opts.TLSKey = opts.TLSCert
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Listen() failed: %v", err)
+		}
+		if err := Serve(context.Background(), ln, opts, NewHandler(opts)); err == nil {
+			t.Error("Expected an error for a missing certificate")
+		}
+	})
+
+	t.Run("Certificate without a key", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile := filepath.Join(dir, "cert.pem")
+		keyFile := filepath.Join(dir, "key.pem")
+		if err := os.WriteFile(certFile, []byte("existing"), 0644); err != nil {
+			t.Fatalf("Failed to write certificate: %v", err)
+		}
+		if generated, err := EnsureSelfSigned(certFile, keyFile, nil); err == nil || generated {
+			t.Errorf("Expected an error for a missing key, got %v, %v", generated, err)
+		}
+		if data, err := os.ReadFile(certFile); err != nil || string(data) != "existing" {
+			t.Errorf("Expected the certificate to be left untouched, got %q (%v)", data, err)
+		}
+		if _, err := os.Stat(keyFile); !os.IsNotExist(err) {
+			t.Errorf("Expected no key to be written, got %v", err)
+		}
+	})
+
+	t.Run("Address in use", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Listen() failed: %v", err)
+		}
+		defer ln.Close()
+		opts := DefaultOptions()
+		opts.Addr = ln.Addr().String()
+		if err := ListenAndServe(context.Background(), opts, NewHandler(opts)); err == nil {
+			t.Error("Expected an error for an address in use")
+		}
+	})
+}
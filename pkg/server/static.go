@@ -0,0 +1,219 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache-Control values of the frontend files. Files whose names contain a
+// content hash never change and are cached for a year; everything else is
+// revalidated on every use.
+const (
+	cacheImmutable  = "public, max-age=31536000, immutable"
+	cacheRevalidate = "no-cache"
+)
+
+// hashedName matches file names with a content hash, such as
+// main-5NBNWQFC.js, as written by the Angular build with output hashing.
+var hashedName = regexp.MustCompile(`-[0-9A-Z]{8}\.[a-z0-9]+$`)
+
+// contentTypes are the content types of frontend files that the mime
+// package does not know on every system.
+var contentTypes = map[string]string{
+	".css":         "text/css; charset=utf-8",
+	".html":        "text/html; charset=utf-8",
+	".ico":         "image/x-icon",
+	".js":          "text/javascript; charset=utf-8",
+	".json":        "application/json",
+	".map":         "application/json",
+	".mjs":         "text/javascript; charset=utf-8",
+	".svg":         "image/svg+xml",
+	".txt":         "text/plain; charset=utf-8",
+	".wasm":        "application/wasm",
+	".webmanifest": "application/manifest+json",
+	".woff":        "font/woff",
+	".woff2":       "font/woff2",
+}
+
+// encodings are the precompressed variants looked for next to a file, in
+// order of preference, with their file extensions.
+var encodings = []struct {
+	name, ext string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// staticHandler serves a single-page application from fsys. Paths without a
+// file extension that match no file are routed by the application, so they
+// are answered with index.html. A file.br or file.gz next to a file is
+// served instead of it to clients accepting that encoding.
+type staticHandler struct {
+	fsys fs.FS
+	// dev marks files on disk that change while the server runs: they are
+	// revalidated by modification time and never cached as immutable.
+	dev bool
+	// etags caches the entity tags of files that do not change.
+	etags sync.Map
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	name, ok := h.resolve(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", contentType(name))
+	header.Set("X-Content-Type-Options", "nosniff")
+	if !h.dev && name != "index.html" && hashedName.MatchString(name) {
+		header.Set("Cache-Control", cacheImmutable)
+	} else {
+		header.Set("Cache-Control", cacheRevalidate)
+	}
+
+	file := name
+	for _, enc := range encodings {
+		if !h.isFile(name + enc.ext) {
+			continue
+		}
+		header.Add("Vary", "Accept-Encoding")
+		if acceptsEncoding(r.Header.Get("Accept-Encoding"), enc.name) {
+			file = name + enc.ext
+			header.Set("Content-Encoding", enc.name)
+			break
+		}
+	}
+	h.serveFile(w, r, file)
+}
+
+// resolve maps a URL path to the name of the file answering it.
+func (h *staticHandler) resolve(urlPath string) (string, bool) {
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if name == "" {
+		name = "index.html"
+	}
+	if h.isFile(name) {
+		return name, true
+	}
+	if index := path.Join(name, "index.html"); h.isFile(index) {
+		return index, true
+	}
+	// Missing assets are errors, not application routes.
+	if path.Ext(name) != "" {
+		return "", false
+	}
+	return "index.html", h.isFile("index.html")
+}
+
+// isFile reports whether name is a regular file of fsys.
+func (h *staticHandler) isFile(name string) bool {
+	info, err := fs.Stat(h.fsys, name)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// serveFile writes the contents of name with http.ServeContent, which
+// handles conditional and range requests.
+func (h *staticHandler) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	modTime := info.ModTime()
+	if !h.dev {
+		etag, err := h.etag(name, content)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		// Embedded files have no modification time, and the entity tag
+		// is a better validator anyway.
+		modTime = time.Time{}
+	}
+	http.ServeContent(w, r, name, modTime, content)
+}
+
+// etag returns the entity tag of name, a hash of its content, and rewinds
+// content.
+func (h *staticHandler) etag(name string, content io.ReadSeeker) (string, error) {
+	if etag, ok := h.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+	sum := sha256.New()
+	if _, err := io.Copy(sum, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := strconv.Quote(hex.EncodeToString(sum.Sum(nil)[:16]))
+	h.etags.Store(name, etag)
+	return etag, nil
+}
+
+// contentType returns the content type of a file by its extension.
+func contentType(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if ct, ok := contentTypes[ext]; ok {
+		return ct
+	}
+	if ct := mime.TypeByExtension(ext); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header value accepts
+// encoding, explicitly or with a wildcard, with a non-zero quality.
+func acceptsEncoding(header, encoding string) bool {
+	accepted := false
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != encoding && coding != "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if coding == encoding {
+			// An explicit entry overrides the wildcard.
+			return q > 0
+		}
+		accepted = q > 0
+	}
+	return accepted
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// testFrontend is a frontend build with a hashed chunk and precompressed
+// variants of main.js.
+func testFrontend() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html":            {Data: []byte("<app-root></app-root>")},
+		"main.js":               {Data: []byte("console.log('main')")},
+		"main.js.br":            {Data: []byte("br data")},
+		"main.js.gz":            {Data: []byte("gzip data")},
+		"chunk-5NBNWQFC.js":     {Data: []byte("chunk")},
+		"styles.css":            {Data: []byte("body {}")},
+		"favicon.ico":           {Data: []byte{0, 0, 1, 0}},
+		"docs/index.html":       {Data: []byte("docs")},
+		"media/font-AB12CD34.x": {Data: []byte("font")},
+	}
+}
+
+func serveStatic(h http.Handler, method, path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestStaticHandlerGood(t *testing.T) {
+	h := &staticHandler{fsys: testFrontend()}
+
+	testCases := []struct {
+		name         string
+		path         string
+		header       map[string]string
+		body         string
+		contentType  string
+		cacheControl string
+		encoding     string
+	}{
+		{"Index", "/", nil, "<app-root></app-root>", "text/html; charset=utf-8", cacheRevalidate, ""},
+		{"Application route", "/settings/general", nil, "<app-root></app-root>", "text/html; charset=utf-8", cacheRevalidate, ""},
+		{"Directory index", "/docs/", nil, "docs", "text/html; charset=utf-8", cacheRevalidate, ""},
+		{"Stylesheet", "/styles.css", nil, "body {}", "text/css; charset=utf-8", cacheRevalidate, ""},
+		{"Icon", "/favicon.ico", nil, "\x00\x00\x01\x00", "image/x-icon", cacheRevalidate, ""},
+		{"Hashed chunk", "/chunk-5NBNWQFC.js", nil, "chunk", "text/javascript; charset=utf-8", cacheImmutable, ""},
+		{"Unknown type", "/media/font-AB12CD34.x", nil, "font", "application/octet-stream", cacheImmutable, ""},
+		{"Uncompressed", "/main.js", nil, "console.log('main')", "text/javascript; charset=utf-8", cacheRevalidate, ""},
+		{"Brotli", "/main.js", map[string]string{"Accept-Encoding": "gzip, deflate, br"}, "br data", "text/javascript; charset=utf-8", cacheRevalidate, "br"},
+		{"Gzip", "/main.js", map[string]string{"Accept-Encoding": "gzip"}, "gzip data", "text/javascript; charset=utf-8", cacheRevalidate, "gzip"},
+		{"Brotli refused", "/main.js", map[string]string{"Accept-Encoding": "*, br;q=0"}, "gzip data", "text/javascript; charset=utf-8", cacheRevalidate, "gzip"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serveStatic(h, http.MethodGet, tc.path, tc.header)
+			if rec.Code != http.StatusOK || rec.Body.String() != tc.body {
+				t.Fatalf("Expected 200 %q, got %d %q", tc.body, rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get("Content-Type"); got != tc.contentType {
+				t.Errorf("Expected Content-Type %q, got %q", tc.contentType, got)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tc.cacheControl {
+				t.Errorf("Expected Cache-Control %q, got %q", tc.cacheControl, got)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tc.encoding {
+				t.Errorf("Expected Content-Encoding %q, got %q", tc.encoding, got)
+			}
+			if rec.Header().Get("ETag") == "" {
+				t.Error("Expected an ETag")
+			}
+		})
+	}
+
+	t.Run("Vary on precompressed files", func(t *testing.T) {
+		rec := serveStatic(h, http.MethodGet, "/main.js", nil)
+		if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("Expected Vary: Accept-Encoding, got %q", got)
+		}
+	})
+
+	t.Run("Revalidation", func(t *testing.T) {
+		etag := serveStatic(h, http.MethodGet, "/styles.css", nil).Header().Get("ETag")
+		rec := serveStatic(h, http.MethodGet, "/styles.css", map[string]string{"If-None-Match": etag})
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("Expected 304 for a matching ETag, got %d", rec.Code)
+		}
+		br := serveStatic(h, http.MethodGet, "/main.js", map[string]string{"Accept-Encoding": "br"}).Header().Get("ETag")
+		plain := serveStatic(h, http.MethodGet, "/main.js", nil).Header().Get("ETag")
+		if br == plain {
+			t.Errorf("Expected different ETags for different encodings, got %s", br)
+		}
+	})
+
+	t.Run("Development files are always revalidated", func(t *testing.T) {
+		dev := &staticHandler{fsys: testFrontend(), dev: true}
+		rec := serveStatic(dev, http.MethodGet, "/chunk-5NBNWQFC.js", nil)
+		if got := rec.Header().Get("Cache-Control"); got != cacheRevalidate {
+			t.Errorf("Expected Cache-Control %q, got %q", cacheRevalidate, got)
+		}
+	})
+}
+
+func TestStaticHandlerBad(t *testing.T) {
+	h := &staticHandler{fsys: testFrontend()}
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		code   int
+	}{
+		{"Missing asset", http.MethodGet, "/missing.js", http.StatusNotFound},
+		{"Method", http.MethodPost, "/", http.StatusMethodNotAllowed},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if rec := serveStatic(h, tc.method, tc.path, nil); rec.Code != tc.code {
+				t.Errorf("Expected %d, got %d", tc.code, rec.Code)
+			}
+		})
+	}
+
+	t.Run("Traversal", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if err := os.Mkdir(filepath.Join(dir, "site"), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		dev := &staticHandler{fsys: os.DirFS(filepath.Join(dir, "site")), dev: true}
+		if rec := serveStatic(dev, http.MethodGet, "/../secret.txt", nil); rec.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 outside the static directory, got %d %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("Frontend not built", func(t *testing.T) {
+		empty := &staticHandler{fsys: fstest.MapFS{}}
+		if rec := serveStatic(empty, http.MethodGet, "/settings", nil); rec.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 without an index.html, got %d", rec.Code)
+		}
+	})
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	testCases := []struct {
+		header   string
+		encoding string
+		expected bool
+	}{
+		{"", "gzip", false},
+		{"gzip", "gzip", true},
+		{"deflate, GZIP", "gzip", true},
+		{"gzip;q=0", "gzip", false},
+		{"gzip; q=0.5", "gzip", true},
+		{"*", "br", true},
+		{"*;q=0, gzip", "br", false},
+		{"br;q=0, *", "br", false},
+		{"identity", "br", false},
+	}
+	for _, tc := range testCases {
+		if got := acceptsEncoding(tc.header, tc.encoding); got != tc.expected {
+			t.Errorf("acceptsEncoding(%q, %q) = %v, expected %v", tc.header, tc.encoding, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// selfSignedValidity is how long a generated certificate is valid.
+const selfSignedValidity = 365 * 24 * time.Hour
+
+// EnsureSelfSigned generates a self-signed certificate for hosts with
+// GenerateSelfSigned if neither certFile nor keyFile exists, and keeps them if
+// both do. It returns an error rather than overwriting anything if only one
+// of them exists. It reports whether a certificate was generated.
+func EnsureSelfSigned(certFile, keyFile string, hosts []string) (bool, error) {
+	certExists, err := fileExists(certFile)
+	if err != nil {
+		return false, err
+	}
+	keyExists, err := fileExists(keyFile)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case certExists && keyExists:
+		return false, nil
+	case certExists:
+		return false, fmt.Errorf("certificate %s exists but its key %s does not", certFile, keyFile)
+	case keyExists:
+		return false, fmt.Errorf("key %s exists but its certificate %s does not", keyFile, certFile)
+	}
+	if err := GenerateSelfSigned(certFile, keyFile, hosts); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GenerateSelfSigned writes a self-signed certificate for hosts, which may
+// be host names or IP addresses, and its ECDSA private key to certFile and
+// keyFile in PEM form. The certificate is valid for a year and is meant for
+// local use only: browsers warn about it until it is trusted explicitly.
+func GenerateSelfSigned(certFile, keyFile string, hosts []string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"demo-cli self-signed"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := writePEM(certFile, "CERTIFICATE", der, 0644); err != nil {
+		return err
+	}
+	return writePEM(keyFile, "PRIVATE KEY", keyDER, 0600)
+}
+
+// writePEM writes a single PEM block to path, creating its directory.
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), perm)
+}
+
+// fileExists reports whether path exists.
+func fileExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,33 @@
+// Package ui embeds the production build of the Angular frontend, so the
+// demo CLI can serve it without the repository at hand.
+//
+// The build must exist before the binary is compiled; go generate runs it:
+//
+//	go generate ./ui
+//
+// A binary built without it serves no frontend.
+package ui
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:generate npm ci
+//go:generate npm run build
+
+// BuildDir is the directory of the build, relative to this package.
+const BuildDir = "dist/config/browser"
+
+//go:embed all:dist
+var dist embed.FS
+
+// FS returns the embedded build, rooted at the directory of its index.html.
+// It is empty if the frontend was not built.
+func FS() fs.FS {
+	sub, err := fs.Sub(dist, BuildDir)
+	if err != nil {
+		panic(err) // BuildDir is a valid path
+	}
+	return sub
+}